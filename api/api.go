@@ -2,63 +2,413 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/fatemehkarimi/chronos_bot/entities"
 )
 
 type Api interface {
 	SendMessage(
+		ctx context.Context,
 		chatId string,
 		text string,
 		replyMarkUp entities.ReplyMarkup,
-	) entities.MethodResponse
+	) (entities.ResponseSendMessage, error)
+	EditMessageText(
+		ctx context.Context,
+		chatId string,
+		messageId int,
+		text string,
+		replyMarkUp entities.ReplyMarkup,
+	) (entities.ResponseEditMessageText, error)
+	EditMessageReplyMarkup(
+		ctx context.Context,
+		chatId string,
+		messageId int,
+		replyMarkUp entities.ReplyMarkup,
+	) (entities.ResponseEditMessageReplyMarkup, error)
+	AnswerCallbackQuery(
+		ctx context.Context,
+		callbackQueryId string,
+		text string,
+	) error
+	DeleteMessage(ctx context.Context, chatId string, messageId int) error
+	SendDocument(
+		ctx context.Context,
+		chatId string,
+		fileName string,
+		document io.Reader,
+		caption string,
+	) (entities.ResponseSendDocument, error)
+	GetMe(ctx context.Context) (entities.ResponseGetMe, error)
+	GetChat(ctx context.Context, chatId string) (entities.ResponseGetChat, error)
+	GetChatMember(
+		ctx context.Context,
+		chatId string,
+		userId int,
+	) (entities.ResponseGetChatMember, error)
+}
+
+const (
+	DefaultBaleBaseURL     = "https://tapi.bale.ai"
+	DefaultTelegramBaseURL = "https://api.telegram.org"
+
+	DefaultTimeout     = 60 * time.Second
+	DefaultGlobalRate  = 30
+	DefaultGlobalBurst = 30
+	DefaultChatRate    = 1
+	DefaultChatBurst   = 5
+	DefaultMaxRetries  = 3
+
+	minRetryDelay = 500 * time.Millisecond
+	maxRetryDelay = 30 * time.Second
+)
+
+// idempotentMethods are the methods that have the same effect when bale
+// receives them more than once.
+var idempotentMethods = map[string]bool{
+	"getUpdates":             true,
+	"getMe":                  true,
+	"getChat":                true,
+	"getChatMember":          true,
+	"getWebhookInfo":         true,
+	"setWebhook":             true,
+	"deleteWebhook":          true,
+	"editMessageText":        true,
+	"editMessageReplyMarkup": true,
+	"deleteMessage":          true,
+}
+
+type Config struct {
+	// BaseURL is the address of the bot api server. the default server of
+	// the messenger is used if it is empty.
+	BaseURL string
+	// Timeout bounds a single request. it must be longer than the long
+	// polling timeout.
+	Timeout time.Duration
+	// GlobalRate and ChatRate are in requests per second.
+	GlobalRate  float64
+	GlobalBurst int
+	ChatRate    float64
+	ChatBurst   int
+	MaxRetries  int
+	// Client is used instead of a client built from Timeout if it is set.
+	Client *http.Client `mapstructure:"-"`
 }
 
 type BaleApi struct {
-	token string
+	baseURL    string
+	token      string
+	client     *http.Client
+	limiter    *rateLimiter
+	maxRetries int
 }
 
-func NewBaleApi(token string) BaleApi {
-	return BaleApi{token}
+func NewBaleApi(token string, config Config) BaleApi {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaleBaseURL
+	}
+	return newBotApi(token, config)
 }
 
-func (api BaleApi) SendMessage(
-	chatId string,
-	text string,
-	replyMarkUp entities.ReplyMarkup,
-) entities.MethodResponse {
-	requestStruct := entities.RequestSendMessage{
-		ChatId:      chatId,
-		Text:        text,
-		ReplyMarkup: &replyMarkUp,
+// newBotApi builds a client of a bot api server. bale and telegram share
+// the same bot api, so they share the client too.
+func newBotApi(token string, config Config) BaleApi {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
 	}
+	if config.GlobalRate <= 0 {
+		config.GlobalRate = DefaultGlobalRate
+	}
+	if config.GlobalBurst <= 0 {
+		config.GlobalBurst = DefaultGlobalBurst
+	}
+	if config.ChatRate <= 0 {
+		config.ChatRate = DefaultChatRate
+	}
+	if config.ChatBurst <= 0 {
+		config.ChatBurst = DefaultChatBurst
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultMaxRetries
+	}
+
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: config.Timeout}
+	}
+
+	return BaleApi{
+		baseURL:    strings.TrimSuffix(config.BaseURL, "/"),
+		token:      token,
+		client:     client,
+		limiter:    newRateLimiter(config),
+		maxRetries: config.MaxRetries,
+	}
+}
+
+func (api BaleApi) endpoint(method string) string {
+	return fmt.Sprintf("%s/bot%s/%s", api.baseURL, api.token, method)
+}
+
+// redact replaces the url in err with one without the token, so that the
+// error can be logged.
+func (api BaleApi) redact(method string, err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	return &url.Error{
+		Op:  urlErr.Op,
+		URL: fmt.Sprintf("%s/bot<token>/%s", api.baseURL, method),
+		Err: urlErr.Err,
+	}
+}
 
-	requestBytes, err := json.MarshalIndent(requestStruct, "", "  ")
+// call posts request as json to the bot api method and returns the result of
+// the response. chatId is the chat the request is rate limited for.
+func call[T any](
+	ctx context.Context,
+	api BaleApi,
+	method string,
+	chatId string,
+	request any,
+) (T, error) {
+	var result T
+	requestBytes, err := json.Marshal(request)
 	if err != nil {
-		return entities.MethodResponse{
-			Err: err,
+		return result, err
+	}
+
+	return do[T](ctx, api, method, chatId, requestBytes, "application/json")
+}
+
+// do sends body to the method, retrying rate limited requests and failed
+// requests of idempotent methods with exponential backoff. a response with ok set to false is returned as
+// a *BaleError.
+func do[T any](
+	ctx context.Context,
+	api BaleApi,
+	method string,
+	chatId string,
+	body []byte,
+	contentType string,
+) (T, error) {
+	for attempt := 0; ; attempt++ {
+		err := api.limiter.wait(ctx, chatId)
+		if err != nil {
+			var result T
+			return result, err
+		}
+
+		result, err := send[T](ctx, api, method, body, contentType)
+		if err == nil || attempt >= api.maxRetries || !retryable(ctx, method, err) {
+			return result, err
+		}
+
+		delay := min(minRetryDelay<<attempt, maxRetryDelay)
+		status := 0
+		if baleErr, ok := asBaleError(err); ok {
+			status = baleErr.ErrorCode
+			if baleErr.RetryAfter > 0 {
+				delay = baleErr.RetryAfter
+			}
+		}
+		// the error is not logged, since errors of the client may carry the
+		// url of the request, which contains the token.
+		slog.Warn(
+			"retrying bale api request",
+			slog.String("method", method),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("delay", delay),
+			slog.Int("status", status),
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, ctx.Err()
+		case <-timer.C:
 		}
 	}
-	endpoint := fmt.Sprintf("https://tapi.bale.ai/bot%s/sendMessage", api.token)
-	req, err := http.NewRequest("GET", endpoint, bytes.NewBuffer(requestBytes))
+}
 
+func send[T any](
+	ctx context.Context,
+	api BaleApi,
+	method string,
+	body []byte,
+	contentType string,
+) (T, error) {
+	var result T
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		api.endpoint(method),
+		bytes.NewReader(body),
+	)
 	if err != nil {
-		return entities.MethodResponse{
-			Err: err,
-		}
+		return result, api.redact(method, err)
 	}
+	req.Header.Set("Content-Type", contentType)
 
-	client := &http.Client{}
-	res, err := client.Do(req)
+	res, err := api.client.Do(req)
+	if err != nil {
+		return result, api.redact(method, err)
+	}
+	defer res.Body.Close()
 
+	var response entities.Response[T]
+	err = json.NewDecoder(res.Body).Decode(&response)
 	if err != nil {
-		return entities.MethodResponse{
-			Err: err,
+		return result, &BaleError{
+			Method:      method,
+			ErrorCode:   res.StatusCode,
+			Description: fmt.Sprintf("decoding response: %v", err),
+		}
+	}
+
+	if !response.Ok {
+		baleErr := &BaleError{
+			Method:      method,
+			ErrorCode:   response.ErrorCode,
+			Description: response.Description,
+		}
+		if baleErr.ErrorCode == 0 {
+			baleErr.ErrorCode = res.StatusCode
 		}
+		if response.Parameters != nil {
+			baleErr.RetryAfter = time.Duration(response.Parameters.RetryAfter) * time.Second
+		}
+		return result, baleErr
+	}
+	return response.Result, nil
+}
+
+// retryable reports whether a failed request may succeed if it is sent
+// again. a rate limited request was rejected before it took effect, but a
+// request that failed in transport or on the server may already have been
+// applied, so only idempotent methods are sent again then.
+func retryable(ctx context.Context, method string, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	baleErr, ok := asBaleError(err)
+	if ok && baleErr.ErrorCode == http.StatusTooManyRequests {
+		return true
+	}
+	if !idempotentMethods[method] {
+		return false
+	}
+	// the request did not reach bale, the connection broke or the server
+	// failed.
+	return !ok || baleErr.ErrorCode >= http.StatusInternalServerError
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func (api BaleApi) SendMessage(
+	ctx context.Context,
+	chatId string,
+	text string,
+	replyMarkUp entities.ReplyMarkup,
+) (entities.ResponseSendMessage, error) {
+	requestStruct := entities.RequestSendMessage{
+		ChatId: chatId,
+		Text:   text,
+	}
+	if replyMarkUp != nil {
+		requestStruct.ReplyMarkup = &replyMarkUp
+	}
+
+	return call[entities.ResponseSendMessage](
+		ctx,
+		api,
+		"sendMessage",
+		chatId,
+		requestStruct,
+	)
+}
+
+// GetUpdates long polls bale for the updates after offset. the request is
+// held by bale for up to timeout seconds when there is no update.
+func (api BaleApi) GetUpdates(
+	ctx context.Context,
+	offset int,
+	limit int,
+	timeout int,
+) ([]entities.Update, error) {
+	requestStruct := entities.RequestGetUpdates{
+		Offset:  offset,
+		Limit:   limit,
+		Timeout: timeout,
 	}
 
-	return entities.MethodResponse{Response: res, Err: nil}
+	return call[entities.ResponseGetUpdates](
+		ctx,
+		api,
+		"getUpdates",
+		"",
+		requestStruct,
+	)
+}
+
+// SetWebhook asks bale to post updates to url. bale sends secretToken back
+// in the secret token header of every webhook request.
+func (api BaleApi) SetWebhook(
+	ctx context.Context,
+	url string,
+	secretToken string,
+) error {
+	requestStruct := entities.RequestSetWebhook{
+		Url:         url,
+		SecretToken: secretToken,
+	}
+
+	_, err := call[entities.ResponseSetWebhook](
+		ctx,
+		api,
+		"setWebhook",
+		"",
+		requestStruct,
+	)
+	return err
+}
+
+func (api BaleApi) DeleteWebhook(ctx context.Context) error {
+	_, err := call[entities.ResponseDeleteWebhook](
+		ctx,
+		api,
+		"deleteWebhook",
+		"",
+		entities.RequestDeleteWebhook{},
+	)
+	return err
+}
+
+func (api BaleApi) GetWebhookInfo(
+	ctx context.Context,
+) (entities.WebhookInfo, error) {
+	return call[entities.ResponseGetWebhookInfo](
+		ctx,
+		api,
+		"getWebhookInfo",
+		"",
+		entities.RequestGetWebhookInfo{},
+	)
 }
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+const testToken = "123:secret"
+
+// closeRecorder counts the response bodies that are closed.
+type closeRecorder struct {
+	transport http.RoundTripper
+	opened    atomic.Int32
+	closed    atomic.Int32
+}
+
+func (r *closeRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	r.opened.Add(1)
+	res.Body = &recordedBody{ReadCloser: res.Body, closed: &r.closed}
+	return res, nil
+}
+
+type recordedBody struct {
+	io.ReadCloser
+	closed *atomic.Int32
+}
+
+func (b *recordedBody) Close() error {
+	b.closed.Add(1)
+	return b.ReadCloser.Close()
+}
+
+// newTestApi returns a client of a bot api server that answers every method
+// with handler.
+func newTestApi(
+	t *testing.T,
+	config Config,
+	handler http.HandlerFunc,
+) (BaleApi, *closeRecorder) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	recorder := &closeRecorder{transport: server.Client().Transport}
+	config.BaseURL = server.URL
+	config.Client = &http.Client{Transport: recorder}
+	return newBotApi(testToken, config), recorder
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   entities.ResponseGetMe
+		err    *BaleError
+	}{
+		{
+			name:   "result",
+			status: http.StatusOK,
+			body:   `{"ok":true,"result":{"id":42,"is_bot":true,"first_name":"chronos"}}`,
+			want:   entities.ResponseGetMe{Id: 42, IsBot: true, FirstName: "chronos"},
+		},
+		{
+			name:   "bale error",
+			status: http.StatusTooManyRequests,
+			body:   `{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":7}}`,
+			err: &BaleError{
+				Method:      "getMe",
+				ErrorCode:   http.StatusTooManyRequests,
+				Description: "Too Many Requests",
+				RetryAfter:  7 * time.Second,
+			},
+		},
+		{
+			name:   "bale error without a code",
+			status: http.StatusForbidden,
+			body:   `{"ok":false,"description":"Forbidden: bot was blocked by the user"}`,
+			err: &BaleError{
+				Method:      "getMe",
+				ErrorCode:   http.StatusForbidden,
+				Description: "Forbidden: bot was blocked by the user",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api, recorder := newTestApi(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/bot"+testToken+"/getMe" {
+					t.Errorf("request was sent to %s", r.URL.Path)
+				}
+				w.WriteHeader(test.status)
+				io.WriteString(w, test.body)
+			})
+
+			got, err := send[entities.ResponseGetMe](t.Context(), api, "getMe", nil, "application/json")
+			if test.err == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Id != test.want.Id || got.IsBot != test.want.IsBot ||
+					got.FirstName != test.want.FirstName {
+					t.Fatalf("got result %+v, want %+v", got, test.want)
+				}
+			} else {
+				baleErr, ok := asBaleError(err)
+				if !ok {
+					t.Fatalf("got error %v, want a bale error", err)
+				}
+				if *baleErr != *test.err {
+					t.Fatalf("got bale error %+v, want %+v", baleErr, test.err)
+				}
+			}
+
+			if recorder.opened.Load() != 1 || recorder.closed.Load() != 1 {
+				t.Fatalf("closed %d of %d response bodies", recorder.closed.Load(), recorder.opened.Load())
+			}
+		})
+	}
+}
+
+func TestSendNonJsonResponse(t *testing.T) {
+	api, recorder := newTestApi(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, "<html>502 Bad Gateway</html>")
+	})
+
+	_, err := send[entities.ResponseGetMe](t.Context(), api, "getMe", nil, "application/json")
+	baleErr, ok := asBaleError(err)
+	if !ok {
+		t.Fatalf("got error %v, want a bale error", err)
+	}
+	if baleErr.Method != "getMe" || baleErr.ErrorCode != http.StatusBadGateway ||
+		!strings.HasPrefix(baleErr.Description, "decoding response") {
+		t.Fatalf("got bale error %+v", baleErr)
+	}
+	if recorder.closed.Load() != 1 {
+		t.Fatal("the response body was not closed")
+	}
+}
+
+func TestSendTransportError(t *testing.T) {
+	api, _ := newTestApi(t, Config{}, func(w http.ResponseWriter, r *http.Request) {})
+	api.client = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})}
+
+	_, err := send[entities.ResponseGetMe](t.Context(), api, "getMe", nil, "application/json")
+	if err == nil {
+		t.Fatal("a failed request returned no error")
+	}
+	if _, ok := asBaleError(err); ok {
+		t.Fatalf("a failed request returned the bale error %v", err)
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRetryable(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		err    error
+		want   bool
+	}{
+		{"rate limited", context.Background(), "sendMessage", &BaleError{ErrorCode: http.StatusTooManyRequests}, true},
+		{"server error of idempotent method", context.Background(), "getMe", &BaleError{ErrorCode: http.StatusBadGateway}, true},
+		{"server error", context.Background(), "sendMessage", &BaleError{ErrorCode: http.StatusBadGateway}, false},
+		{"transport error of idempotent method", context.Background(), "editMessageText", errors.New("connection reset"), true},
+		{"transport error", context.Background(), "sendMessage", errors.New("connection reset"), false},
+		{"bad request", context.Background(), "getMe", &BaleError{ErrorCode: http.StatusBadRequest}, false},
+		{"cancelled", cancelled, "getMe", &BaleError{ErrorCode: http.StatusTooManyRequests}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := retryable(test.ctx, test.method, test.err); got != test.want {
+				t.Fatalf("retryable(%s, %v) = %v, want %v", test.method, test.err, got, test.want)
+			}
+		})
+	}
+}
+
+// failingServer fails the first failures requests with status and answers the
+// rest with an empty result. it returns the number of requests it received.
+func failingServer(failures int, status int, body string) (http.HandlerFunc, *atomic.Int32) {
+	var requests atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		if int(requests.Add(1)) <= failures {
+			w.WriteHeader(status)
+			io.WriteString(w, body)
+			return
+		}
+		io.WriteString(w, `{"ok":true,"result":{"id":1}}`)
+	}, &requests
+}
+
+func TestDoRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		maxRetries int
+		failures   int
+		status     int
+		body       string
+		requests   int32
+		minElapsed time.Duration
+		ok         bool
+	}{
+		{
+			name:       "idempotent method after server errors",
+			method:     "getMe",
+			maxRetries: 3,
+			failures:   2,
+			status:     http.StatusBadGateway,
+			body:       `{"ok":false,"error_code":502,"description":"Bad Gateway"}`,
+			requests:   3,
+			// the delay doubles after each attempt.
+			minElapsed: minRetryDelay + 2*minRetryDelay,
+			ok:         true,
+		},
+		{
+			name:       "idempotent method up to the retry limit",
+			method:     "getMe",
+			maxRetries: 1,
+			failures:   5,
+			status:     http.StatusBadGateway,
+			body:       `{"ok":false,"error_code":502,"description":"Bad Gateway"}`,
+			requests:   2,
+			minElapsed: minRetryDelay,
+		},
+		{
+			name:       "no retry of a server error",
+			method:     "sendMessage",
+			maxRetries: 3,
+			failures:   1,
+			status:     http.StatusInternalServerError,
+			body:       `{"ok":false,"error_code":500,"description":"Internal Server Error"}`,
+			requests:   1,
+		},
+		{
+			name:       "rate limited after retry after",
+			method:     "sendMessage",
+			maxRetries: 3,
+			failures:   1,
+			status:     http.StatusTooManyRequests,
+			body:       `{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":1}}`,
+			requests:   2,
+			minElapsed: time.Second,
+			ok:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler, requests := failingServer(test.failures, test.status, test.body)
+			api, recorder := newTestApi(t, Config{MaxRetries: test.maxRetries}, handler)
+
+			start := time.Now()
+			_, err := do[entities.ResponseGetMe](t.Context(), api, test.method, "1", nil, "application/json")
+			elapsed := time.Since(start)
+			if test.ok && err != nil {
+				t.Fatal(err)
+			}
+			if !test.ok && err == nil {
+				t.Fatal("the request succeeded")
+			}
+			if got := requests.Load(); got != test.requests {
+				t.Fatalf("sent %d requests, want %d", got, test.requests)
+			}
+			if elapsed < test.minElapsed {
+				t.Fatalf("retried after %v, want at least %v", elapsed, test.minElapsed)
+			}
+			if recorder.closed.Load() != recorder.opened.Load() {
+				t.Fatalf("closed %d of %d response bodies", recorder.closed.Load(), recorder.opened.Load())
+			}
+		})
+	}
+}
+
+func TestDoStopsRetryingWhenCancelled(t *testing.T) {
+	handler, requests := failingServer(
+		10,
+		http.StatusTooManyRequests,
+		`{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":60}}`,
+	)
+	api, _ := newTestApi(t, Config{}, handler)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	_, err := do[entities.ResponseGetMe](ctx, api, "sendMessage", "1", nil, "application/json")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want the deadline", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("sent %d requests, want 1", got)
+	}
+}
+
+func TestTransportErrorsDoNotLeakTheToken(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(logger)
+
+	api, _ := newTestApi(t, Config{MaxRetries: 1}, func(w http.ResponseWriter, r *http.Request) {})
+	api.client = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})}
+
+	_, err := do[entities.ResponseGetMe](t.Context(), api, "getMe", "", nil, "application/json")
+	if err == nil {
+		t.Fatal("a failed request returned no error")
+	}
+	for _, text := range []string{err.Error(), fmt.Sprintf("%#v", err), logs.String()} {
+		if strings.Contains(text, testToken) {
+			t.Fatalf("the token leaked in %q", text)
+		}
+	}
+	if !strings.Contains(err.Error(), "getMe") || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("got error %v, want the method and the cause", err)
+	}
+	if !strings.Contains(logs.String(), "method=getMe") {
+		t.Fatalf("the retry was not logged: %q", logs.String())
+	}
+}
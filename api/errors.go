@@ -0,0 +1,54 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// BaleError is returned when bale answers a method with ok set to false.
+type BaleError struct {
+	Method      string
+	ErrorCode   int
+	Description string
+	// RetryAfter is set when the request is rate limited.
+	RetryAfter time.Duration
+}
+
+func (e *BaleError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.Method, e.ErrorCode, e.Description)
+}
+
+func asBaleError(err error) (*BaleError, bool) {
+	var baleErr *BaleError
+	ok := errors.As(err, &baleErr)
+	return baleErr, ok
+}
+
+// IsChatUnreachable reports whether the message could not be delivered
+// because the chat does not exist or the user has blocked the bot or never
+// started it.
+func IsChatUnreachable(err error) bool {
+	baleErr, ok := asBaleError(err)
+	if !ok {
+		return false
+	}
+
+	return baleErr.ErrorCode == http.StatusForbidden ||
+		(baleErr.ErrorCode == http.StatusBadRequest &&
+			containsFold(baleErr.Description, "chat not found"))
+}
+
+// IsTooManyRequests reports whether the request is rate limited.
+func IsTooManyRequests(err error) bool {
+	baleErr, ok := asBaleError(err)
+	return ok && baleErr.ErrorCode == http.StatusTooManyRequests
+}
+
+// IsMessageNotModified reports whether an edit is rejected because it does
+// not change the message.
+func IsMessageNotModified(err error) bool {
+	baleErr, ok := asBaleError(err)
+	return ok && containsFold(baleErr.Description, "message is not modified")
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+func (api BaleApi) EditMessageText(
+	ctx context.Context,
+	chatId string,
+	messageId int,
+	text string,
+	replyMarkUp entities.ReplyMarkup,
+) (entities.ResponseEditMessageText, error) {
+	requestStruct := entities.RequestEditMessageText{
+		ChatId:    chatId,
+		MessageId: messageId,
+		Text:      text,
+	}
+	if replyMarkUp != nil {
+		requestStruct.ReplyMarkup = &replyMarkUp
+	}
+
+	return call[entities.ResponseEditMessageText](
+		ctx,
+		api,
+		"editMessageText",
+		chatId,
+		requestStruct,
+	)
+}
+
+// EditMessageReplyMarkup replaces the inline keyboard of a message. a nil
+// replyMarkUp removes the keyboard.
+func (api BaleApi) EditMessageReplyMarkup(
+	ctx context.Context,
+	chatId string,
+	messageId int,
+	replyMarkUp entities.ReplyMarkup,
+) (entities.ResponseEditMessageReplyMarkup, error) {
+	if replyMarkUp == nil {
+		replyMarkUp = entities.InlineKeyboardMarkup{
+			InlineKeyboard: [][]entities.InlineKeyboardButton{},
+		}
+	}
+
+	requestStruct := entities.RequestEditMessageReplyMarkup{
+		ChatId:      chatId,
+		MessageId:   messageId,
+		ReplyMarkup: &replyMarkUp,
+	}
+	return call[entities.ResponseEditMessageReplyMarkup](
+		ctx,
+		api,
+		"editMessageReplyMarkup",
+		chatId,
+		requestStruct,
+	)
+}
+
+// AnswerCallbackQuery stops the loading indicator of the pressed button. text
+// is shown to the user as a notification if it is not empty.
+func (api BaleApi) AnswerCallbackQuery(
+	ctx context.Context,
+	callbackQueryId string,
+	text string,
+) error {
+	requestStruct := entities.RequestAnswerCallbackQuery{
+		CallbackQueryId: callbackQueryId,
+		Text:            text,
+	}
+	_, err := call[entities.ResponseAnswerCallbackQuery](
+		ctx,
+		api,
+		"answerCallbackQuery",
+		"",
+		requestStruct,
+	)
+	return err
+}
+
+func (api BaleApi) DeleteMessage(
+	ctx context.Context,
+	chatId string,
+	messageId int,
+) error {
+	requestStruct := entities.RequestDeleteMessage{
+		ChatId:    chatId,
+		MessageId: messageId,
+	}
+	_, err := call[entities.ResponseDeleteMessage](
+		ctx,
+		api,
+		"deleteMessage",
+		chatId,
+		requestStruct,
+	)
+	return err
+}
+
+func (api BaleApi) SendDocument(
+	ctx context.Context,
+	chatId string,
+	fileName string,
+	document io.Reader,
+	caption string,
+) (entities.ResponseSendDocument, error) {
+	requestStruct := entities.RequestSendDocument{
+		ChatId:   chatId,
+		FileName: fileName,
+		Document: document,
+		Caption:  caption,
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	err := writer.WriteField("chat_id", requestStruct.ChatId)
+	if err == nil && requestStruct.Caption != "" {
+		err = writer.WriteField("caption", requestStruct.Caption)
+	}
+	if err == nil {
+		var part io.Writer
+		part, err = writer.CreateFormFile("document", requestStruct.FileName)
+		if err == nil {
+			_, err = io.Copy(part, requestStruct.Document)
+		}
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		return entities.ResponseSendDocument{}, err
+	}
+
+	return do[entities.ResponseSendDocument](
+		ctx,
+		api,
+		"sendDocument",
+		chatId,
+		body.Bytes(),
+		writer.FormDataContentType(),
+	)
+}
+
+func (api BaleApi) GetMe(ctx context.Context) (entities.ResponseGetMe, error) {
+	return call[entities.ResponseGetMe](
+		ctx,
+		api,
+		"getMe",
+		"",
+		entities.RequestGetMe{},
+	)
+}
+
+func (api BaleApi) GetChat(
+	ctx context.Context,
+	chatId string,
+) (entities.ResponseGetChat, error) {
+	return call[entities.ResponseGetChat](
+		ctx,
+		api,
+		"getChat",
+		chatId,
+		entities.RequestGetChat{ChatId: chatId},
+	)
+}
+
+func (api BaleApi) GetChatMember(
+	ctx context.Context,
+	chatId string,
+	userId int,
+) (entities.ResponseGetChatMember, error) {
+	return call[entities.ResponseGetChatMember](
+		ctx,
+		api,
+		"getChatMember",
+		chatId,
+		entities.RequestGetChatMember{ChatId: chatId, UserId: userId},
+	)
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket allows rate requests per second on average with bursts of up
+// to burst requests.
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:     rate,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		lastFill: time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.lastFill).Seconds()*b.rate)
+	b.lastFill = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// idle reports whether the bucket is full, meaning it can be dropped without
+// changing the rate it allows.
+func (b *tokenBucket) idle(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens+now.Sub(b.lastFill).Seconds()*b.rate >= b.burst
+}
+
+// rateLimiter limits the requests sent to bale globally and per chat.
+type rateLimiter struct {
+	global *tokenBucket
+
+	mu        sync.Mutex
+	chatRate  float64
+	chatBurst int
+	chats     map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(config Config) *rateLimiter {
+	return &rateLimiter{
+		global:    newTokenBucket(config.GlobalRate, config.GlobalBurst),
+		chatRate:  config.ChatRate,
+		chatBurst: config.ChatBurst,
+		chats:     map[string]*tokenBucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// wait blocks until a request to chatId is allowed. an empty chatId is only
+// limited globally.
+func (l *rateLimiter) wait(ctx context.Context, chatId string) error {
+	now := time.Now()
+	delay := l.global.reserve(now)
+	if chatId != "" {
+		delay = max(delay, l.chat(chatId, now).reserve(now))
+	}
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (l *rateLimiter) chat(chatId string, now time.Time) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > time.Minute {
+		for id, bucket := range l.chats {
+			if bucket.idle(now) {
+				delete(l.chats, id)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	bucket, ok := l.chats[chatId]
+	if !ok {
+		bucket = newTokenBucket(l.chatRate, l.chatBurst)
+		l.chats[chatId] = bucket
+	}
+	return bucket
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(2, 3)
+	bucket.lastFill = now
+
+	// the burst is allowed right away.
+	for i := 0; i < 3; i++ {
+		if delay := bucket.reserve(now); delay != 0 {
+			t.Fatalf("request %d of the burst waits %v", i+1, delay)
+		}
+	}
+	if delay := bucket.reserve(now); delay != 500*time.Millisecond {
+		t.Fatalf("request after the burst waits %v, want 500ms", delay)
+	}
+	if delay := bucket.reserve(now); delay != time.Second {
+		t.Fatalf("second request after the burst waits %v, want 1s", delay)
+	}
+	if bucket.idle(now) {
+		t.Fatal("an empty bucket is idle")
+	}
+
+	// the tokens refilled in a second pay for the reserved ones first.
+	now = now.Add(time.Second)
+	if delay := bucket.reserve(now); delay != 500*time.Millisecond {
+		t.Fatalf("request a second later waits %v, want 500ms", delay)
+	}
+
+	// the bucket does not fill beyond the burst.
+	now = now.Add(time.Hour)
+	if !bucket.idle(now) {
+		t.Fatal("a full bucket is not idle")
+	}
+	for i := 0; i < 3; i++ {
+		bucket.reserve(now)
+	}
+	if delay := bucket.reserve(now); delay == 0 {
+		t.Fatal("the bucket allowed more than its burst after a long idle time")
+	}
+}
+
+func TestRateLimiterLimitsEachChat(t *testing.T) {
+	limiter := newRateLimiter(Config{
+		GlobalRate:  1000,
+		GlobalBurst: 1000,
+		ChatRate:    0.001,
+		ChatBurst:   1,
+	})
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	err := limiter.wait(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// other chats and requests to no chat are not limited by chat 1.
+	err = limiter.wait(ctx, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = limiter.wait(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = limiter.wait(ctx, "1")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("a second request to the chat returned %v, want to wait past the deadline", err)
+	}
+}
+
+func TestRateLimiterLimitsAllChats(t *testing.T) {
+	limiter := newRateLimiter(Config{
+		GlobalRate:  0.001,
+		GlobalBurst: 2,
+		ChatRate:    1000,
+		ChatBurst:   1000,
+	})
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	for _, chatId := range []string{"1", "2"} {
+		err := limiter.wait(ctx, chatId)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := limiter.wait(ctx, "3")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("a request after the global burst returned %v, want to wait past the deadline", err)
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+const (
+	BalePlatform     = "bale"
+	TelegramPlatform = "telegram"
+)
+
+// TelegramApi is the client of the telegram bot api. bale implements the
+// telegram bot api, so the requests and responses are the same.
+type TelegramApi struct {
+	BaleApi
+}
+
+func NewTelegramApi(token string, config Config) TelegramApi {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultTelegramBaseURL
+	}
+	return TelegramApi{newBotApi(token, config)}
+}
+
+// BotApi is the whole bot api of a messenger, including the methods that
+// receive updates.
+type BotApi interface {
+	Api
+	GetUpdates(
+		ctx context.Context,
+		offset int,
+		limit int,
+		timeout int,
+	) ([]entities.Update, error)
+	SetWebhook(ctx context.Context, url string, secretToken string) error
+	DeleteWebhook(ctx context.Context) error
+	GetWebhookInfo(ctx context.Context) (entities.WebhookInfo, error)
+}
+
+// NewPlatformApi returns the client of the messenger named by platform. an
+// empty platform means bale.
+func NewPlatformApi(
+	platform string,
+	token string,
+	config Config,
+) (BotApi, error) {
+	switch platform {
+	case "", BalePlatform:
+		return NewBaleApi(token, config), nil
+	case TelegramPlatform:
+		return NewTelegramApi(token, config), nil
+	default:
+		return nil, fmt.Errorf("unknown platform %q", platform)
+	}
+}
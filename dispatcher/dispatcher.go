@@ -0,0 +1,146 @@
+package dispatcher
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+const (
+	DefaultWorkers       = 8
+	DefaultQueueSize     = 64
+	DefaultUpdateTimeout = 2 * time.Minute
+)
+
+type Config struct {
+	Workers   int
+	QueueSize int
+	// UpdateTimeout bounds processing a single update.
+	UpdateTimeout time.Duration
+}
+
+// Dispatcher processes updates concurrently across chats while the updates
+// of a single chat are processed one by one in the order they are
+// dispatched. every chat is always served by the same worker, so a slow chat
+// only delays the chats that share its worker.
+type Dispatcher struct {
+	// ctx is cancelled on shutdown. the updates that are still being
+	// processed then are cancelled too.
+	ctx           context.Context
+	process       func(context.Context, entities.Update)
+	queues        []chan entities.Update
+	updateTimeout time.Duration
+	wg            sync.WaitGroup
+
+	mu      sync.RWMutex
+	stopped bool
+}
+
+func NewDispatcher(
+	ctx context.Context,
+	config Config,
+	process func(context.Context, entities.Update),
+) *Dispatcher {
+	workers := config.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	updateTimeout := config.UpdateTimeout
+	if updateTimeout <= 0 {
+		updateTimeout = DefaultUpdateTimeout
+	}
+
+	d := &Dispatcher{
+		ctx:           ctx,
+		process:       process,
+		queues:        make([]chan entities.Update, workers),
+		updateTimeout: updateTimeout,
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan entities.Update, queueSize)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+// Dispatch queues the update on the worker of its chat. it blocks while the
+// queue of that worker is full and reports false if the dispatcher is
+// stopped.
+func (d *Dispatcher) Dispatch(update entities.Update) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.stopped {
+		return false
+	}
+
+	d.queues[d.worker(ChatId(update))] <- update
+	return true
+}
+
+// Stop stops accepting updates and waits for the queued ones to be processed.
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = true
+	for _, queue := range d.queues {
+		close(queue)
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) worker(chatId int64) int {
+	if chatId < 0 {
+		chatId = -chatId
+	}
+	return int(chatId % int64(len(d.queues)))
+}
+
+func (d *Dispatcher) work(queue chan entities.Update) {
+	defer d.wg.Done()
+	for update := range queue {
+		d.processSafely(update)
+	}
+}
+
+func (d *Dispatcher) processSafely(update entities.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error(
+				"panic while processing update",
+				slog.Int("updateId", update.UpdateId),
+				slog.Any("panic", r),
+			)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(d.ctx, d.updateTimeout)
+	defer cancel()
+	d.process(ctx, update)
+}
+
+// ChatId returns the chat an update belongs to. updates of the same chat
+// share a conversation state and must not be processed concurrently.
+func ChatId(update entities.Update) int64 {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.Id
+	case update.CallbackQuery != nil:
+		return int64(update.CallbackQuery.From.Id)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,123 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+func messageUpdate(updateId int, chatId int64) entities.Update {
+	return entities.Update{
+		UpdateId: updateId,
+		Message:  &entities.Message{Chat: entities.Chat{Id: chatId}},
+	}
+}
+
+func TestDispatchKeepsTheOrderOfAChat(t *testing.T) {
+	var mu sync.Mutex
+	processed := map[int64][]int{}
+	d := NewDispatcher(context.Background(), Config{Workers: 3, QueueSize: 4}, func(ctx context.Context, update entities.Update) {
+		mu.Lock()
+		defer mu.Unlock()
+		chatId := ChatId(update)
+		processed[chatId] = append(processed[chatId], update.UpdateId)
+	})
+
+	const chats, updates = 5, 50
+	for i := 0; i < updates; i++ {
+		for chatId := int64(1); chatId <= chats; chatId++ {
+			if !d.Dispatch(messageUpdate(i, chatId)) {
+				t.Fatalf("dispatch of update %d of chat %d was rejected", i, chatId)
+			}
+		}
+	}
+	d.Stop()
+
+	for chatId := int64(1); chatId <= chats; chatId++ {
+		got := processed[chatId]
+		if len(got) != updates {
+			t.Fatalf("chat %d: processed %d updates, want %d", chatId, len(got), updates)
+		}
+		for i, updateId := range got {
+			if updateId != i {
+				t.Fatalf("chat %d: update %d processed at position %d", chatId, updateId, i)
+			}
+		}
+	}
+}
+
+func TestDispatchProcessesChatsConcurrently(t *testing.T) {
+	released := make(chan struct{})
+	d := NewDispatcher(context.Background(), Config{Workers: 2}, func(ctx context.Context, update entities.Update) {
+		switch ChatId(update) {
+		case 2:
+			// blocks the worker of chat 2 until chat 1 is processed.
+			select {
+			case <-released:
+			case <-time.After(5 * time.Second):
+				t.Error("chat 1 was not processed while chat 2 was in progress")
+			}
+		case 1:
+			close(released)
+		}
+	})
+
+	d.Dispatch(messageUpdate(1, 2))
+	d.Dispatch(messageUpdate(2, 1))
+	d.Stop()
+
+	select {
+	case <-released:
+	default:
+		t.Fatal("chat 1 was not processed")
+	}
+}
+
+func TestStopDrainsTheQueues(t *testing.T) {
+	var processed atomic.Int32
+	d := NewDispatcher(context.Background(), Config{Workers: 2, QueueSize: 16}, func(ctx context.Context, update entities.Update) {
+		time.Sleep(time.Millisecond)
+		processed.Add(1)
+	})
+
+	const updates = 20
+	for i := 0; i < updates; i++ {
+		d.Dispatch(messageUpdate(i, int64(i%4)))
+	}
+	d.Stop()
+
+	if got := processed.Load(); got != updates {
+		t.Fatalf("processed %d updates before stop returned, want %d", got, updates)
+	}
+	if d.Dispatch(messageUpdate(updates, 1)) {
+		t.Fatal("dispatch after stop was accepted")
+	}
+	d.Stop()
+}
+
+func TestUpdatesAreCancelledWithTheDispatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	var cancelled atomic.Bool
+	d := NewDispatcher(ctx, Config{}, func(ctx context.Context, update entities.Update) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			cancelled.Store(true)
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	d.Dispatch(messageUpdate(1, 1))
+	<-started
+	cancel()
+	d.Stop()
+
+	if !cancelled.Load() {
+		t.Fatal("the update was not cancelled with the dispatcher")
+	}
+}
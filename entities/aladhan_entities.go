@@ -6,6 +6,12 @@ type AladhanDateResponse struct {
 	Data   DateConversionData `json:"data"`
 }
 
+type AladhanCalendarResponse struct {
+	Code   int                  `json:"code"`
+	Status string               `json:"status"`
+	Data   []DateConversionData `json:"data"`
+}
+
 type DateConversionData struct {
 	Hijri     HijriDate     `json:"hijri"`
 	Gregorian GregorianDate `json:"gregorian"`
@@ -26,6 +26,13 @@ type Chat struct {
 	Photo     *ChatPhoto `json:"photo,omitempty"`
 }
 
+// ChatMember is a user in a group or channel. Status is one of "creator",
+// "administrator", "member", "restricted", "left" or "kicked".
+type ChatMember struct {
+	Status string `json:"status"`
+	User   User   `json:"user"`
+}
+
 type PhotoSize struct {
 	FileId       string `json:"file_id"`
 	FileUniqueId string `json:"file_unique_id"`
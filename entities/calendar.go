@@ -1,18 +1,66 @@
 package entities
 
 import (
+	"context"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	ptime "github.com/yaa110/go-persian-calendar"
 )
 
+const (
+	// maxOccurrenceYears bounds how far in the future NextOccurrences looks
+	// for patterns without a year.
+	maxOccurrenceYears = 10
+
+	// maxAladhanCalls bounds the requests a single NextOccurrences call of
+	// QamariCalendar sends to aladhan, so that a pattern that rarely fires
+	// does not walk years of months one request at a time.
+	maxAladhanCalls = 24
+	aladhanTimeout  = 10 * time.Second
+)
+
+var (
+	aladhanBaseURL = "https://api.aladhan.com/v1"
+	aladhanClient  = &http.Client{Timeout: aladhanTimeout}
+)
+
+// hijriMonths caches the Gregorian dates of the days of Hijri months by year
+// and month. they never change, so the cache is never invalidated.
+var hijriMonths = struct {
+	sync.Mutex
+	dates map[[2]int][]string
+}{dates: map[[2]int][]string{}}
+
+// errLookupLimit stops nextOccurrences once a calendar has used up the
+// lookups it may make. the occurrences found so far are returned.
+var errLookupLimit = errors.New("calendar lookup limit reached")
+
 type Calendar interface {
 	Type() CalendarType
 	GetToday() CalendarTime
+
+	// NextOccurrences returns at most count fire times of the pattern that
+	// are not before from. patterns that never fire, e.g. day 31 of a 30-day
+	// month, yield no occurrence for that month.
+	NextOccurrences(
+		ctx context.Context,
+		pattern CalendarTime,
+		from time.Time,
+		count int,
+	) ([]Occurrence, error)
+}
+
+// Occurrence is a single fire time of a schedule, both in the schedule's
+// calendar and as a Gregorian time.
+type Occurrence struct {
+	Date CalendarTime
+	Time time.Time
 }
 
 type GeorgianCalendar struct{}
@@ -32,6 +80,37 @@ func (g GeorgianCalendar) GetToday() CalendarTime {
 	}
 }
 
+func (g GeorgianCalendar) NextOccurrences(
+	ctx context.Context,
+	pattern CalendarTime,
+	from time.Time,
+	count int,
+) ([]Occurrence, error) {
+	today := CalendarTime{Year: from.Year(), Month: int(from.Month())}
+	return nextOccurrences(
+		GeorgianCalendarType,
+		pattern,
+		today,
+		from,
+		count,
+		func(year, month int) (func(day, hour, minute int) (time.Time, bool), error) {
+			return func(day, hour, minute int) (time.Time, bool) {
+				t := time.Date(
+					year,
+					time.Month(month),
+					day,
+					hour,
+					minute,
+					0,
+					0,
+					from.Location(),
+				)
+				return t, t.Month() == time.Month(month) && t.Day() == day
+			}, nil
+		},
+	)
+}
+
 type KhorshidiCalendar struct{}
 
 func (k KhorshidiCalendar) Type() CalendarType {
@@ -49,6 +128,38 @@ func (k KhorshidiCalendar) GetToday() CalendarTime {
 	}
 }
 
+func (k KhorshidiCalendar) NextOccurrences(
+	ctx context.Context,
+	pattern CalendarTime,
+	from time.Time,
+	count int,
+) ([]Occurrence, error) {
+	now := ptime.New(from)
+	today := CalendarTime{Year: now.Year(), Month: int(now.Month())}
+	return nextOccurrences(
+		KhorshidiCalendarType,
+		pattern,
+		today,
+		from,
+		count,
+		func(year, month int) (func(day, hour, minute int) (time.Time, bool), error) {
+			return func(day, hour, minute int) (time.Time, bool) {
+				t := ptime.Date(
+					year,
+					ptime.Month(month),
+					day,
+					hour,
+					minute,
+					0,
+					0,
+					from.Location(),
+				)
+				return t.Time(), int(t.Month()) == month && t.Day() == day
+			}, nil
+		},
+	)
+}
+
 type QamariCalendar struct{}
 
 func (q QamariCalendar) Type() CalendarType {
@@ -56,26 +167,227 @@ func (q QamariCalendar) Type() CalendarType {
 }
 
 func (q QamariCalendar) GetToday() CalendarTime {
-	res, err := http.Get("https://api.aladhan.com/v1/gToH")
-	if err != nil {
-		return CalendarTime{}
-	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return CalendarTime{}
-	}
-
 	var cTime CalendarTime
 	var resp AladhanDateResponse
-	err = json.Unmarshal(body, &resp)
-
+	err := getAladhan(context.Background(), aladhanBaseURL+"/gToH", &resp)
 	if err != nil {
 		return cTime
 	}
+
 	cTime.Year, _ = strconv.Atoi(resp.Data.Hijri.Year)
 	cTime.Month = resp.Data.Hijri.Month.Number
 	cTime.Day, _ = strconv.Atoi(resp.Data.Hijri.Day)
 	return cTime
 }
+
+// NextOccurrences asks aladhan for the Gregorian dates of each candidate
+// Hijri month, since the length of Qamari months is not known in advance.
+// months are cached, and at most maxAladhanCalls requests are sent, so fewer
+// than count occurrences may be returned for patterns that rarely fire.
+func (q QamariCalendar) NextOccurrences(
+	ctx context.Context,
+	pattern CalendarTime,
+	from time.Time,
+	count int,
+) ([]Occurrence, error) {
+	if pattern.Day > 30 {
+		// qamari months have 29 or 30 days.
+		return nil, nil
+	}
+
+	var dateResp AladhanDateResponse
+	err := getAladhan(
+		ctx,
+		fmt.Sprintf(
+			"%s/gToH/%s",
+			aladhanBaseURL,
+			from.Format("02-01-2006"),
+		),
+		&dateResp,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var today CalendarTime
+	today.Year, _ = strconv.Atoi(dateResp.Data.Hijri.Year)
+	today.Month = dateResp.Data.Hijri.Month.Number
+
+	calls := 1
+	return nextOccurrences(
+		QamariCalendarType,
+		pattern,
+		today,
+		from,
+		count,
+		func(year, month int) (func(day, hour, minute int) (time.Time, bool), error) {
+			dates, err := hijriMonthDates(ctx, year, month, &calls)
+			if err != nil {
+				return nil, err
+			}
+
+			return func(day, hour, minute int) (time.Time, bool) {
+				if day < 1 || day > len(dates) {
+					return time.Time{}, false
+				}
+
+				date, err := time.ParseInLocation(
+					"02-01-2006",
+					dates[day-1],
+					from.Location(),
+				)
+				if err != nil {
+					return time.Time{}, false
+				}
+
+				t := time.Date(
+					date.Year(),
+					date.Month(),
+					date.Day(),
+					hour,
+					minute,
+					0,
+					0,
+					from.Location(),
+				)
+				return t, t.Day() == date.Day()
+			}, nil
+		},
+	)
+}
+
+// hijriMonthDates returns the Gregorian dates of the days of the Hijri month,
+// from the cache or from aladhan. calls counts the requests sent to aladhan
+// and errLookupLimit is returned once it reaches maxAladhanCalls.
+func hijriMonthDates(ctx context.Context, year, month int, calls *int) ([]string, error) {
+	key := [2]int{year, month}
+	hijriMonths.Lock()
+	dates, ok := hijriMonths.dates[key]
+	hijriMonths.Unlock()
+	if ok {
+		return dates, nil
+	}
+
+	if *calls >= maxAladhanCalls {
+		return nil, errLookupLimit
+	}
+	*calls++
+
+	var calendarResp AladhanCalendarResponse
+	err := getAladhan(
+		ctx,
+		fmt.Sprintf(
+			"%s/hToGCalendar/%d/%d",
+			aladhanBaseURL,
+			month,
+			year,
+		),
+		&calendarResp,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	dates = make([]string, len(calendarResp.Data))
+	for i, day := range calendarResp.Data {
+		dates[i] = day.Gregorian.Date
+	}
+
+	hijriMonths.Lock()
+	hijriMonths.dates[key] = dates
+	hijriMonths.Unlock()
+	return dates, nil
+}
+
+// nextOccurrences walks the months of calendar starting from the month of
+// today. monthResolver returns a function that converts a day of the given
+// month to a Gregorian time and reports whether that day exists.
+func nextOccurrences(
+	calendarType CalendarType,
+	pattern CalendarTime,
+	today CalendarTime,
+	from time.Time,
+	count int,
+	monthResolver func(year, month int) (
+		func(day, hour, minute int) (time.Time, bool),
+		error,
+	),
+) ([]Occurrence, error) {
+	var occurrences []Occurrence
+	if pattern.Year != 0 && pattern.Year < today.Year {
+		return occurrences, nil
+	}
+
+	startYear := today.Year
+	endYear := today.Year + maxOccurrenceYears
+	if pattern.Year != 0 {
+		startYear = pattern.Year
+		endYear = pattern.Year
+	}
+
+	for year := startYear; year <= endYear; year++ {
+		for month := 1; month <= 12; month++ {
+			if pattern.Month != 0 && pattern.Month != month {
+				continue
+			}
+			if year == today.Year && month < today.Month {
+				continue
+			}
+
+			resolve, err := monthResolver(year, month)
+			if errors.Is(err, errLookupLimit) {
+				return occurrences, nil
+			}
+			if err != nil {
+				return occurrences, err
+			}
+
+			t, ok := resolve(pattern.Day, pattern.Hour, pattern.Minute)
+			if !ok || t.Hour() != pattern.Hour || t.Minute() != pattern.Minute {
+				continue
+			}
+			if t.Before(from) {
+				continue
+			}
+
+			occurrences = append(
+				occurrences,
+				Occurrence{
+					Date: CalendarTime{
+						Type:   calendarType,
+						Year:   year,
+						Month:  month,
+						Day:    pattern.Day,
+						Hour:   pattern.Hour,
+						Minute: pattern.Minute,
+					},
+					Time: t,
+				},
+			)
+			if len(occurrences) >= count {
+				return occurrences, nil
+			}
+		}
+	}
+
+	return occurrences, nil
+}
+
+func getAladhan(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := aladhanClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("aladhan responded with status %d", res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,297 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const occurrenceLayout = "2006-01-02 15:04"
+
+func occurrenceTimes(t *testing.T, occurrences []Occurrence) []string {
+	t.Helper()
+	var times []string
+	for _, occurrence := range occurrences {
+		if occurrence.Time.Second() != 0 || occurrence.Time.Nanosecond() != 0 {
+			t.Errorf("occurrence %v is not at the start of a minute", occurrence.Time)
+		}
+		times = append(times, occurrence.Time.Format(occurrenceLayout))
+	}
+	return times
+}
+
+func TestNextOccurrences(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar Calendar
+		pattern  CalendarTime
+		from     time.Time
+		count    int
+		want     []string
+	}{
+		{
+			name:     "georgian monthly skips short months",
+			calendar: GeorgianCalendar{},
+			pattern:  CalendarTime{Day: 31, Hour: 9},
+			from:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			count:    4,
+			want:     []string{"2026-01-31 09:00", "2026-03-31 09:00", "2026-05-31 09:00", "2026-07-31 09:00"},
+		},
+		{
+			name:     "georgian february 29 in leap years only",
+			calendar: GeorgianCalendar{},
+			pattern:  CalendarTime{Month: 2, Day: 29, Hour: 12, Minute: 30},
+			from:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			count:    2,
+			want:     []string{"2028-02-29 12:30", "2032-02-29 12:30"},
+		},
+		{
+			name:     "georgian february 30 never fires",
+			calendar: GeorgianCalendar{},
+			pattern:  CalendarTime{Month: 2, Day: 30},
+			from:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			count:    5,
+		},
+		{
+			name:     "georgian fire time equal to from is included",
+			calendar: GeorgianCalendar{},
+			pattern:  CalendarTime{Day: 1, Hour: 10},
+			from:     time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC),
+			count:    2,
+			want:     []string{"2026-05-01 10:00", "2026-06-01 10:00"},
+		},
+		{
+			name:     "georgian fire time earlier in the minute of from is skipped",
+			calendar: GeorgianCalendar{},
+			pattern:  CalendarTime{Day: 1, Hour: 10},
+			from:     time.Date(2026, 5, 1, 10, 0, 30, 0, time.UTC),
+			count:    1,
+			want:     []string{"2026-06-01 10:00"},
+		},
+		{
+			name:     "georgian fixed year",
+			calendar: GeorgianCalendar{},
+			pattern:  CalendarTime{Year: 2027, Day: 15},
+			from:     time.Date(2026, 11, 20, 0, 0, 0, 0, time.UTC),
+			count:    2,
+			want:     []string{"2027-01-15 00:00", "2027-02-15 00:00"},
+		},
+		{
+			name:     "georgian past year",
+			calendar: GeorgianCalendar{},
+			pattern:  CalendarTime{Year: 2020, Day: 15},
+			from:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			count:    2,
+		},
+		{
+			name:     "khorshidi monthly skips 30 day months",
+			calendar: KhorshidiCalendar{},
+			pattern:  CalendarTime{Day: 31, Hour: 9},
+			// 1404/05/01
+			from:  time.Date(2025, 7, 23, 0, 0, 0, 0, time.UTC),
+			count: 3,
+			want:  []string{"2025-08-22 09:00", "2025-09-22 09:00", "2026-04-20 09:00"},
+		},
+		{
+			name:     "khorshidi esfand 30 in leap years only",
+			calendar: KhorshidiCalendar{},
+			pattern:  CalendarTime{Month: 12, Day: 30, Hour: 9},
+			// 1404/01/01
+			from:  time.Date(2025, 3, 21, 0, 0, 0, 0, time.UTC),
+			count: 3,
+			// the third one is beyond the horizon.
+			want: []string{"2030-03-20 09:00", "2034-03-20 09:00"},
+		},
+		{
+			name:     "khorshidi first of farvardin",
+			calendar: KhorshidiCalendar{},
+			pattern:  CalendarTime{Month: 1, Day: 1, Hour: 8},
+			from:     time.Date(2025, 3, 21, 9, 0, 0, 0, time.UTC),
+			count:    1,
+			want:     []string{"2026-03-21 08:00"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			occurrences, err := test.calendar.NextOccurrences(
+				t.Context(),
+				test.pattern,
+				test.from,
+				test.count,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := occurrenceTimes(t, occurrences)
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("got occurrences %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNextOccurrencesHorizon(t *testing.T) {
+	from := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	occurrences, err := GeorgianCalendar{}.NextOccurrences(
+		t.Context(),
+		CalendarTime{Day: 1},
+		from,
+		1000,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// february to december of this year and every month of the years of
+	// the horizon.
+	want := 11 + 12*maxOccurrenceYears
+	if len(occurrences) != want {
+		t.Fatalf("got %d occurrences, want %d", len(occurrences), want)
+	}
+	last := occurrences[len(occurrences)-1].Time
+	if last.Year() != from.Year()+maxOccurrenceYears || last.Month() != time.December {
+		t.Fatalf("last occurrence is %v", last)
+	}
+}
+
+// fakeAladhan serves hijri year 1448 starting on 2026-06-16 with 30 day odd
+// months and 29 day even months, and counts the months it is asked for.
+func fakeAladhan(t *testing.T) *atomic.Int32 {
+	var monthRequests atomic.Int32
+	start := time.Date(2026, 6, 16, 0, 0, 0, 0, time.UTC)
+	monthStart := func(year, month int) time.Time {
+		days := 0
+		for y := 1448; y < year; y++ {
+			days += 354
+		}
+		for m := 1; m < month; m++ {
+			days += 30 - (m+1)%2
+		}
+		return start.AddDate(0, 0, days)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/gToH/"):
+			var response AladhanDateResponse
+			response.Data.Hijri.Year = "1448"
+			response.Data.Hijri.Month.Number = 1
+			json.NewEncoder(w).Encode(response)
+		case strings.HasPrefix(r.URL.Path, "/hToGCalendar/"):
+			monthRequests.Add(1)
+			var month, year int
+			_, err := fmt.Sscanf(r.URL.Path, "/hToGCalendar/%d/%d", &month, &year)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			var response AladhanCalendarResponse
+			first := monthStart(year, month)
+			for day := 0; day < 30-(month+1)%2; day++ {
+				var data DateConversionData
+				data.Gregorian.Date = first.AddDate(0, 0, day).Format("02-01-2006")
+				response.Data = append(response.Data, data)
+			}
+			json.NewEncoder(w).Encode(response)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	baseURL := aladhanBaseURL
+	aladhanBaseURL = server.URL
+	hijriMonths.Lock()
+	cached := hijriMonths.dates
+	hijriMonths.dates = map[[2]int][]string{}
+	hijriMonths.Unlock()
+	t.Cleanup(func() {
+		server.Close()
+		aladhanBaseURL = baseURL
+		hijriMonths.Lock()
+		hijriMonths.dates = cached
+		hijriMonths.Unlock()
+	})
+	return &monthRequests
+}
+
+func TestQamariNextOccurrences(t *testing.T) {
+	from := time.Date(2026, 6, 16, 0, 0, 0, 0, time.UTC)
+
+	t.Run("monthly", func(t *testing.T) {
+		monthRequests := fakeAladhan(t)
+		pattern := CalendarTime{Day: 1, Hour: 10}
+		want := []string{"2026-06-16 10:00", "2026-07-16 10:00", "2026-08-14 10:00"}
+		for range 2 {
+			occurrences, err := QamariCalendar{}.NextOccurrences(t.Context(), pattern, from, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := occurrenceTimes(t, occurrences)
+			if !slices.Equal(got, want) {
+				t.Fatalf("got occurrences %v, want %v", got, want)
+			}
+		}
+		// the months of the second call come from the cache.
+		if got := monthRequests.Load(); got != 3 {
+			t.Fatalf("asked aladhan for %d months, want 3", got)
+		}
+	})
+
+	t.Run("day 30 skips 29 day months", func(t *testing.T) {
+		fakeAladhan(t)
+		occurrences, err := QamariCalendar{}.NextOccurrences(
+			t.Context(),
+			CalendarTime{Day: 30},
+			from,
+			2,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := occurrenceTimes(t, occurrences)
+		want := []string{"2026-07-15 00:00", "2026-09-12 00:00"}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got occurrences %v, want %v", got, want)
+		}
+	})
+
+	t.Run("day after 30", func(t *testing.T) {
+		monthRequests := fakeAladhan(t)
+		occurrences, err := QamariCalendar{}.NextOccurrences(
+			t.Context(),
+			CalendarTime{Day: 31},
+			from,
+			2,
+		)
+		if err != nil || len(occurrences) != 0 {
+			t.Fatalf("got occurrences %v, %v, want none", occurrences, err)
+		}
+		if got := monthRequests.Load(); got != 0 {
+			t.Fatalf("asked aladhan for %d months, want none", got)
+		}
+	})
+
+	t.Run("lookups are bounded", func(t *testing.T) {
+		monthRequests := fakeAladhan(t)
+		// even months never have a day 30.
+		occurrences, err := QamariCalendar{}.NextOccurrences(
+			t.Context(),
+			CalendarTime{Month: 2, Day: 30},
+			from,
+			1,
+		)
+		if err != nil || len(occurrences) != 0 {
+			t.Fatalf("got occurrences %v, %v, want none", occurrences, err)
+		}
+		if got := monthRequests.Load(); got > maxAladhanCalls {
+			t.Fatalf("asked aladhan for %d months, want at most %d", got, maxAladhanCalls)
+		}
+	})
+}
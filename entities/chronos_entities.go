@@ -1,20 +1,153 @@
 package entities
 
 type FeatureFlag struct {
-	Name     string
-	OwnerId  int
+	WorkspaceId int
+	Name        string
+	OwnerId     int
+	Paused      bool
+	UnixTime    int64
+	// DeletedAt is the unix time the feature flag was moved to the trash,
+	// or zero if it is not deleted.
+	DeletedAt int64
+}
+
+type Role int
+
+const (
+	_ Role = iota
+	ViewerRole
+	EditorRole
+	OwnerRole
+)
+
+type FeatureFlagMember struct {
+	WorkspaceId     int
+	FeatureFlagName string
+	UserId          int
+	Role            Role
+	UnixTime        int64
+}
+
+type Workspace struct {
+	WorkspaceId int
+	Name        string
+	OwnerId     int
+	UnixTime    int64
+}
+
+type WorkspaceMember struct {
+	WorkspaceId int
+	UserId      int
+	Role        Role
+	UnixTime    int64
+}
+
+type BotUser struct {
+	UserId    int
+	UserName  string
+	FirstName string
+	UnixTime  int64
+}
+
+// Admin is an admin added from the bot.
+type Admin struct {
+	UserId   int
 	UnixTime int64
 }
 
+// InviteCode lets the user who redeems it use the bot when access is
+// restricted. UsedBy is zero until it is redeemed.
+type InviteCode struct {
+	Code      string
+	CreatorId int
+	UnixTime  int64
+	UsedBy    int
+	UsedAt    int64
+}
+
+// AllowedUser is a user let in by an invite code.
+type AllowedUser struct {
+	UserId     int
+	InviteCode string
+	UnixTime   int64
+}
+
+// Stats counts what the bot keeps, for the health report of admins.
+// schedules of deleted feature flags are not counted.
+type Stats struct {
+	FeatureFlags        int
+	DeletedFeatureFlags int
+	Schedules           int
+	PendingSchedules    int
+	Workspaces          int
+	BotUsers            int
+}
+
+// AuditLog records a change a user made. Before and After are json snapshots
+// of the changed entity, empty when it did not exist before or after the
+// change. FeatureFlagName is empty for changes that are not about a feature
+// flag.
+type AuditLog struct {
+	AuditId         int
+	ActorId         int
+	WorkspaceId     int
+	FeatureFlagName string
+	Entity          AuditEntity
+	Action          AuditAction
+	Before          string
+	After           string
+	UnixTime        int64
+}
+
+// SystemActorId is the actor of the changes the bot makes on its own, like
+// purging the trash.
+const SystemActorId = 0
+
+type AuditEntity string
+
+const (
+	FeatureFlagAuditEntity       AuditEntity = "feature_flag"
+	ScheduleAuditEntity          AuditEntity = "schedule"
+	FeatureFlagMemberAuditEntity AuditEntity = "feature_flag_member"
+	WorkspaceAuditEntity         AuditEntity = "workspace"
+	WorkspaceMemberAuditEntity   AuditEntity = "workspace_member"
+	SchedulingAuditEntity        AuditEntity = "scheduling"
+	AdminAuditEntity             AuditEntity = "admin"
+	AccessAuditEntity            AuditEntity = "access"
+)
+
+type AuditAction string
+
+const (
+	CreateAuditAction AuditAction = "create"
+	UpdateAuditAction AuditAction = "update"
+	DeleteAuditAction AuditAction = "delete"
+)
+
 type Schedule struct {
 	ScheduleId      int
+	WorkspaceId     int
 	FeatureFlagName string
 	Value           string
 	UsersList       string
 	Calendar        CalendarTime
+	Paused          bool
 	UnixTime        int64
+	// Approval is whether the schedule may run. only approved schedules are
+	// launched.
+	Approval  Approval
+	CreatorId int
 }
 
+type Approval int
+
+const (
+	_ Approval = iota
+	PendingApproval
+	ApprovedApproval
+	RejectedApproval
+)
+
 type State int
 
 const (
@@ -31,8 +164,24 @@ const (
 	ChooseFeatureFlagState
 	ChooseCalendarTypeState
 	GetScheduleState
+	ConfirmSchedulePatternState
 	GetValueState
 	GetUserListState
+	ConfirmScheduleConflictState
+
+	// pause and resume
+	ManageFeatureFlagState
+
+	// sharing feature flag
+	GetInviteeState
+	GetNewOwnerState
+
+	// workspaces
+	GetWorkspaceNameState
+	GetWorkspaceMemberState
+
+	// add feature flag with its first schedule
+	AddFeatureFlagWithScheduleState
 )
 
 type UserState struct {
@@ -40,6 +189,14 @@ type UserState struct {
 
 	// for scheduler state
 	Schedule *Schedule
+	// NewFeatureFlag is set if the feature flag of Schedule is created
+	// along with the schedule.
+	NewFeatureFlag bool
+
+	// for sharing feature flag states
+	WorkspaceId     int
+	FeatureFlagName string
+	Role            Role
 }
 
 type CalendarType int
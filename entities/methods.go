@@ -1,10 +1,20 @@
 package entities
 
-import "net/http"
+import "io"
 
-type MethodResponse struct {
-	Response *http.Response
-	Err      error
+// Response is the envelope of every bot api response. Result is set when Ok
+// is true, the error fields otherwise.
+type Response[T any] struct {
+	Ok          bool                `json:"ok"`
+	Result      T                   `json:"result"`
+	ErrorCode   int                 `json:"error_code,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Parameters  *ResponseParameters `json:"parameters,omitempty"`
+}
+
+type ResponseParameters struct {
+	MigrateToChatId int64 `json:"migrate_to_chat_id,omitempty"`
+	RetryAfter      int   `json:"retry_after,omitempty"`
 }
 
 type RequestSendMessage struct {
@@ -16,10 +26,96 @@ type RequestSendMessage struct {
 
 type ResponseSendMessage = Message
 
+type RequestEditMessageText struct {
+	ChatId      string       `json:"chat_id"`
+	MessageId   int          `json:"message_id"`
+	Text        string       `json:"text"`
+	ReplyMarkup *ReplyMarkup `json:"reply_markup,omitempty"`
+}
+
+type ResponseEditMessageText = Message
+
+type RequestEditMessageReplyMarkup struct {
+	ChatId      string       `json:"chat_id"`
+	MessageId   int          `json:"message_id"`
+	ReplyMarkup *ReplyMarkup `json:"reply_markup,omitempty"`
+}
+
+type ResponseEditMessageReplyMarkup = Message
+
+type RequestAnswerCallbackQuery struct {
+	CallbackQueryId string `json:"callback_query_id"`
+	Text            string `json:"text,omitempty"`
+	ShowAlert       bool   `json:"show_alert,omitempty"`
+}
+
+type ResponseAnswerCallbackQuery = bool
+
+type RequestDeleteMessage struct {
+	ChatId    string `json:"chat_id"`
+	MessageId int    `json:"message_id"`
+}
+
+type ResponseDeleteMessage = bool
+
+// RequestSendDocument is sent as multipart form data since the document is
+// uploaded with the request.
+type RequestSendDocument struct {
+	ChatId   string
+	FileName string
+	Document io.Reader
+	Caption  string
+}
+
+type ResponseSendDocument = Message
+
+type RequestGetMe struct {
+}
+
+type ResponseGetMe = User
+
+type RequestGetChat struct {
+	ChatId string `json:"chat_id"`
+}
+
+type ResponseGetChat = Chat
+
+type RequestGetChatMember struct {
+	ChatId string `json:"chat_id"`
+	UserId int    `json:"user_id"`
+}
+
+type ResponseGetChatMember = ChatMember
+
 type RequestGetUpdates struct {
+	Offset  int `json:"offset,omitempty"`
+	Limit   int `json:"limit,omitempty"`
+	Timeout int `json:"timeout,omitempty"`
+}
+
+type ResponseGetUpdates = []Update
+
+type RequestSetWebhook struct {
+	Url         string `json:"url"`
+	SecretToken string `json:"secret_token,omitempty"`
 }
 
-type ResponseGetUpdates struct {
-	Ok     bool     `json:"ok"`
-	Result []Update `json:"result"`
+type ResponseSetWebhook = bool
+
+type RequestDeleteWebhook struct {
+	DropPendingUpdates bool `json:"drop_pending_updates,omitempty"`
 }
+
+type ResponseDeleteWebhook = ResponseSetWebhook
+
+type RequestGetWebhookInfo struct {
+}
+
+type WebhookInfo struct {
+	Url                string `json:"url"`
+	PendingUpdateCount int    `json:"pending_update_count"`
+	LastErrorDate      int    `json:"last_error_date,omitempty"`
+	LastErrorMessage   string `json:"last_error_message,omitempty"`
+}
+
+type ResponseGetWebhookInfo = WebhookInfo
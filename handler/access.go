@@ -0,0 +1,463 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/repository"
+)
+
+const (
+	// DefaultMembershipTTL is how long the group membership of a user is
+	// trusted before it is checked again.
+	DefaultMembershipTTL = 10 * time.Minute
+	// membershipFailureTTL is how long a failed membership check denies
+	// access before bale is asked again.
+	membershipFailureTTL = time.Minute
+	// deniedReportInterval is how often the denied attempts of a user are
+	// reported to the log channel.
+	deniedReportInterval = time.Hour
+)
+
+// AccessConfig restricts who can use the bot. when Restricted is set, only
+// admins, Users, users who redeemed an invite code and the members of Group
+// can use it.
+type AccessConfig struct {
+	Restricted bool
+	Users      []int
+	// Group is the chat id of a group whose members can use the bot.
+	Group         string
+	MembershipTTL time.Duration
+}
+
+type membership struct {
+	member    bool
+	failed    bool
+	checkedAt time.Time
+}
+
+// accessCache keeps the group memberships of users and the last time their
+// denied attempts were reported, so that neither bale nor the log channel
+// is hit on every update.
+type accessCache struct {
+	mu          sync.Mutex
+	memberships map[int]membership
+	reportedAt  map[int]time.Time
+}
+
+func newAccessCache() *accessCache {
+	return &accessCache{
+		memberships: map[int]membership{},
+		reportedAt:  map[int]time.Time{},
+	}
+}
+
+// HasAccess reports whether the user may use the bot. errors are logged and
+// deny access.
+func (h *HttpHandler) HasAccess(ctx context.Context, userId int) bool {
+	if !h.access.Restricted ||
+		slices.Contains(h.access.Users, userId) ||
+		h.IsAdmin(ctx, userId) {
+		return true
+	}
+
+	allowed, err := h.db.IsAllowedUser(ctx, userId)
+	if err != nil {
+		slog.Error(
+			"error checking if user is allowed",
+			slog.Int("userId", userId),
+			slog.Any("error", err),
+		)
+	}
+	if allowed {
+		return true
+	}
+
+	return h.IsGroupMember(ctx, userId)
+}
+
+// IsGroupMember reports whether the user is in the group of the access
+// config, caching the answer of bale for the membership ttl. a failure is
+// cached for a shorter time so that an outage of bale is not asked about
+// on every update.
+func (h *HttpHandler) IsGroupMember(ctx context.Context, userId int) bool {
+	if h.access.Group == "" {
+		return false
+	}
+
+	ttl := h.access.MembershipTTL
+	if ttl <= 0 {
+		ttl = DefaultMembershipTTL
+	}
+
+	h.accessCache.mu.Lock()
+	cached, ok := h.accessCache.memberships[userId]
+	h.accessCache.mu.Unlock()
+	if ok && cached.failed {
+		ttl = membershipFailureTTL
+	}
+	if ok && time.Since(cached.checkedAt) < ttl {
+		return cached.member
+	}
+
+	chatMember, err := h.api.GetChatMember(ctx, h.access.Group, userId)
+	if err != nil {
+		slog.Error(
+			"error getting group membership",
+			slog.Int("userId", userId),
+			slog.String("group", h.access.Group),
+			slog.Any("error", err),
+		)
+		h.accessCache.mu.Lock()
+		h.accessCache.memberships[userId] = membership{failed: true, checkedAt: time.Now()}
+		h.accessCache.mu.Unlock()
+		return false
+	}
+
+	member := chatMember.Status == "creator" ||
+		chatMember.Status == "administrator" ||
+		chatMember.Status == "member"
+	h.accessCache.mu.Lock()
+	h.accessCache.memberships[userId] = membership{member: member, checkedAt: time.Now()}
+	h.accessCache.mu.Unlock()
+	return member
+}
+
+// CheckMessageAccess reports whether the message of user may be handled. a
+// user without access is let in by sending "/start <invite code>", and is
+// refused otherwise.
+func (h *HttpHandler) CheckMessageAccess(
+	ctx context.Context,
+	updateId int,
+	user entities.User,
+	text *string,
+) bool {
+	if user.IsBot {
+		return false
+	}
+	if h.HasAccess(ctx, user.Id) {
+		return true
+	}
+
+	code, ok := inviteCodeOfStart(text)
+	if !ok {
+		h.DenyAccess(ctx, updateId, user, "بدون دسترسی")
+		return false
+	}
+
+	err := withAudit(
+		ctx,
+		h.db,
+		&entities.AuditLog{
+			ActorId: user.Id,
+			Entity:  entities.AccessAuditEntity,
+		},
+		allowedUserSnapshot(ctx, user.Id),
+		func(repo repository.Repository) error {
+			return repo.RedeemInviteCode(ctx, code, user.Id)
+		},
+	)
+	if errors.Is(err, repository.ErrInviteCodeInvalid) {
+		h.DenyAccess(ctx, updateId, user, "کد دعوت نامعتبر")
+		return false
+	}
+	if err != nil {
+		slog.Error(
+			"error redeeming invite code",
+			slog.Int("updateId", updateId),
+			slog.Int("userId", user.Id),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, user.Id)
+		return false
+	}
+
+	slog.Info(
+		"invite code redeemed",
+		slog.Int("updateId", updateId),
+		slog.Int("userId", user.Id),
+	)
+	return true
+}
+
+// DenyAccess refuses the user and reports the attempt to the log channel,
+// at most once per deniedReportInterval for each user.
+func (h *HttpHandler) DenyAccess(
+	ctx context.Context,
+	updateId int,
+	user entities.User,
+	reason string,
+) {
+	slog.Info(
+		"access denied",
+		slog.Int("updateId", updateId),
+		slog.Int("userId", user.Id),
+		slog.String("reason", reason),
+	)
+	h.api.SendMessage(
+		ctx,
+		fmt.Sprint(user.Id),
+		"متاسفانه شما اجازه‌ی استفاده از این ربات را ندارید. اگر کد دعوت دارید، آن را به صورت /start <کد دعوت> بفرستید.",
+		nil,
+	)
+
+	if h.logChannel == "" {
+		return
+	}
+
+	h.accessCache.mu.Lock()
+	reportedAt, reported := h.accessCache.reportedAt[user.Id]
+	report := !reported || time.Since(reportedAt) >= deniedReportInterval
+	if report {
+		h.accessCache.reportedAt[user.Id] = time.Now()
+	}
+	h.accessCache.mu.Unlock()
+	if !report {
+		return
+	}
+
+	userName := "-"
+	if user.UserName != nil {
+		userName = "@" + *user.UserName
+	}
+	_, err := h.api.SendMessage(
+		ctx,
+		h.logChannel,
+		fmt.Sprintf(
+			"دسترسی رد شد: کاربر %d (%s %s) | %s",
+			user.Id,
+			user.FirstName,
+			userName,
+			reason,
+		),
+		nil,
+	)
+	if err != nil {
+		slog.Error(
+			"error reporting denied access",
+			slog.Int("updateId", updateId),
+			slog.Int("userId", user.Id),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// inviteCodeOfStart returns the invite code of a "/start <code>" message,
+// which is what bale sends for a start link with a payload.
+func inviteCodeOfStart(text *string) (string, bool) {
+	if text == nil {
+		return "", false
+	}
+
+	fields := strings.Fields(*text)
+	if len(fields) != 2 || fields[0] != "/start" {
+		return "", false
+	}
+	return fields[1], true
+}
+
+func allowedUserSnapshot(ctx context.Context, userId int) auditSnapshot {
+	return func(repo repository.Repository) (any, error) {
+		allowedUsers, err := repo.GetAllowedUsers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, allowedUser := range allowedUsers {
+			if allowedUser.UserId == userId {
+				return allowedUser, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+func newInviteCode() string {
+	code := make([]byte, 8)
+	rand.Read(code)
+	return hex.EncodeToString(code)
+}
+
+// HandleInviteCodeCommand creates a single use invite code.
+func (h *HttpHandler) HandleInviteCodeCommand(ctx context.Context, updateId, chatId int) {
+	code := newInviteCode()
+	err := h.db.AddInviteCode(ctx, code, chatId)
+	if err != nil {
+		slog.Error(
+			"error adding invite code",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		fmt.Sprintf(
+			"کد دعوت یک بار مصرف:\n%s\nکاربر برای ورود باید /start %s را به ربات بفرستد.",
+			code,
+			code,
+		),
+		nil,
+	)
+}
+
+// HandleSendAllowedUsers lists the users of the allowlist of the config and
+// those let in by invite codes.
+func (h *HttpHandler) HandleSendAllowedUsers(ctx context.Context, updateId, chatId int) {
+	allowedUsers, err := h.db.GetAllowedUsers(ctx)
+	if err != nil {
+		slog.Error(
+			"error getting allowed users",
+			slog.Int("updateId", updateId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	var text strings.Builder
+	if !h.access.Restricted {
+		text.WriteString("دسترسی به ربات محدود نیست.\n\n")
+	}
+	if h.access.Group != "" {
+		fmt.Fprintf(&text, "اعضای گروه %s دسترسی دارند.\n\n", h.access.Group)
+	}
+	text.WriteString("کاربران تنظیمات ربات:\n")
+	if len(h.access.Users) == 0 {
+		text.WriteString("-\n")
+	}
+	for _, userId := range h.access.Users {
+		fmt.Fprintf(&text, "%d\n", userId)
+	}
+	text.WriteString("\nکاربران دعوت شده:\n")
+	if len(allowedUsers) == 0 {
+		text.WriteString("-")
+	}
+	for _, allowedUser := range allowedUsers {
+		fmt.Fprintf(
+			&text,
+			"%d با کد %s از %s\n",
+			allowedUser.UserId,
+			allowedUser.InviteCode,
+			time.Unix(allowedUser.UnixTime, 0).Format("2006-01-02 15:04"),
+		)
+	}
+
+	h.api.SendMessage(ctx, fmt.Sprint(chatId), text.String(), nil)
+}
+
+// HandleRevokeAccessCommand takes away the access a user got with an invite
+// code. the command is "/revoke <id>".
+func (h *HttpHandler) HandleRevokeAccessCommand(
+	ctx context.Context,
+	updateId, chatId int,
+	args []string,
+) {
+	if len(args) != 1 {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"برای گرفتن دسترسی یک کاربر /revoke <شناسه> را بفرستید.",
+			nil,
+		)
+		return
+	}
+
+	userId, err := strconv.Atoi(args[0])
+	if err != nil {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"شناسه کاربر باید یک عدد باشد.",
+			nil,
+		)
+		return
+	}
+
+	allowed, err := h.db.IsAllowedUser(ctx, userId)
+	if err == nil && !allowed {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			fmt.Sprintf("کاربر %d با کد دعوت دسترسی نگرفته است.", userId),
+			nil,
+		)
+		return
+	}
+	if err == nil {
+		err = withAudit(
+			ctx,
+			h.db,
+			&entities.AuditLog{
+				ActorId: chatId,
+				Entity:  entities.AccessAuditEntity,
+			},
+			allowedUserSnapshot(ctx, userId),
+			func(repo repository.Repository) error {
+				return repo.RemoveAllowedUser(ctx, userId)
+			},
+		)
+	}
+	if err != nil {
+		slog.Error(
+			"error removing allowed user",
+			slog.Int("updateId", updateId),
+			slog.Int("userId", userId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	text := fmt.Sprintf("دسترسی کاربر %d گرفته شد.", userId)
+	if slices.Contains(h.access.Users, userId) {
+		text += " این کاربر در تنظیمات ربات هم دسترسی دارد."
+	}
+	text += h.OwnedFeatureFlagsText(ctx, updateId, userId)
+	h.api.SendMessage(ctx, fmt.Sprint(chatId), text, nil)
+}
+
+// OwnedFeatureFlagsText tells an admin which feature flags of the user
+// remain after the access of the user is revoked, and how to delete them.
+func (h *HttpHandler) OwnedFeatureFlagsText(
+	ctx context.Context,
+	updateId, userId int,
+) string {
+	featureFlags, err := h.db.GetFeatureFlagsByOwnerId(ctx, userId)
+	if err != nil {
+		slog.Error(
+			"error getting feature flags of revoked user",
+			slog.Int("updateId", updateId),
+			slog.Int("userId", userId),
+			slog.Any("error", err),
+		)
+		return "\nپرچم‌های ثبت‌شده‌ی این کاربر باقی می‌مانند. آن‌ها را با /flags ببینید."
+	}
+
+	if len(featureFlags) == 0 {
+		return ""
+	}
+
+	var owned []string
+	for _, featureFlag := range featureFlags {
+		owned = append(owned, fmt.Sprintf("%d %s", featureFlag.WorkspaceId, featureFlag.Name))
+	}
+
+	return fmt.Sprintf(
+		"\nپرچم‌های ثبت‌شده‌ی این کاربر باقی می‌مانند و برنامه‌های زمانی آن‌ها اجرا می‌شوند:\n%s\nبرای حذف هر کدام /forcedelete <شناسه فضای کاری> <نام پرچم> را بفرستید.",
+		strings.Join(owned, "\n"),
+	)
+}
@@ -0,0 +1,538 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/pkg/utils"
+	"github.com/fatemehkarimi/chronos_bot/repository"
+)
+
+const adminHelpText = `دستورهای مدیران ربات:
+/admins فهرست مدیران
+/admin add <شناسه> افزودن مدیر
+/admin remove <شناسه> حذف مدیر
+/flags همه‌ی پرچم‌ها و مالک آن‌ها
+/forcedelete <شناسه فضای کاری> <نام پرچم> حذف کامل یک پرچم
+/pauseall توقف همه‌ی برنامه‌های زمانی
+/resumeall ادامه‌ی همه‌ی برنامه‌های زمانی
+/health وضعیت ربات
+/broadcast <متن> ارسال پیام به همه‌ی کاربران
+/invitecode ساخت کد دعوت یک بار مصرف
+/allowed کاربران دارای دسترسی
+/revoke <شناسه> گرفتن دسترسی کاربر دعوت شده
+/audit تغییرات یک پرچم یا کاربر`
+
+// IsAdmin reports whether the user is an admin of the bot, either in the
+// config or in the repository.
+func (h *HttpHandler) IsAdmin(ctx context.Context, chatId int) bool {
+	if h.admins[chatId] {
+		return true
+	}
+
+	admin, err := h.db.IsAdmin(ctx, chatId)
+	if err != nil {
+		slog.Error(
+			"error checking if user is admin",
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		return false
+	}
+	return admin
+}
+
+func (h *HttpHandler) MainReplyMarkup(
+	ctx context.Context,
+	chatId int,
+) entities.ReplyMarkup {
+	if !h.IsAdmin(ctx, chatId) {
+		return utils.GetMainReplyMarkup()
+	}
+
+	schedulingPaused, err := h.db.IsSchedulingPaused(ctx)
+	if err != nil {
+		slog.Error(
+			"error getting scheduling paused status",
+			slog.Any("error", err),
+		)
+		return utils.GetMainReplyMarkup()
+	}
+	return utils.GetAdminMainReplyMarkup(schedulingPaused)
+}
+
+func adminSnapshot(ctx context.Context, userId int) auditSnapshot {
+	return func(repo repository.Repository) (any, error) {
+		admins, err := repo.GetAdmins(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, admin := range admins {
+			if admin.UserId == userId {
+				return admin, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// HandleAdminCommand answers the commands of admins. it reports whether text
+// is an admin command, so that other messages are handled as usual.
+func (h *HttpHandler) HandleAdminCommand(
+	ctx context.Context,
+	updateId, chatId int,
+	text string,
+) bool {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return false
+	}
+
+	command := fields[0]
+	switch command {
+	case "/admin", "/admins", "/flags", "/forcedelete", "/pauseall",
+		"/resumeall", "/health", "/broadcast", "/audit", "/invitecode",
+		"/allowed", "/revoke":
+	default:
+		return false
+	}
+
+	if !h.IsAdmin(ctx, chatId) {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"این دستور فقط برای مدیران ربات است.",
+			nil,
+		)
+		return true
+	}
+
+	switch command {
+	case "/admin":
+		h.HandleAdminMembersCommand(ctx, updateId, chatId, fields[1:])
+	case "/admins":
+		h.HandleSendAdmins(ctx, updateId, chatId)
+	case "/flags":
+		h.HandleSendAllFeatureFlags(ctx, updateId, chatId)
+	case "/forcedelete":
+		h.HandleForceDeleteCommand(ctx, updateId, chatId, fields[1:])
+	case "/pauseall":
+		h.HandleSetSchedulingPaused(ctx, updateId, chatId, true)
+	case "/resumeall":
+		h.HandleSetSchedulingPaused(ctx, updateId, chatId, false)
+	case "/health":
+		h.HandleHealthCommand(ctx, updateId, chatId)
+	case "/broadcast":
+		h.HandleBroadcastCommand(
+			ctx,
+			updateId,
+			chatId,
+			strings.TrimSpace(strings.TrimPrefix(text, command)),
+		)
+	case "/audit":
+		h.HandleAuditCommand(ctx, updateId, chatId, text)
+	case "/invitecode":
+		h.HandleInviteCodeCommand(ctx, updateId, chatId)
+	case "/allowed":
+		h.HandleSendAllowedUsers(ctx, updateId, chatId)
+	case "/revoke":
+		h.HandleRevokeAccessCommand(ctx, updateId, chatId, fields[1:])
+	}
+	return true
+}
+
+// HandleAdminMembersCommand adds or removes an admin of the repository with
+// "/admin add <id>" or "/admin remove <id>", and shows the admin commands
+// otherwise. admins of the config cannot be removed.
+func (h *HttpHandler) HandleAdminMembersCommand(
+	ctx context.Context,
+	updateId, chatId int,
+	args []string,
+) {
+	if len(args) != 2 || (args[0] != "add" && args[0] != "remove") {
+		h.api.SendMessage(ctx, fmt.Sprint(chatId), adminHelpText, nil)
+		return
+	}
+
+	userId, err := strconv.Atoi(args[1])
+	if err != nil || userId <= 0 {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"شناسه کاربر باید یک عدد باشد.",
+			nil,
+		)
+		return
+	}
+
+	add := args[0] == "add"
+	if !add && h.admins[userId] {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"این کاربر در تنظیمات ربات مدیر است و از اینجا حذف نمی‌شود.",
+			nil,
+		)
+		return
+	}
+
+	err = withAudit(
+		ctx,
+		h.db,
+		&entities.AuditLog{
+			ActorId: chatId,
+			Entity:  entities.AdminAuditEntity,
+		},
+		adminSnapshot(ctx, userId),
+		func(repo repository.Repository) error {
+			if add {
+				return repo.AddAdmin(ctx, userId)
+			}
+			return repo.RemoveAdmin(ctx, userId)
+		},
+	)
+	if err != nil {
+		slog.Error(
+			"error changing admins",
+			slog.Int("updateId", updateId),
+			slog.Int("userId", userId),
+			slog.Bool("add", add),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	text := fmt.Sprintf("کاربر %d از مدیران حذف شد.", userId)
+	if add {
+		text = fmt.Sprintf("کاربر %d به مدیران اضافه شد.", userId)
+	}
+	h.api.SendMessage(ctx, fmt.Sprint(chatId), text, nil)
+}
+
+func (h *HttpHandler) HandleSendAdmins(ctx context.Context, updateId, chatId int) {
+	admins, err := h.db.GetAdmins(ctx)
+	if err != nil {
+		slog.Error(
+			"error getting admins",
+			slog.Int("updateId", updateId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	configAdmins := make([]int, 0, len(h.admins))
+	for admin := range h.admins {
+		configAdmins = append(configAdmins, admin)
+	}
+	slices.Sort(configAdmins)
+
+	var text strings.Builder
+	text.WriteString("مدیران تنظیمات ربات:\n")
+	for _, admin := range configAdmins {
+		fmt.Fprintf(&text, "%d\n", admin)
+	}
+	text.WriteString("\nمدیران اضافه شده:\n")
+	if len(admins) == 0 {
+		text.WriteString("-")
+	}
+	for _, admin := range admins {
+		fmt.Fprintf(
+			&text,
+			"%d از %s\n",
+			admin.UserId,
+			time.Unix(admin.UnixTime, 0).Format("2006-01-02 15:04"),
+		)
+	}
+
+	h.api.SendMessage(ctx, fmt.Sprint(chatId), text.String(), nil)
+}
+
+// HandleSendAllFeatureFlags lists the feature flags of every workspace with
+// their owners.
+func (h *HttpHandler) HandleSendAllFeatureFlags(ctx context.Context, updateId, chatId int) {
+	featureFlags, err := h.db.GetAllFeatureFlags(ctx)
+	if err != nil {
+		slog.Error(
+			"error getting all feature flags",
+			slog.Int("updateId", updateId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	if len(featureFlags) == 0 {
+		h.api.SendMessage(ctx, fmt.Sprint(chatId), "هیچ پرچمی وجود ندارد.", nil)
+		return
+	}
+
+	for _, message := range utils.FeatureFlagsToMessages(featureFlags) {
+		_, err = h.api.SendMessage(ctx, fmt.Sprint(chatId), message, nil)
+		if err != nil {
+			slog.Error(
+				"error sending all feature flags. err = ",
+				slog.Int("updateId", updateId),
+				slog.Int("chatId", chatId),
+				slog.Int("count", len(featureFlags)),
+				slog.Any("err", err),
+			)
+			return
+		}
+	}
+}
+
+// HandleForceDeleteCommand deletes a feature flag of any workspace right
+// away, without moving it to the trash. the command is
+// "/forcedelete <workspace id> <name>".
+func (h *HttpHandler) HandleForceDeleteCommand(
+	ctx context.Context,
+	updateId, chatId int,
+	args []string,
+) {
+	if len(args) != 2 {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"برای حذف کامل یک پرچم /forcedelete <شناسه فضای کاری> <نام پرچم> را بفرستید.",
+			nil,
+		)
+		return
+	}
+
+	workspaceId, err := strconv.Atoi(args[0])
+	if err != nil {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"شناسه فضای کاری باید یک عدد باشد.",
+			nil,
+		)
+		return
+	}
+
+	featureFlagName := args[1]
+	err = withAudit(
+		ctx,
+		h.db,
+		&entities.AuditLog{
+			ActorId:         chatId,
+			WorkspaceId:     workspaceId,
+			FeatureFlagName: featureFlagName,
+			Entity:          entities.FeatureFlagAuditEntity,
+			// the feature flag cannot be read if it is in the trash.
+			Action: entities.DeleteAuditAction,
+		},
+		featureFlagSnapshot(ctx, workspaceId, featureFlagName),
+		func(repo repository.Repository) error {
+			return repo.PurgeFeatureFlag(ctx, workspaceId, featureFlagName)
+		},
+	)
+	if errors.Is(err, repository.ErrFlagNotFound) {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			fmt.Sprintf("پرچم %s در فضای کاری %d وجود ندارد.", featureFlagName, workspaceId),
+			nil,
+		)
+		return
+	}
+	if err != nil {
+		slog.Error(
+			"error purging feature flag",
+			slog.Int("updateId", updateId),
+			slog.Int("workspaceId", workspaceId),
+			slog.String("featureFlag", featureFlagName),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	h.scheduler.RelaunchToday()
+	h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		fmt.Sprintf("پرچم %s از فضای کاری %d به طور کامل حذف شد.", featureFlagName, workspaceId),
+		nil,
+	)
+}
+
+func (h *HttpHandler) HandleHealthCommand(ctx context.Context, updateId, chatId int) {
+	start := time.Now()
+	stats, err := h.db.GetStats(ctx)
+	dbLatency := time.Since(start)
+	if err != nil {
+		slog.Error(
+			"error getting stats",
+			slog.Int("updateId", updateId),
+			slog.Any("error", err),
+		)
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"پایگاه داده در دسترس نیست.",
+			nil,
+		)
+		return
+	}
+
+	schedulingPaused, err := h.db.IsSchedulingPaused(ctx)
+	if err != nil {
+		slog.Error(
+			"error getting scheduling paused status",
+			slog.Int("updateId", updateId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		utils.HealthToText(time.Since(h.startedAt), dbLatency, schedulingPaused, stats),
+		nil,
+	)
+}
+
+// broadcastProgressInterval is how many users a broadcast is sent to
+// between two updates of its progress.
+const broadcastProgressInterval = 50
+
+// HandleBroadcastCommand starts sending text to every user of the bot in the
+// background. the admin is shown the progress of it. only the users who
+// talked to this bot are messaged, and one broadcast of the bot runs at a
+// time.
+func (h *HttpHandler) HandleBroadcastCommand(
+	ctx context.Context,
+	updateId, chatId int,
+	text string,
+) {
+	if text == "" {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"برای ارسال پیام به همه‌ی کاربران /broadcast <متن> را بفرستید.",
+			nil,
+		)
+		return
+	}
+
+	if !h.broadcasting.CompareAndSwap(false, true) {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"ارسال پیام دیگری به همه‌ی کاربران در جریان است. پس از پایان آن دوباره تلاش کنید.",
+			nil,
+		)
+		return
+	}
+
+	userIds, err := h.db.GetBotUserIds(ctx, h.bot)
+	if err != nil {
+		h.broadcasting.Store(false)
+		slog.Error(
+			"error getting bot user ids",
+			slog.Int("updateId", updateId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	progress, err := h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		broadcastProgressText(0, len(userIds)),
+		nil,
+	)
+	if err != nil {
+		h.broadcasting.Store(false)
+		slog.Error(
+			"error sending broadcast progress",
+			slog.Int("updateId", updateId),
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	h.jobs.Add(1)
+	go func() {
+		defer h.jobs.Done()
+		defer h.broadcasting.Store(false)
+		h.Broadcast(updateId, chatId, progress.MessageId, text, userIds)
+	}()
+}
+
+// Broadcast sends text to userIds and keeps the progress message of the
+// admin up to date. it stops early on shutdown.
+func (h *HttpHandler) Broadcast(
+	updateId, chatId int,
+	progressMessageId int,
+	text string,
+	userIds []int,
+) {
+	ctx := h.ctx
+	sent := 0
+	for i, userId := range userIds {
+		if ctx.Err() != nil {
+			slog.Warn(
+				"broadcast stopped on shutdown",
+				slog.Int("updateId", updateId),
+				slog.Int("sent", sent),
+				slog.Int("users", len(userIds)),
+			)
+			return
+		}
+
+		_, err := h.api.SendMessage(ctx, fmt.Sprint(userId), text, nil)
+		if err != nil {
+			slog.Error(
+				"error sending broadcast",
+				slog.Int("updateId", updateId),
+				slog.Int("userId", userId),
+				slog.Any("error", err),
+			)
+		} else {
+			sent++
+		}
+
+		if (i+1)%broadcastProgressInterval == 0 && i+1 < len(userIds) {
+			h.api.EditMessageText(
+				ctx,
+				fmt.Sprint(chatId),
+				progressMessageId,
+				broadcastProgressText(i+1, len(userIds)),
+				nil,
+			)
+		}
+	}
+
+	slog.Info(
+		"broadcast sent",
+		slog.Int("updateId", updateId),
+		slog.Int("chatId", chatId),
+		slog.Int("sent", sent),
+		slog.Int("users", len(userIds)),
+	)
+	h.api.EditMessageText(
+		ctx,
+		fmt.Sprint(chatId),
+		progressMessageId,
+		fmt.Sprintf("پیام به %d کاربر از %d کاربر ارسال شد.", sent, len(userIds)),
+		nil,
+	)
+}
+
+func broadcastProgressText(done, users int) string {
+	return fmt.Sprintf("در حال ارسال پیام: %d کاربر از %d کاربر", done, users)
+}
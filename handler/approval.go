@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/pkg/utils"
+	"github.com/fatemehkarimi/chronos_bot/repository"
+)
+
+// ApprovalConfig configures the approval of schedules. when Required is set,
+// new schedules only run after one of Approvers other than their creator
+// approves them.
+type ApprovalConfig struct {
+	Required  bool
+	Approvers []int
+}
+
+func (h *HttpHandler) IsApprover(chatId int) bool {
+	return slices.Contains(h.approval.Approvers, chatId)
+}
+
+// NewScheduleApproval returns the approval new schedules are saved with.
+func (h *HttpHandler) NewScheduleApproval() entities.Approval {
+	if h.approval.Required {
+		return entities.PendingApproval
+	}
+	return entities.ApprovedApproval
+}
+
+// OnScheduleSaved launches a saved schedule, or asks for its approval if it
+// is pending.
+func (h *HttpHandler) OnScheduleSaved(
+	ctx context.Context,
+	updateId int,
+	schedule entities.Schedule,
+) {
+	if schedule.Approval != entities.PendingApproval {
+		h.scheduler.OnNewSchedule(schedule)
+		return
+	}
+
+	h.RequestScheduleApproval(ctx, updateId, schedule)
+}
+
+// RequestScheduleApproval sends the schedule to the approvers other than its
+// creator with buttons to approve or reject it.
+func (h *HttpHandler) RequestScheduleApproval(
+	ctx context.Context,
+	updateId int,
+	schedule entities.Schedule,
+) {
+	sent := false
+	for _, approver := range h.approval.Approvers {
+		if approver == schedule.CreatorId {
+			continue
+		}
+
+		_, err := h.api.SendMessage(
+			ctx,
+			fmt.Sprint(approver),
+			utils.ScheduleApprovalRequestToText(schedule),
+			utils.GetScheduleApprovalReplyMarkup(schedule.ScheduleId),
+		)
+		if err != nil {
+			slog.Error(
+				"error sending schedule approval request",
+				slog.Int("updateId", updateId),
+				slog.Int("scheduleId", schedule.ScheduleId),
+				slog.Int("approver", approver),
+				slog.Any("error", err),
+			)
+			continue
+		}
+		sent = true
+	}
+
+	if !sent {
+		slog.Error(
+			"no approver received the schedule approval request",
+			slog.Int("updateId", updateId),
+			slog.Int("scheduleId", schedule.ScheduleId),
+		)
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(schedule.CreatorId),
+			"درخواست تایید به هیچ تاییدکننده‌ای نرسید. لطفا با مدیر ربات تماس بگیرید.",
+			nil,
+		)
+	}
+}
+
+// HandleReviewSchedule approves or rejects a pending schedule. approvers
+// cannot review the schedules they created themselves.
+func (h *HttpHandler) HandleReviewSchedule(
+	ctx context.Context,
+	updateId, chatId int,
+	callbackData string,
+	prefix string,
+	approval entities.Approval,
+) {
+	if !h.IsApprover(chatId) {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"شما تاییدکننده‌ی برنامه‌های زمانی نیستید.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		return
+	}
+
+	scheduleId, err := utils.GetScheduleIdFromCallbackData(callbackData, prefix)
+	if err != nil {
+		slog.Error(
+			"invalid schedule callback data",
+			slog.String("data", callbackData),
+			slog.Any("error", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	schedule, err := h.db.GetScheduleById(ctx, scheduleId)
+	if err == nil && schedule.CreatorId == chatId {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"برنامه زمانی‌ای که خودتان ثبت کرده‌اید باید توسط شخص دیگری تایید شود.",
+			nil,
+		)
+		return
+	}
+	if err == nil {
+		err = withAudit(
+			ctx,
+			h.db,
+			&entities.AuditLog{
+				ActorId:         chatId,
+				WorkspaceId:     schedule.WorkspaceId,
+				FeatureFlagName: schedule.FeatureFlagName,
+				Entity:          entities.ScheduleAuditEntity,
+			},
+			scheduleSnapshot(ctx, &scheduleId),
+			func(repo repository.Repository) error {
+				return repo.ReviewSchedule(ctx, scheduleId, approval)
+			},
+		)
+	}
+	if errors.Is(err, repository.ErrScheduleNotFound) {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"این برنامه زمانی دیگر وجود ندارد.",
+			nil,
+		)
+		return
+	}
+	if errors.Is(err, repository.ErrScheduleReviewed) {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			fmt.Sprintf("برنامه زمانی %d قبلا بررسی شده است.", scheduleId),
+			nil,
+		)
+		return
+	}
+	if err != nil {
+		slog.Error(
+			"error reviewing schedule",
+			slog.Int("updateId", updateId),
+			slog.Int("scheduleId", scheduleId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	text := fmt.Sprintf(
+		"برنامه زمانی %d پرچم %s رد شد.",
+		scheduleId,
+		schedule.FeatureFlagName,
+	)
+	if approval == entities.ApprovedApproval {
+		text = fmt.Sprintf(
+			"برنامه زمانی %d پرچم %s تایید شد.",
+			scheduleId,
+			schedule.FeatureFlagName,
+		)
+		schedule.Approval = approval
+		h.scheduler.OnNewSchedule(*schedule)
+	}
+
+	h.api.SendMessage(ctx, fmt.Sprint(chatId), text, nil)
+	h.NotifyUser(ctx, updateId, chatId, schedule.CreatorId, text)
+}
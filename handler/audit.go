@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/pkg/utils"
+	"github.com/fatemehkarimi/chronos_bot/repository"
+)
+
+// auditLogLimit is the number of changes the /audit command shows.
+const auditLogLimit = 20
+
+// auditSnapshot reads the entity that a change is about. it returns nil if
+// the entity does not exist.
+type auditSnapshot func(repo repository.Repository) (any, error)
+
+// withAudit runs change in a transaction of repo and records it in the audit
+// log with snapshots of the entity taken before and after it. change may
+// fill in auditLog, e.g. with the id of the workspace it creates. the action
+// is derived from the snapshots unless auditLog already has one.
+func withAudit(
+	ctx context.Context,
+	repo repository.Repository,
+	auditLog *entities.AuditLog,
+	snapshot auditSnapshot,
+	change func(repo repository.Repository) error,
+) error {
+	return repo.WithTx(ctx, func(tx repository.Repository) error {
+		before, err := snapshotJson(tx, snapshot)
+		if err != nil {
+			return err
+		}
+
+		err = change(tx)
+		if err != nil {
+			return err
+		}
+
+		after, err := snapshotJson(tx, snapshot)
+		if err != nil {
+			return err
+		}
+
+		auditLog.Before = before
+		auditLog.After = after
+		switch {
+		case auditLog.Action != "":
+		case before == "":
+			auditLog.Action = entities.CreateAuditAction
+		case after == "":
+			auditLog.Action = entities.DeleteAuditAction
+		default:
+			auditLog.Action = entities.UpdateAuditAction
+		}
+		return tx.AddAuditLog(ctx, *auditLog)
+	})
+}
+
+func snapshotJson(repo repository.Repository, snapshot auditSnapshot) (string, error) {
+	entity, err := snapshot(repo)
+	if err != nil || entity == nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(entity)
+	return string(data), err
+}
+
+func featureFlagSnapshot(
+	ctx context.Context,
+	workspaceId int,
+	featureFlagName string,
+) auditSnapshot {
+	return func(repo repository.Repository) (any, error) {
+		featureFlag, err := repo.GetFeatureFlagByName(ctx, workspaceId, featureFlagName)
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, nil
+		}
+		return featureFlag, err
+	}
+}
+
+// scheduleSnapshot reads the schedule *scheduleId points to, so that it can
+// follow a schedule that the change creates.
+func scheduleSnapshot(ctx context.Context, scheduleId *int) auditSnapshot {
+	return func(repo repository.Repository) (any, error) {
+		schedule, err := repo.GetScheduleById(ctx, *scheduleId)
+		if errors.Is(err, repository.ErrScheduleNotFound) {
+			return nil, nil
+		}
+		return schedule, err
+	}
+}
+
+func featureFlagMemberSnapshot(
+	ctx context.Context,
+	workspaceId int,
+	featureFlagName string,
+	userId int,
+) auditSnapshot {
+	return func(repo repository.Repository) (any, error) {
+		members, err := repo.GetFeatureFlagMembers(ctx, workspaceId, featureFlagName)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if member.UserId == userId {
+				return member, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// workspaceSnapshot reads the workspace *workspaceId points to, so that it
+// can follow a workspace that the change creates.
+func workspaceSnapshot(ctx context.Context, workspaceId *int) auditSnapshot {
+	return func(repo repository.Repository) (any, error) {
+		workspace, err := repo.GetWorkspaceById(ctx, *workspaceId)
+		if errors.Is(err, repository.ErrWorkspaceNotFound) {
+			return nil, nil
+		}
+		return workspace, err
+	}
+}
+
+func workspaceMemberSnapshot(
+	ctx context.Context,
+	workspaceId int,
+	userId int,
+) auditSnapshot {
+	return func(repo repository.Repository) (any, error) {
+		members, err := repo.GetWorkspaceMembers(ctx, workspaceId)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if member.UserId == userId {
+				return member, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+func schedulingSnapshot(ctx context.Context) auditSnapshot {
+	return func(repo repository.Repository) (any, error) {
+		paused, err := repo.IsSchedulingPaused(ctx)
+		return map[string]bool{"Paused": paused}, err
+	}
+}
+
+// HandleAuditCommand answers the /audit command with the last
+// changes of a feature flag or of a user. the command is either
+// "/audit flag [workspace id] <name>" or "/audit user <id or @username>".
+// feature flags are looked up in the current workspace of the admin unless
+// the workspace is given.
+func (h *HttpHandler) HandleAuditCommand(
+	ctx context.Context,
+	updateId, chatId int,
+	text string,
+) {
+	fields := strings.Fields(text)
+	valid := len(fields) == 3 && fields[1] == "user" ||
+		(len(fields) == 3 || len(fields) == 4) && fields[1] == "flag"
+	if !valid {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"برای دیدن تغییرات یک پرچم /audit flag <شناسه فضای کاری> <نام پرچم> و برای دیدن تغییرات یک کاربر /audit user <شناسه یا نام کاربری> را بفرستید. بدون شناسه فضای کاری، پرچم در فضای کاری فعلی شما جستجو می‌شود.",
+			nil,
+		)
+		return
+	}
+
+	var auditLogs []entities.AuditLog
+	var err error
+	if fields[1] == "flag" {
+		var workspaceId int
+		if len(fields) == 4 {
+			workspaceId, err = strconv.Atoi(fields[2])
+			if err != nil {
+				h.api.SendMessage(
+					ctx,
+					fmt.Sprint(chatId),
+					"شناسه فضای کاری باید یک عدد باشد.",
+					nil,
+				)
+				return
+			}
+		} else {
+			var ok bool
+			workspaceId, ok = h.CurrentWorkspaceId(ctx, updateId, chatId)
+			if !ok {
+				return
+			}
+		}
+		featureFlagName := fields[len(fields)-1]
+		auditLogs, err = h.db.GetAuditLogsByFeatureFlag(
+			ctx,
+			workspaceId,
+			featureFlagName,
+			auditLogLimit,
+		)
+	} else {
+		userId, ok := h.ResolveUser(ctx, updateId, chatId, entities.Message{Text: &fields[2]})
+		if !ok {
+			return
+		}
+		auditLogs, err = h.db.GetAuditLogsByActorId(ctx, userId, auditLogLimit)
+	}
+	if err != nil {
+		slog.Error(
+			"error getting audit logs",
+			slog.Int("updateId", updateId),
+			slog.String("text", text),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	if len(auditLogs) == 0 {
+		h.api.SendMessage(ctx, fmt.Sprint(chatId), "تغییری ثبت نشده است.", nil)
+		return
+	}
+
+	for _, message := range utils.AuditLogsToMessages(auditLogs) {
+		_, err = h.api.SendMessage(ctx, fmt.Sprint(chatId), message, nil)
+		if err != nil {
+			slog.Error(
+				"error sending audit logs. err = ",
+				slog.Int("updateId", updateId),
+				slog.Int("chatId", chatId),
+				slog.Int("count", len(auditLogs)),
+				slog.Any("err", err),
+			)
+			return
+		}
+	}
+}
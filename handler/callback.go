@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	api "github.com/fatemehkarimi/chronos_bot/api"
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/pkg/utils"
+)
+
+// wizardStepsCallbackData are the buttons that move a wizard to its next
+// step. their keyboard is removed once pressed so an old step can not be
+// answered again.
+var wizardStepsCallbackData = map[string]bool{
+	utils.KhorshidiCalendarCallbackData:      true,
+	utils.GeorgianCalendarCallbackData:       true,
+	utils.QamariCalendarCallbackData:         true,
+	utils.UsersListForAllCallbackData:        true,
+	utils.ConfirmSchedulePatternCallbackData: true,
+	utils.EditSchedulePatternCallbackData:    true,
+	utils.SaveScheduleAnywayCallbackData:     true,
+	utils.CancelScheduleCallbackData:         true,
+}
+
+// AnswerCallbackQuery stops the loading indicator of the pressed button.
+func (h *HttpHandler) AnswerCallbackQuery(
+	ctx context.Context,
+	updateId int,
+	callbackQuery *entities.CallbackQuery,
+) {
+	err := h.api.AnswerCallbackQuery(ctx, callbackQuery.Id, "")
+	if err != nil {
+		slog.Error(
+			"error answering callback query",
+			slog.Int("updateId", updateId),
+			slog.String("callbackQueryId", callbackQuery.Id),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// RemoveInlineKeyboard removes the keyboard of the message the pressed button
+// belongs to if the button is a wizard step.
+func (h *HttpHandler) RemoveInlineKeyboard(
+	ctx context.Context,
+	updateId int,
+	callbackQuery *entities.CallbackQuery,
+) {
+	if callbackQuery.Message == nil || callbackQuery.Data == nil ||
+		!wizardStepsCallbackData[*callbackQuery.Data] {
+		return
+	}
+
+	_, err := h.api.EditMessageReplyMarkup(
+		ctx,
+		fmt.Sprint(callbackQuery.Message.Chat.Id),
+		callbackQuery.Message.MessageId,
+		nil,
+	)
+	if err != nil && !api.IsMessageNotModified(err) {
+		slog.Error(
+			"error removing inline keyboard",
+			slog.Int("updateId", updateId),
+			slog.Int("messageId", callbackQuery.Message.MessageId),
+			slog.Any("error", err),
+		)
+	}
+}
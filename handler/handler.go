@@ -1,50 +1,123 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	api "github.com/fatemehkarimi/chronos_bot/api"
+	"github.com/fatemehkarimi/chronos_bot/dispatcher"
 	"github.com/fatemehkarimi/chronos_bot/pkg/utils"
 	"github.com/fatemehkarimi/chronos_bot/scheduler"
+	"github.com/fatemehkarimi/chronos_bot/state"
 
 	"github.com/fatemehkarimi/chronos_bot/entities"
 	"github.com/fatemehkarimi/chronos_bot/repository"
-	"github.com/lib/pq"
 )
 
+const schedulePatternText = `برنامه زمانی پرچم را با الگوی زیر بفرستید. برای پارامترهای روز(d)، ساعت(hh) و دقیقه(mm) باید مقداری تعیین شود اما پارامترهای دیگر می‌توانند خالی باشند. اگر به راهنمایی بیشتر نیاز دارید، /help را بفرستید
+y:
+m:
+d: 20
+hh: 0
+mm: 30
+`
+
+const schedulePreviewCount = 5
+
 type Handler interface {
 	GetUpdates(w http.ResponseWriter, r *http.Request)
-	GetLastProcessedUpdateId() int
+	GetLastProcessedUpdateId(ctx context.Context) int
+	ReceiveUpdate(ctx context.Context, update entities.Update) error
+	ProcessUpdate(ctx context.Context, update entities.Update)
+	Stop()
 }
 
 type HttpHandler struct {
+	bot        string
 	db         repository.Repository
 	api        api.Api
-	updateId   int
-	userStates map[string]entities.UserState
+	dispatcher *dispatcher.Dispatcher
+	states     state.StateStore
 	scheduler  scheduler.Scheduler
+	admins     map[int]bool
+	approval   ApprovalConfig
+	// trashRetention is how long deleted feature flags can be restored.
+	trashRetention time.Duration
+	startedAt      time.Time
+	access         AccessConfig
+	accessCache    *accessCache
+	// logChannel is where denied attempts to use the bot are reported.
+	logChannel string
+	// ctx is cancelled on shutdown. background jobs, like broadcasts, run
+	// within it and Stop waits for them.
+	ctx          context.Context
+	jobs         sync.WaitGroup
+	broadcasting atomic.Bool
 }
 
+// NewHttpHandler returns the handler of the bot named bot. bots that share
+// the repository must have different names. updates are processed within
+// ctx.
 func NewHttpHandler(
+	ctx context.Context,
+	bot string,
 	db repository.Repository,
 	Api api.Api,
 	scheduler scheduler.Scheduler,
+	states state.StateStore,
+	dispatcherConfig dispatcher.Config,
+	admins []int,
+	trashRetention time.Duration,
+	approval ApprovalConfig,
+	access AccessConfig,
+	logChannel string,
 ) Handler {
-	return &HttpHandler{
-		db:         db,
-		api:        Api,
-		userStates: map[string]entities.UserState{},
-		updateId:   517,
-		scheduler:  scheduler,
+	adminsSet := make(map[int]bool, len(admins))
+	for _, admin := range admins {
+		adminsSet[admin] = true
+	}
+
+	h := &HttpHandler{
+		bot:       bot,
+		db:        db,
+		api:       Api,
+		states:    states,
+		scheduler: scheduler,
+		admins:    adminsSet,
+		approval:  approval,
+
+		trashRetention: trashRetention,
+		startedAt:      time.Now(),
+		access:         access,
+		accessCache:    newAccessCache(),
+		logChannel:     logChannel,
+		ctx:            ctx,
 	}
+	h.dispatcher = dispatcher.NewDispatcher(ctx, dispatcherConfig, h.ProcessUpdate)
+	return h
 }
 
-func (h *HttpHandler) GetLastProcessedUpdateId() int {
-	return h.updateId
+func (h *HttpHandler) GetLastProcessedUpdateId(ctx context.Context) int {
+	updateId, err := h.db.GetLastProcessedUpdateId(ctx, h.bot)
+	if err != nil {
+		slog.Error("error getting last processed update id", slog.Any("error", err))
+	}
+	return updateId
+}
+
+// Stop waits for the dispatched updates to be processed and the background
+// jobs to end.
+func (h *HttpHandler) Stop() {
+	h.dispatcher.Stop()
+	h.jobs.Wait()
 }
 
 func (h *HttpHandler) GetUpdates(w http.ResponseWriter, r *http.Request) {
@@ -57,23 +130,62 @@ func (h *HttpHandler) GetUpdates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	if h.updateId >= update.UpdateId {
+	err = h.ReceiveUpdate(r.Context(), update)
+	if err != nil {
+		// bale redelivers the update since it is not acknowledged.
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	w.WriteHeader(http.StatusOK)
+}
 
+// ReceiveUpdate deduplicates the update and dispatches it for processing.
+// an error means the update is not recorded and must be delivered again. ctx
+// only bounds the deduplication, the update is processed after it returns.
+func (h *HttpHandler) ReceiveUpdate(
+	ctx context.Context,
+	update entities.Update,
+) error {
+	isNew, err := h.db.MarkUpdateProcessed(ctx, h.bot, update.UpdateId)
+	if err != nil {
+		slog.Error(
+			"error marking update processed",
+			slog.Int("updateId", update.UpdateId),
+			slog.Any("error", err),
+		)
+		return err
+	}
+
+	if !isNew {
+		return nil
+	}
+
+	if !h.dispatcher.Dispatch(update) {
+		slog.Error(
+			"update received after shutdown",
+			slog.Int("updateId", update.UpdateId),
+		)
+	}
+	return nil
+}
+
+// ProcessUpdate handles a single update. updates of the same chat must not
+// be processed concurrently; GetUpdates goes through the dispatcher for that.
+func (h *HttpHandler) ProcessUpdate(
+	ctx context.Context,
+	update entities.Update,
+) {
 	if update.Message != nil {
-		h.HandleMessageUpdate(update.UpdateId, update.Message)
+		h.HandleMessageUpdate(ctx, update.UpdateId, update.Message)
 	}
 
 	if update.CallbackQuery != nil {
-		h.HandleCallbackQueryUpdate(update.UpdateId, update.CallbackQuery)
+		h.HandleCallbackQueryUpdate(ctx, update.UpdateId, update.CallbackQuery)
 	}
-
-	h.updateId = max(h.updateId, update.UpdateId)
 }
 
 func (h *HttpHandler) HandleMessageUpdate(
+	ctx context.Context,
 	updateId int,
 	message *entities.Message,
 ) {
@@ -82,57 +194,74 @@ func (h *HttpHandler) HandleMessageUpdate(
 		return
 	}
 
+	if !h.CheckMessageAccess(ctx, updateId, message.From, message.Text) {
+		return
+	}
+
+	h.RecordBotUser(ctx, message.From)
+
 	chatId := chat.Id
 	text := message.Text
-	if text != nil && *text == "/start" {
+	_, inviteCode := inviteCodeOfStart(text)
+	if text != nil && (*text == "/start" || inviteCode) {
 		from := message.From
 		if from.IsBot {
 			return
 		}
 
-		h.userStates[fmt.Sprintf(
-			"%d",
-			chatId,
-		)] = entities.UserState{StateName: entities.StartState}
+		h.SetUserState(ctx, int(chatId), entities.UserState{StateName: entities.StartState})
 
-		replyMarkup := utils.GetMainReplyMarkup()
-		result := h.api.SendMessage(
+		replyMarkup := h.MainReplyMarkup(ctx, int(chatId))
+		_, err := h.api.SendMessage(
+			ctx,
 			fmt.Sprint(chatId),
 			"سلام!\nچه کاری را می خواهید به من بسپارید؟",
 			replyMarkup,
 		)
 
-		if result.Err != nil {
-			fmt.Println("failed to send response", result.Err)
+		if err != nil {
+			fmt.Println("failed to send response", err)
 
 			slog.Error(
 				"error handling /start command. err = ",
 				slog.Int64("chatId", chatId),
-				slog.Any("err", result.Err),
+				slog.Any("err", err),
 			)
 			h.api.SendMessage(
+				ctx,
 				fmt.Sprint(chatId),
 				"خطایی رخ داده است. لطفا دوباره /start را بفرستید",
 				nil,
 			)
 			return
-		} else {
 		}
+		return
+	}
 
-		fmt.Println(result.Response)
+	if text != nil && h.HandleAdminCommand(ctx, updateId, int(chatId), *text) {
 		return
 	}
 
-	userState := h.userStates[fmt.Sprint(chatId)]
+	userState := h.GetUserState(ctx, int(chatId))
 	switch userState.StateName {
 	case entities.AddFeatureFlagState:
-		h.AddFeatureFlag(updateId, chatId, message)
+		h.AddFeatureFlag(ctx, updateId, chatId, message)
+	case entities.AddFeatureFlagWithScheduleState:
+		h.HandleFeatureFlagWithScheduleName(ctx, updateId, int(chatId), *message)
 	case entities.GetScheduleState:
-		h.HandleGetSchedule(updateId, int(chatId), *message)
+		h.HandleGetSchedule(ctx, updateId, int(chatId), *message)
 	case entities.GetValueState:
-		h.HandleGetValues(updateId, int(chatId), *message)
+		h.HandleGetValues(ctx, updateId, int(chatId), *message)
 	case entities.GetUserListState:
-		h.HandleUsersList(updateId, int(chatId), *message)
+		h.HandleUsersList(ctx, updateId, int(chatId), *message)
+	case entities.GetInviteeState:
+		h.HandleGetInvitee(ctx, updateId, int(chatId), *message)
+	case entities.GetNewOwnerState:
+		h.HandleGetNewOwner(ctx, updateId, int(chatId), *message)
+	case entities.GetWorkspaceNameState:
+		h.HandleGetWorkspaceName(ctx, updateId, int(chatId), *message)
+	case entities.GetWorkspaceMemberState:
+		h.HandleGetWorkspaceMember(ctx, updateId, int(chatId), *message)
 	default:
 		slog.Error(
 			"unhandled default case",
@@ -142,81 +271,231 @@ func (h *HttpHandler) HandleMessageUpdate(
 }
 
 func (h *HttpHandler) HandleCallbackQueryUpdate(
+	ctx context.Context,
 	updateId int,
 	callbackQuery *entities.CallbackQuery,
 ) {
+	if !h.HasAccess(ctx, callbackQuery.From.Id) {
+		h.AnswerCallbackQuery(ctx, updateId, callbackQuery)
+		h.DenyAccess(ctx, updateId, callbackQuery.From, "بدون دسترسی")
+		return
+	}
+
+	h.RecordBotUser(ctx, callbackQuery.From)
+	h.AnswerCallbackQuery(ctx, updateId, callbackQuery)
+	h.RemoveInlineKeyboard(ctx, updateId, callbackQuery)
+
 	data := callbackQuery.Data
 	switch {
 	case *data == utils.AddFeatureFlagCallbackData:
-		h.HandleAddFeatureFlagCallbackData(updateId, callbackQuery.From.Id)
+		h.HandleAddFeatureFlagCallbackData(ctx, updateId, callbackQuery.From.Id)
 	case *data == utils.AddScheduleCallbackData:
-		h.HandleAddScheduleCallbackData(updateId, callbackQuery.From.Id)
+		h.HandleAddScheduleCallbackData(ctx, updateId, callbackQuery.From.Id)
+	case *data == utils.AddFeatureFlagWithScheduleCallbackData:
+		h.HandleAddFeatureFlagWithScheduleCallbackData(
+			ctx,
+			updateId,
+			callbackQuery.From.Id,
+		)
 	case *data == utils.KhorshidiCalendarCallbackData:
 		h.HandleCalendarTypeCallbackData(
+			ctx,
 			updateId,
 			callbackQuery.From.Id,
 			utils.CallbackDataToCalendarType(*data),
 		)
 	case *data == utils.GeorgianCalendarCallbackData:
 		h.HandleCalendarTypeCallbackData(
+			ctx,
 			updateId,
 			callbackQuery.From.Id,
 			utils.CallbackDataToCalendarType(*data),
 		)
 	case *data == utils.QamariCalendarCallbackData:
 		h.HandleCalendarTypeCallbackData(
+			ctx,
 			updateId,
 			callbackQuery.From.Id,
 			utils.CallbackDataToCalendarType(*data),
 		)
 	case strings.HasPrefix(*data, "feature_flag"):
-		userState := h.userStates[fmt.Sprint(callbackQuery.From.Id)]
-		if userState.StateName == entities.ChooseFeatureFlagState {
-			h.HandleChooseFeatureFlag(updateId, callbackQuery.From.Id, *data)
-		} else {
-			h.HandleDeleteFeatureFlag(updateId, callbackQuery.From.Id, *data)
+		userState := h.GetUserState(ctx, callbackQuery.From.Id)
+		switch userState.StateName {
+		case entities.ChooseFeatureFlagState:
+			h.HandleChooseFeatureFlag(ctx, updateId, callbackQuery.From.Id, *data)
+		case entities.ManageFeatureFlagState:
+			h.HandleSendFeatureFlagStatus(
+				ctx,
+				updateId,
+				callbackQuery.From.Id,
+				utils.GetFeatureFlagNameFromCallbackData(*data),
+			)
+		default:
+			h.HandleDeleteFeatureFlag(ctx, updateId, callbackQuery.From.Id, *data)
 		}
 	case *data == utils.UsersListForAllCallbackData:
 		value := "*"
 		h.HandleUsersList(
+			ctx,
 			updateId,
 			callbackQuery.From.Id,
 			entities.Message{Text: &value},
 		)
 	case *data == utils.ViewFeatureFlagsCallbackData:
-		h.HandleViewFeatureFlags(updateId, callbackQuery.From.Id)
+		h.HandleViewFeatureFlags(ctx, updateId, callbackQuery.From.Id)
 	case *data == utils.DeleteFeatureFlagCallbakData:
-		h.HandleDeleteFeatureFlagCallbackData(updateId, callbackQuery.From.Id)
+		h.HandleDeleteFeatureFlagCallbackData(ctx, updateId, callbackQuery.From.Id)
+	case *data == utils.TrashCallbackData:
+		h.HandleTrashCallbackData(ctx, updateId, callbackQuery.From.Id)
+	case strings.HasPrefix(*data, utils.RestoreFeatureFlagCallbackDataPrefix):
+		h.HandleRestoreFeatureFlag(
+			ctx,
+			updateId,
+			callbackQuery.From.Id,
+			strings.TrimPrefix(*data, utils.RestoreFeatureFlagCallbackDataPrefix),
+		)
+	case *data == utils.ManageFeatureFlagsCallbackData:
+		h.HandleManageFeatureFlagsCallbackData(ctx, updateId, callbackQuery.From.Id)
+	case strings.HasPrefix(*data, utils.PauseFeatureFlagCallbackDataPrefix):
+		h.HandleSetFeatureFlagPaused(
+			ctx,
+			updateId,
+			callbackQuery.From.Id,
+			strings.TrimPrefix(*data, utils.PauseFeatureFlagCallbackDataPrefix),
+			true,
+		)
+	case strings.HasPrefix(*data, utils.ResumeFeatureFlagCallbackDataPrefix):
+		h.HandleSetFeatureFlagPaused(
+			ctx,
+			updateId,
+			callbackQuery.From.Id,
+			strings.TrimPrefix(*data, utils.ResumeFeatureFlagCallbackDataPrefix),
+			false,
+		)
+	case strings.HasPrefix(*data, utils.ApproveScheduleCallbackDataPrefix):
+		h.HandleReviewSchedule(
+			ctx,
+			updateId,
+			callbackQuery.From.Id,
+			*data,
+			utils.ApproveScheduleCallbackDataPrefix,
+			entities.ApprovedApproval,
+		)
+	case strings.HasPrefix(*data, utils.RejectScheduleCallbackDataPrefix):
+		h.HandleReviewSchedule(
+			ctx,
+			updateId,
+			callbackQuery.From.Id,
+			*data,
+			utils.RejectScheduleCallbackDataPrefix,
+			entities.RejectedApproval,
+		)
+	case strings.HasPrefix(*data, utils.PauseScheduleCallbackDataPrefix):
+		h.HandleSetSchedulePaused(
+			ctx,
+			updateId,
+			callbackQuery.From.Id,
+			*data,
+			utils.PauseScheduleCallbackDataPrefix,
+			true,
+		)
+	case strings.HasPrefix(*data, utils.ResumeScheduleCallbackDataPrefix):
+		h.HandleSetSchedulePaused(
+			ctx,
+			updateId,
+			callbackQuery.From.Id,
+			*data,
+			utils.ResumeScheduleCallbackDataPrefix,
+			false,
+		)
+	case *data == utils.ConfirmSchedulePatternCallbackData:
+		h.HandleConfirmSchedulePattern(ctx, updateId, callbackQuery.From.Id)
+	case *data == utils.EditSchedulePatternCallbackData:
+		h.HandleEditSchedulePattern(ctx, updateId, callbackQuery.From.Id)
+	case *data == utils.SaveScheduleAnywayCallbackData:
+		h.HandleSaveScheduleAnyway(ctx, updateId, callbackQuery.From.Id)
+	case *data == utils.CancelScheduleCallbackData:
+		h.HandleCancelSchedule(ctx, updateId, callbackQuery.From.Id)
+	case strings.HasPrefix(*data, utils.InviteEditorCallbackDataPrefix):
+		h.HandleInviteCallbackData(
+			ctx,
+			updateId,
+			callbackQuery.From.Id,
+			strings.TrimPrefix(*data, utils.InviteEditorCallbackDataPrefix),
+			entities.EditorRole,
+		)
+	case strings.HasPrefix(*data, utils.InviteViewerCallbackDataPrefix):
+		h.HandleInviteCallbackData(
+			ctx,
+			updateId,
+			callbackQuery.From.Id,
+			strings.TrimPrefix(*data, utils.InviteViewerCallbackDataPrefix),
+			entities.ViewerRole,
+		)
+	case strings.HasPrefix(*data, utils.TransferOwnershipCallbackDataPrefix):
+		h.HandleTransferOwnershipCallbackData(
+			ctx,
+			updateId,
+			callbackQuery.From.Id,
+			strings.TrimPrefix(*data, utils.TransferOwnershipCallbackDataPrefix),
+		)
+	case strings.HasPrefix(*data, utils.FeatureFlagMembersCallbackDataPrefix):
+		h.HandleSendFeatureFlagMembers(
+			ctx,
+			updateId,
+			callbackQuery.From.Id,
+			strings.TrimPrefix(*data, utils.FeatureFlagMembersCallbackDataPrefix),
+		)
+	case strings.HasPrefix(*data, utils.RemoveMemberCallbackDataPrefix):
+		h.HandleRemoveFeatureFlagMember(ctx, updateId, callbackQuery.From.Id, *data)
+	case *data == utils.WorkspacesCallbackData:
+		h.HandleSendWorkspaces(ctx, updateId, callbackQuery.From.Id)
+	case strings.HasPrefix(*data, utils.SwitchWorkspaceCallbackDataPrefix):
+		h.HandleSwitchWorkspace(ctx, updateId, callbackQuery.From.Id, *data)
+	case *data == utils.CreateWorkspaceCallbackData:
+		h.HandleCreateWorkspaceCallbackData(ctx, updateId, callbackQuery.From.Id)
+	case *data == utils.WorkspaceMembersCallbackData:
+		h.HandleSendWorkspaceMembers(ctx, updateId, callbackQuery.From.Id)
+	case *data == utils.AddWorkspaceMemberCallbackData:
+		h.HandleAddWorkspaceMemberCallbackData(ctx, updateId, callbackQuery.From.Id)
+	case strings.HasPrefix(*data, utils.RemoveWorkspaceMemberCallbackDataPrefix):
+		h.HandleRemoveWorkspaceMember(ctx, updateId, callbackQuery.From.Id, *data)
+	case *data == utils.PauseAllCallbackData:
+		h.HandleSetSchedulingPaused(ctx, updateId, callbackQuery.From.Id, true)
+	case *data == utils.ResumeAllCallbackData:
+		h.HandleSetSchedulingPaused(ctx, updateId, callbackQuery.From.Id, false)
 	default:
 		slog.Info("unknown callback query data", slog.String("data", *data))
 	}
 }
 
-func (h *HttpHandler) HandleAddFeatureFlagCallbackData(updateId, chatId int) {
-	result := h.api.SendMessage(
+func (h *HttpHandler) HandleAddFeatureFlagCallbackData(ctx context.Context, updateId, chatId int) {
+	_, err := h.api.SendMessage(
+		ctx,
 		fmt.Sprint(chatId),
 		"نام پرچم(feature flag) را بنویسید.",
 		nil,
 	)
 
-	if result.Err != nil {
+	if err != nil {
 		slog.Error(
 			"error handling /start command. err = ",
 			slog.Int("chatId", chatId),
-			slog.Any("err", result.Err),
+			slog.Any("err", err),
 		)
-		h.ResetUserStateAndSendResetMessage(chatId)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
 		return
 	}
 
-	h.userStates[fmt.Sprint(chatId)] = entities.UserState{StateName: entities.AddFeatureFlagState}
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.AddFeatureFlagState})
 }
 
-func (h *HttpHandler) HandleAddScheduleCallbackData(updateId int, chatId int) {
-	h.HandleSendUserFeatureFlags(updateId, chatId)
+func (h *HttpHandler) HandleAddScheduleCallbackData(ctx context.Context, updateId int, chatId int) {
+	h.HandleSendUserFeatureFlags(ctx, updateId, chatId)
 }
 
 func (h *HttpHandler) AddFeatureFlag(
+	ctx context.Context,
 	updateId int,
 	chatId int64,
 	message *entities.Message,
@@ -225,111 +504,164 @@ func (h *HttpHandler) AddFeatureFlag(
 
 	if value != "" {
 		// because chatId is private, casting is fine
-		err := h.db.AddFeatureFlag(int(chatId), value)
+		workspaceId, ok := h.CurrentWorkspaceIdForRole(
+			ctx,
+			updateId,
+			int(chatId),
+			entities.EditorRole,
+		)
+		if !ok {
+			return
+		}
+
+		err := withAudit(
+			ctx,
+			h.db,
+			&entities.AuditLog{
+				ActorId:         int(chatId),
+				WorkspaceId:     workspaceId,
+				FeatureFlagName: value,
+				Entity:          entities.FeatureFlagAuditEntity,
+			},
+			featureFlagSnapshot(ctx, workspaceId, value),
+			func(repo repository.Repository) error {
+				return repo.AddFeatureFlag(ctx, workspaceId, int(chatId), value)
+			},
+		)
 		if err != nil {
-			if pgErr, ok := err.(*pq.Error); ok {
-				if pgErr.Code == "23505" && pgErr.Constraint == "feature_flag_pkey" {
+			if errors.Is(err, repository.ErrFlagExists) {
+				slog.Error(
+					"duplicate feature flag",
+					slog.Int("updateId", updateId),
+					slog.Int64("chatId", chatId),
+					slog.String("value", value),
+				)
+
+				text := "این پرچم قبلا در این فضای کاری ثبت شده است."
+				featureFlag, err := h.db.GetFeatureFlagByName(ctx, workspaceId, value)
+				if err != nil {
 					slog.Error(
-						"Duplicate key error on feature_flag_pkey",
+						"error getting feature flag",
 						slog.Int("updateId", updateId),
-						slog.Int64("chatId", chatId),
-						slog.String("value", value),
+						slog.Any("error", err),
 					)
+				} else if featureFlag.OwnerId == int(chatId) {
+					text = "این پرچم قبلا به نام شما ثبت شده است."
+				} else {
+					text = "این پرچم در این فضای کاری به نام کاربر دیگری ثبت شده است."
+				}
 
-					featureFlag, err := h.db.GetFeatureFlagByName(value)
-					if err != nil {
-						slog.Error(
-							"error getting feature flag",
-							slog.Any("error", err),
-						)
-					}
-
-					text := "این پرچم به نام کاربر دیگری ثبت شده است."
-					if featureFlag.OwnerId == int(chatId) {
-						text = "این پرچم قبلا به نام شما ثبت شده است."
-					}
-					result := h.api.SendMessage(
-						fmt.Sprint(chatId),
-						text,
-						utils.GetMainReplyMarkup(),
-					)
+				_, err = h.api.SendMessage(
+					ctx,
+					fmt.Sprint(chatId),
+					text,
+					h.MainReplyMarkup(ctx, int(chatId)),
+				)
 
-					if result.Err != nil {
-						slog.Error(
-							"faild to notify user for duplicate response",
-							slog.Int("updateId", updateId),
-							slog.Int64("chatId", chatId),
-							slog.String("value", value),
-						)
-						h.userStates[fmt.Sprint(chatId)] = entities.UserState{StateName: entities.StartState}
-					}
+				if err != nil {
+					slog.Error(
+						"faild to notify user for duplicate response",
+						slog.Int("updateId", updateId),
+						slog.Int64("chatId", chatId),
+						slog.String("value", value),
+					)
+					h.SetUserState(ctx, int(chatId), entities.UserState{StateName: entities.StartState})
 				}
+			} else if errors.Is(err, repository.ErrFlagDeleted) {
+				h.api.SendMessage(
+					ctx,
+					fmt.Sprint(chatId),
+					"پرچمی با این نام در سطل زباله است. آن را بازگردانی کنید یا نام دیگری انتخاب کنید.",
+					h.MainReplyMarkup(ctx, int(chatId)),
+				)
+				h.SetUserState(ctx, int(chatId), entities.UserState{StateName: entities.StartState})
 			} else {
-				h.SendContactDeveloperErrorMessage(updateId, int(chatId))
+				slog.Error(
+					"error adding feature flag",
+					slog.Int("updateId", updateId),
+					slog.Int64("chatId", chatId),
+					slog.String("value", value),
+					slog.Any("error", err),
+				)
+				h.SendContactDeveloperErrorMessage(ctx, updateId, int(chatId))
 			}
 		} else {
-			result := h.api.SendMessage(
+			_, err := h.api.SendMessage(
+				ctx,
 				fmt.Sprint(chatId),
 				"پرچم شما ثبت شد. اکنون می‌توانید برنامه زمانی برای آن تعریف کنید.",
-				utils.GetMainReplyMarkup(),
+				h.MainReplyMarkup(ctx, int(chatId)),
 			)
 
-			if result.Err != nil {
+			if err != nil {
 				slog.Error(
 					"unknown error occurred adding new feature flag",
 					slog.Int("updateId", updateId),
 					slog.Int64("chatId", chatId),
 					slog.String("value", value),
-					slog.Any("error", result.Err),
+					slog.Any("error", err),
 				)
-				h.userStates[fmt.Sprint(chatId)] = entities.UserState{StateName: entities.StartState}
+				h.SetUserState(ctx, int(chatId), entities.UserState{StateName: entities.StartState})
 			}
 		}
 	}
 }
 
-func (h *HttpHandler) HandleSendUserFeatureFlags(updateId, chatId int) {
-	featureFlags, err := h.db.GetFeatureFlagsByOwnerId(chatId)
+func (h *HttpHandler) HandleSendUserFeatureFlags(ctx context.Context, updateId, chatId int) {
+	workspaceId, ok := h.CurrentWorkspaceId(ctx, updateId, chatId)
+	if !ok {
+		return
+	}
+
+	featureFlags, err := h.db.GetFeatureFlagsByUserId(
+		ctx,
+		workspaceId,
+		chatId,
+		entities.EditorRole,
+	)
 	if err != nil {
 		slog.Error("error getting feature flags", slog.Any("error", err))
-		h.SendContactDeveloperErrorMessage(updateId, chatId)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
 		return
 	}
 
 	if len(featureFlags) == 0 {
 		h.api.SendMessage(
+			ctx,
 			fmt.Sprint(chatId),
-			"پرچمی به نام شما ثبت نشده است. پرچم را ثبت کنید تا بتوانید برنامه زمانی برای آن تنظیم کنید.",
-			utils.GetMainReplyMarkup(),
+			"پرچمی که بتوانید برای آن برنامه زمانی تنظیم کنید وجود ندارد. پرچم را ثبت کنید یا از مالک آن بخواهید شما را ویرایشگر کند.",
+			h.MainReplyMarkup(ctx, chatId),
 		)
-		h.userStates[fmt.Sprint(chatId)] = entities.UserState{StateName: entities.StartState}
+		h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
 		return
 	}
 
 	replyMarkup := utils.GetReplyMarkupFromFeatureFlags(featureFlags)
-	result := h.api.SendMessage(
+	_, err = h.api.SendMessage(
+		ctx,
 		fmt.Sprint(chatId),
 		"پرچم را انتخاب کنید.",
 		replyMarkup,
 	)
 
-	if result.Err != nil {
+	if err != nil {
 		slog.Error(
 			"failed to send feature flags to user",
 			slog.Int("updateId", updateId),
 			slog.Int("chatId", chatId),
-			slog.Any("error", result.Err),
+			slog.Any("error", err),
 		)
 		return
 	}
-	h.userStates[fmt.Sprint(chatId)] = entities.UserState{StateName: entities.ChooseFeatureFlagState}
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.ChooseFeatureFlagState})
 }
 
 func (h *HttpHandler) HandleCalendarTypeCallbackData(
+	ctx context.Context,
 	updateId, chatId int,
 	calendarType entities.CalendarType,
 ) {
-	userState := h.userStates[fmt.Sprint(chatId)]
+	userState := h.GetUserState(ctx, chatId)
 	if userState.StateName != entities.ChooseCalendarTypeState {
 		slog.Error(
 			"user cannot set calendar type in this state",
@@ -337,7 +669,7 @@ func (h *HttpHandler) HandleCalendarTypeCallbackData(
 			slog.Int("chatId", chatId),
 			slog.Any("state", userState.StateName),
 		)
-		h.ResetUserStateAndSendResetMessage(chatId)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
 		return
 	}
 
@@ -345,110 +677,150 @@ func (h *HttpHandler) HandleCalendarTypeCallbackData(
 	if schedule != nil {
 		schedule.Calendar.Type = calendarType
 
-		h.userStates[fmt.Sprint(chatId)] = entities.UserState{
-			StateName: entities.GetScheduleState,
-			Schedule:  schedule,
-		}
+		h.SetUserState(ctx, chatId, entities.UserState{
+			StateName:      entities.GetScheduleState,
+			Schedule:       schedule,
+			NewFeatureFlag: userState.NewFeatureFlag,
+		})
 	}
 
 	userState.StateName = entities.GetScheduleState
-	result := h.api.SendMessage(
+	_, err := h.api.SendMessage(
+		ctx,
 		fmt.Sprint(chatId),
-		`برنامه زمانی پرچم را با الگوی زیر بفرستید. برای پارامترهای روز(d)، ساعت(hh) و دقیقه(mm) باید مقداری تعیین شود اما پارامترهای دیگر می‌توانند خالی باشند. اگر به راهنمایی بیشتر نیاز دارید، /help را بفرستید
-y:
-m:
-d: 20
-hh: 0
-mm: 30
-`,
+		schedulePatternText,
 		nil,
 	)
 
-	if result.Err != nil {
+	if err != nil {
 		slog.Error(
 			"error send scheduler message to user",
 			slog.Int("chatId", chatId),
 		)
-		h.ResetUserStateAndSendResetMessage(chatId)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
 		return
 	}
 }
 
-func (h *HttpHandler) ResetUserStateAndSendResetMessage(chatId int) {
+// GetUserState returns the conversation state of the user. the start state
+// is returned if the state can not be loaded.
+func (h *HttpHandler) GetUserState(ctx context.Context, chatId int) entities.UserState {
+	userState, err := h.states.Get(ctx, chatId)
+	if err != nil {
+		slog.Error(
+			"error getting user state",
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		return entities.UserState{StateName: entities.StartState}
+	}
+	return userState
+}
+
+func (h *HttpHandler) SetUserState(
+	ctx context.Context,
+	chatId int,
+	userState entities.UserState,
+) {
+	err := h.states.Set(ctx, chatId, userState)
+	if err != nil {
+		slog.Error(
+			"error setting user state",
+			slog.Int("chatId", chatId),
+			slog.Any("state", userState.StateName),
+			slog.Any("error", err),
+		)
+	}
+}
+
+func (h *HttpHandler) ResetUserStateAndSendResetMessage(ctx context.Context, chatId int) {
 	h.api.SendMessage(
+		ctx,
 		fmt.Sprint(chatId),
 		"خطایی رخ داده است. لطفا دوباره /start را بفرستید",
 		nil,
 	)
-	h.userStates[fmt.Sprint(chatId)] = entities.UserState{StateName: entities.StartState}
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
 }
 
-func (h *HttpHandler) SendContactDeveloperErrorMessage(updateId, chatId int) {
-	result := h.api.SendMessage(
+func (h *HttpHandler) SendContactDeveloperErrorMessage(ctx context.Context, updateId, chatId int) {
+	_, err := h.api.SendMessage(
+		ctx,
 		fmt.Sprint(chatId),
 		"خطای نامشخص رخ داده است. این موضوع را با توسعه دهنده در میان بگذارید.",
-		utils.GetMainReplyMarkup(),
+		h.MainReplyMarkup(ctx, chatId),
 	)
 
-	if result.Err != nil {
+	if err != nil {
 		slog.Error(
 			"unknown error occurred adding new feature flag",
 			slog.Int("updateId", updateId),
 			slog.Int("chatId", chatId),
-			slog.Any("error", result.Err),
+			slog.Any("error", err),
 		)
-		h.userStates[fmt.Sprint(chatId)] = entities.UserState{StateName: entities.StartState}
+		h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
 	}
 }
 
 func (h *HttpHandler) HandleChooseFeatureFlag(
+	ctx context.Context,
 	updateId, chatId int,
 	featureFlagCallbackData string,
 ) {
 	featureFlagName := utils.GetFeatureFlagNameFromCallbackData(featureFlagCallbackData)
-	userState := h.userStates[fmt.Sprint(chatId)]
+	userState := h.GetUserState(ctx, chatId)
 
 	if userState.StateName != entities.ChooseFeatureFlagState {
-		h.ResetUserStateAndSendResetMessage(chatId)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
 		return
 	}
 
-	newFeatureFlag := entities.FeatureFlag{
-		Name:    featureFlagName,
-		OwnerId: chatId,
+	featureFlag, _, ok := h.GetFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		featureFlagName,
+		entities.EditorRole,
+	)
+	if !ok {
+		return
 	}
-	h.HandleSendCalendarType(updateId, chatId, &newFeatureFlag)
+	h.HandleSendCalendarType(ctx, updateId, chatId, featureFlag)
 }
 
 func (h *HttpHandler) HandleSendCalendarType(
+	ctx context.Context,
 	updateId, chatId int,
 	featureFlag *entities.FeatureFlag,
 ) {
 	replyMarkup := utils.GetScheduleReplyMarkup()
-	result := h.api.SendMessage(
+	_, err := h.api.SendMessage(
+		ctx,
 		fmt.Sprint(chatId),
 		"تقویم برنامه زمانی را انتخاب کنید",
 		replyMarkup,
 	)
-	if result.Err != nil {
+	if err != nil {
 		slog.Error(
 			"error send choose calendar message. err = ",
 			slog.Int("chatId", chatId),
-			slog.Any("err", result.Err),
+			slog.Any("err", err),
 		)
-		h.ResetUserStateAndSendResetMessage(chatId)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
 		return
 	}
 
-	h.userStates[fmt.Sprint(chatId)] = entities.UserState{
+	h.SetUserState(ctx, chatId, entities.UserState{
 		StateName: entities.ChooseCalendarTypeState,
 		Schedule: &entities.Schedule{
+			WorkspaceId:     featureFlag.WorkspaceId,
 			FeatureFlagName: featureFlag.Name,
 		},
-	}
+	})
 }
 
 func (h *HttpHandler) HandleGetSchedule(
+	ctx context.Context,
 	updateId, chatId int,
 	message entities.Message,
 ) {
@@ -456,23 +828,25 @@ func (h *HttpHandler) HandleGetSchedule(
 	schedule, err := utils.ParseSchedulePattern(*text)
 
 	if err != nil {
-		h.api.SendMessage(fmt.Sprint(chatId), err.Error(), nil)
+		h.api.SendMessage(ctx, fmt.Sprint(chatId), err.Error(), nil)
 		return
 	}
 
-	userSchedule := h.userStates[fmt.Sprint(chatId)].Schedule
+	userState := h.GetUserState(ctx, chatId)
+	userSchedule := userState.Schedule
 	if userSchedule != nil {
 		userSchedule.Calendar.Year = schedule.Calendar.Year
 		userSchedule.Calendar.Month = schedule.Calendar.Month
 		userSchedule.Calendar.Day = schedule.Calendar.Day
 		userSchedule.Calendar.Hour = schedule.Calendar.Hour
 		userSchedule.Calendar.Minute = schedule.Calendar.Minute
-		h.userStates[fmt.Sprint(chatId)] = entities.UserState{
-			StateName: entities.GetValueState,
-			Schedule:  userSchedule,
-		}
+		h.SetUserState(ctx, chatId, entities.UserState{
+			StateName:      entities.ConfirmSchedulePatternState,
+			Schedule:       userSchedule,
+			NewFeatureFlag: userState.NewFeatureFlag,
+		})
 	} else {
-		h.SendContactDeveloperErrorMessage(updateId, chatId)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
 		slog.Error(
 			"user is in userSchedule state but not feature flag value is provided",
 			slog.Int("updateId", updateId),
@@ -480,30 +854,111 @@ func (h *HttpHandler) HandleGetSchedule(
 		)
 		return
 	}
+
+	h.SendSchedulePreview(ctx, updateId, chatId, *userSchedule)
+}
+
+// SendSchedulePreview shows the next fire times of the schedule so the user
+// can catch a wrong pattern before it is saved.
+func (h *HttpHandler) SendSchedulePreview(
+	ctx context.Context,
+	updateId, chatId int,
+	schedule entities.Schedule,
+) {
+	calendar := utils.GetCalendarByType(schedule.Calendar.Type)
+	// the scheduler fires schedules at the current minute too.
+	occurrences, err := calendar.NextOccurrences(
+		ctx,
+		schedule.Calendar,
+		time.Now().Truncate(time.Minute),
+		schedulePreviewCount,
+	)
+
+	text := utils.OccurrencesToText(schedule.Calendar.Type, occurrences)
+	if err != nil {
+		slog.Error(
+			"error getting next occurrences of schedule",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		text = "محاسبه زمان‌های اجرای بعدی ممکن نشد."
+	}
+
+	_, err = h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		text,
+		utils.GetConfirmSchedulePatternReplyMarkup(),
+	)
+	if err != nil {
+		slog.Error(
+			"error sending schedule preview. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+	}
+}
+
+func (h *HttpHandler) HandleConfirmSchedulePattern(ctx context.Context, updateId, chatId int) {
+	userState := h.GetUserState(ctx, chatId)
+	if userState.StateName != entities.ConfirmSchedulePatternState ||
+		userState.Schedule == nil {
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{
+		StateName:      entities.GetValueState,
+		Schedule:       userState.Schedule,
+		NewFeatureFlag: userState.NewFeatureFlag,
+	})
 	h.api.SendMessage(
+		ctx,
 		fmt.Sprint(chatId),
 		"مقدار(value) پرچم را وارد کنید. با اجرای برنامه زمانی، این مقدار برای کاربران تنظیم می شود.",
 		nil,
 	)
 }
 
+func (h *HttpHandler) HandleEditSchedulePattern(ctx context.Context, updateId, chatId int) {
+	userState := h.GetUserState(ctx, chatId)
+	if userState.StateName != entities.ConfirmSchedulePatternState ||
+		userState.Schedule == nil {
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{
+		StateName:      entities.GetScheduleState,
+		Schedule:       userState.Schedule,
+		NewFeatureFlag: userState.NewFeatureFlag,
+	})
+	h.api.SendMessage(ctx, fmt.Sprint(chatId), schedulePatternText, nil)
+}
+
 func (h *HttpHandler) HandleGetValues(
+	ctx context.Context,
 	updateId, chatId int,
 	message entities.Message,
 ) {
 	value := message.Text
-	userState := h.userStates[fmt.Sprint(chatId)]
+	userState := h.GetUserState(ctx, chatId)
 	schedule := userState.Schedule
 	if schedule != nil {
 		schedule.Value = *value
-		h.userStates[fmt.Sprint(chatId)] = entities.UserState{
-			StateName: entities.GetUserListState,
-			Schedule:  schedule,
-		}
+		h.SetUserState(ctx, chatId, entities.UserState{
+			StateName:      entities.GetUserListState,
+			Schedule:       schedule,
+			NewFeatureFlag: userState.NewFeatureFlag,
+		})
 	}
 
 	replyMarkup := utils.GetUsersListCReplyMarkup()
 	h.api.SendMessage(
+		ctx,
 		fmt.Sprint(chatId),
 		"شناسه‌ی کاربری(id) کاربرانی که می‌خواهید برای آنها این مقدار تنظیم شود را بنویسید. برای تنظیم مقدار برای همه‌ی کاربران، روی دکمه 'همه کاربران' کلیک کنید. ",
 		replyMarkup,
@@ -511,50 +966,205 @@ func (h *HttpHandler) HandleGetValues(
 }
 
 func (h *HttpHandler) HandleUsersList(
+	ctx context.Context,
 	updateId, chatId int,
 	message entities.Message,
 ) {
 	value := message.Text
-	userState := h.userStates[fmt.Sprint(chatId)]
+	userState := h.GetUserState(ctx, chatId)
 	schedule := userState.Schedule
 	if schedule != nil {
 		schedule.UsersList = *value
-		scheduleId, err := h.db.AddSchedule(*schedule)
 
-		if err != nil {
-			h.ResetUserStateAndSendResetMessage(chatId)
-			slog.Error("error save scheduler. err = ", slog.Any("error", err))
+		// a new feature flag has no other schedule to conflict with.
+		if userState.NewFeatureFlag {
+			h.SaveFeatureFlagWithSchedule(ctx, updateId, chatId, schedule)
 			return
 		}
 
-		h.userStates[fmt.Sprint(chatId)] = entities.UserState{StateName: entities.StartState}
-		replyMarkup := utils.GetMainReplyMarkup()
-		h.api.SendMessage(
-			fmt.Sprint(chatId),
-			fmt.Sprintf(
-				"برنامه زمانی با شناسه %d با موفقیت ذخیره شد",
-				scheduleId,
-			),
-			replyMarkup,
+		if h.SendScheduleConflicts(ctx, updateId, chatId, schedule) {
+			return
+		}
+		h.SaveSchedule(ctx, updateId, chatId, schedule)
+	}
+}
+
+// SendScheduleConflicts warns the user about schedules of the same feature
+// flag that fire at or near the fire times of schedule and asks for an
+// explicit confirmation. it reports whether the confirmation was asked.
+func (h *HttpHandler) SendScheduleConflicts(
+	ctx context.Context,
+	updateId, chatId int,
+	schedule *entities.Schedule,
+) bool {
+	existing, err := h.db.GetSchedulesByFeatureFlag(
+		ctx,
+		schedule.WorkspaceId,
+		schedule.FeatureFlagName,
+	)
+	if err != nil {
+		slog.Error(
+			"error getting schedules of feature flag",
+			slog.Int("updateId", updateId),
+			slog.String("featureFlag", schedule.FeatureFlagName),
+			slog.Any("error", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return true
+	}
+
+	conflicts, err := utils.FindScheduleConflicts(
+		ctx,
+		*schedule,
+		existing,
+		time.Now().Truncate(time.Minute),
+		utils.ConflictWindow,
+	)
+
+	var text string
+	switch {
+	case err != nil:
+		slog.Error(
+			"error finding schedule conflicts",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
 		)
+		text = "بررسی هم‌پوشانی برنامه‌های زمانی ممکن نشد. آیا می‌خواهید برنامه را در هر صورت ذخیره کنید؟"
+	case len(conflicts) > 0:
+		text = utils.ScheduleConflictsToText(*schedule, conflicts)
+	default:
+		return false
+	}
 
-		h.scheduler.OnNewSchedule(*schedule)
+	h.SetUserState(ctx, chatId, entities.UserState{
+		StateName: entities.ConfirmScheduleConflictState,
+		Schedule:  schedule,
+	})
+	_, err = h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		text,
+		utils.GetConfirmScheduleConflictReplyMarkup(),
+	)
+	if err != nil {
+		slog.Error(
+			"error sending schedule conflicts. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
 	}
+	return true
 }
 
-func (h *HttpHandler) HandleViewFeatureFlags(updateId, chatId int) {
-	featureFlags, err := h.db.GetFeatureFlagsByOwnerId(chatId)
+func (h *HttpHandler) HandleSaveScheduleAnyway(ctx context.Context, updateId, chatId int) {
+	userState := h.GetUserState(ctx, chatId)
+	if userState.StateName != entities.ConfirmScheduleConflictState ||
+		userState.Schedule == nil {
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	h.SaveSchedule(ctx, updateId, chatId, userState.Schedule)
+}
+
+func (h *HttpHandler) HandleCancelSchedule(ctx context.Context, updateId, chatId int) {
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+	h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		"برنامه زمانی ذخیره نشد.",
+		h.MainReplyMarkup(ctx, chatId),
+	)
+}
+
+func (h *HttpHandler) SaveSchedule(
+	ctx context.Context,
+	updateId, chatId int,
+	schedule *entities.Schedule,
+) {
+	_, _, ok := h.GetWorkspaceFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		schedule.WorkspaceId,
+		schedule.FeatureFlagName,
+		entities.EditorRole,
+	)
+	if !ok {
+		return
+	}
+
+	schedule.CreatorId = chatId
+	schedule.Approval = h.NewScheduleApproval()
+	var scheduleId int
+	err := withAudit(
+		ctx,
+		h.db,
+		&entities.AuditLog{
+			ActorId:         chatId,
+			WorkspaceId:     schedule.WorkspaceId,
+			FeatureFlagName: schedule.FeatureFlagName,
+			Entity:          entities.ScheduleAuditEntity,
+		},
+		scheduleSnapshot(ctx, &scheduleId),
+		func(repo repository.Repository) error {
+			var err error
+			scheduleId, err = repo.AddSchedule(ctx, *schedule)
+			return err
+		},
+	)
+
+	if err != nil {
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		slog.Error("error save scheduler. err = ", slog.Any("error", err))
+		return
+	}
+	schedule.ScheduleId = scheduleId
+
+	text := fmt.Sprintf("برنامه زمانی با شناسه %d با موفقیت ذخیره شد", scheduleId)
+	if schedule.Approval == entities.PendingApproval {
+		text += " و پس از تایید اجرا می‌شود"
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+	replyMarkup := h.MainReplyMarkup(ctx, chatId)
+	h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		text,
+		replyMarkup,
+	)
+
+	h.OnScheduleSaved(ctx, updateId, *schedule)
+}
+
+func (h *HttpHandler) HandleViewFeatureFlags(ctx context.Context, updateId, chatId int) {
+	workspaceId, ok := h.CurrentWorkspaceId(ctx, updateId, chatId)
+	if !ok {
+		return
+	}
+
+	featureFlags, err := h.db.GetFeatureFlagsByUserId(
+		ctx,
+		workspaceId,
+		chatId,
+		entities.ViewerRole,
+	)
 	if err != nil {
 		slog.Error("error getting feature flags", slog.Any("error", err))
-		h.SendContactDeveloperErrorMessage(updateId, chatId)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
 		return
 	}
 
 	if len(featureFlags) == 0 {
-		replyMarkup := utils.GetMainReplyMarkup()
+		replyMarkup := h.MainReplyMarkup(ctx, chatId)
 		h.api.SendMessage(
+			ctx,
 			fmt.Sprint(chatId),
-			"شما هیچ پرچمی ثبت نکرده‌اید.",
+			"شما به هیچ پرچمی دسترسی ندارید.",
 			replyMarkup,
 		)
 		return
@@ -566,91 +1176,134 @@ func (h *HttpHandler) HandleViewFeatureFlags(updateId, chatId int) {
 		flagList.WriteString(fmt.Sprintf("%d. %s\n", i+1, flag.Name))
 	}
 
-	replyMarkup := utils.GetMainReplyMarkup()
-	result := h.api.SendMessage(
+	replyMarkup := h.MainReplyMarkup(ctx, chatId)
+	_, err = h.api.SendMessage(
+		ctx,
 		fmt.Sprint(chatId),
 		flagList.String(),
 		replyMarkup,
 	)
 
-	if result.Err != nil {
-		slog.Error("error sending feature flags", slog.Any("error", result.Err))
-		h.ResetUserStateAndSendResetMessage(chatId)
+	if err != nil {
+		slog.Error("error sending feature flags", slog.Any("error", err))
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
 		return
 	}
 }
 
-func (h *HttpHandler) HandleDeleteFeatureFlagCallbackData(updateId, chatId int) {
-	featureFlags, err := h.db.GetFeatureFlagsByOwnerId(chatId)
+func (h *HttpHandler) HandleDeleteFeatureFlagCallbackData(ctx context.Context, updateId, chatId int) {
+	workspaceId, ok := h.CurrentWorkspaceId(ctx, updateId, chatId)
+	if !ok {
+		return
+	}
+
+	featureFlags, err := h.db.GetFeatureFlagsByUserId(
+		ctx,
+		workspaceId,
+		chatId,
+		entities.OwnerRole,
+	)
 	if err != nil {
 		slog.Error("error getting feature flags", slog.Any("error", err))
-		h.SendContactDeveloperErrorMessage(updateId, chatId)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
 		return
 	}
 
 	if len(featureFlags) == 0 {
-		replyMarkup := utils.GetMainReplyMarkup()
+		replyMarkup := h.MainReplyMarkup(ctx, chatId)
 		h.api.SendMessage(
+			ctx,
 			fmt.Sprint(chatId),
 			"پرچمی برای شما ثبت نشده است تا ان را پاک کنید.",
 			replyMarkup,
 		)
-		h.userStates[fmt.Sprint(chatId)] = entities.UserState{StateName: entities.StartState}
+		h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
 		return
 	}
 
 	replyMarkup := utils.GetReplyMarkupFromFeatureFlags(featureFlags)
-	result := h.api.SendMessage(
+	_, err = h.api.SendMessage(
+		ctx,
 		fmt.Sprint(chatId),
 		"کدام پرچم را می‌خواهید حذف کنید؟",
 		replyMarkup,
 	)
 
-	if result.Err != nil {
+	if err != nil {
 		slog.Error(
 			"error sending select feature flag to delete. err = ",
 			slog.Int("updateId", updateId),
 			slog.Int("chatId", chatId),
-			slog.Any("err", result.Err),
+			slog.Any("err", err),
 		)
-		h.ResetUserStateAndSendResetMessage(chatId)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
 		return
 	}
 
-	h.userStates[fmt.Sprint(chatId)] = entities.UserState{StateName: entities.DeleteFeatureFlagState}
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.DeleteFeatureFlagState})
 }
 
 func (h *HttpHandler) HandleDeleteFeatureFlag(
+	ctx context.Context,
 	updateId, chatId int,
 	featureFlagCallbackData string,
 ) {
 	featureFlagName := utils.GetFeatureFlagNameFromCallbackData(featureFlagCallbackData)
-	userState := h.userStates[fmt.Sprint(chatId)]
+	userState := h.GetUserState(ctx, chatId)
 
 	if userState.StateName != entities.DeleteFeatureFlagState {
-		h.ResetUserStateAndSendResetMessage(chatId)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
 		return
 	}
 
-	err := h.db.RemoveFeatureFlag(featureFlagName)
+	featureFlag, _, ok := h.GetFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		featureFlagName,
+		entities.OwnerRole,
+	)
+	if !ok {
+		return
+	}
+
+	err := withAudit(
+		ctx,
+		h.db,
+		&entities.AuditLog{
+			ActorId:         chatId,
+			WorkspaceId:     featureFlag.WorkspaceId,
+			FeatureFlagName: featureFlagName,
+			Entity:          entities.FeatureFlagAuditEntity,
+		},
+		featureFlagSnapshot(ctx, featureFlag.WorkspaceId, featureFlagName),
+		func(repo repository.Repository) error {
+			return repo.RemoveFeatureFlag(ctx, featureFlag.WorkspaceId, featureFlagName)
+		},
+	)
 	if err != nil {
-		h.ResetUserStateAndSendResetMessage(chatId)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
 		return
 	}
-	replyMarkup := utils.GetMainReplyMarkup()
+	replyMarkup := h.MainReplyMarkup(ctx, chatId)
 
-	result := h.api.SendMessage(
+	_, err = h.api.SendMessage(
+		ctx,
 		fmt.Sprint(chatId),
-		fmt.Sprintf("پرچم %s پاک شد.", featureFlagName),
+		fmt.Sprintf(
+			"پرچم %s به سطل زباله منتقل شد و تا %d روز قابل بازگردانی است.",
+			featureFlagName,
+			trashDays(h.trashRetention),
+		),
 		replyMarkup,
 	)
-	if result.Err != nil {
+	if err != nil {
 		slog.Error(
 			"error sending success delete feature flag. err = ",
 			slog.Int("updateId", updateId),
 			slog.Int("chatId", chatId),
-			slog.Any("err", result.Err),
+			slog.Any("err", err),
 		)
 	}
-	h.userStates[fmt.Sprint(chatId)] = entities.UserState{StateName: entities.StartState}
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
 }
@@ -0,0 +1,312 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/pkg/utils"
+	"github.com/fatemehkarimi/chronos_bot/repository"
+)
+
+func (h *HttpHandler) HandleManageFeatureFlagsCallbackData(ctx context.Context, updateId, chatId int) {
+	workspaceId, ok := h.CurrentWorkspaceId(ctx, updateId, chatId)
+	if !ok {
+		return
+	}
+
+	featureFlags, err := h.db.GetFeatureFlagsByUserId(
+		ctx,
+		workspaceId,
+		chatId,
+		entities.ViewerRole,
+	)
+	if err != nil {
+		slog.Error("error getting feature flags", slog.Any("error", err))
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	if len(featureFlags) == 0 {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"شما به هیچ پرچمی دسترسی ندارید.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+		return
+	}
+
+	replyMarkup := utils.GetReplyMarkupFromFeatureFlags(featureFlags)
+	_, err = h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		"وضعیت کدام پرچم را می‌خواهید تغییر دهید؟",
+		replyMarkup,
+	)
+
+	if err != nil {
+		slog.Error(
+			"error sending select feature flag to manage. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.ManageFeatureFlagState})
+}
+
+func (h *HttpHandler) HandleSendFeatureFlagStatus(
+	ctx context.Context,
+	updateId, chatId int,
+	featureFlagName string,
+) {
+	featureFlag, role, ok := h.GetFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		featureFlagName,
+		entities.ViewerRole,
+	)
+	if !ok {
+		return
+	}
+
+	schedules, err := h.db.GetSchedulesByFeatureFlag(
+		ctx,
+		featureFlag.WorkspaceId,
+		featureFlagName,
+	)
+	if err != nil {
+		slog.Error(
+			"error getting schedules of feature flag",
+			slog.String("featureFlag", featureFlagName),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	_, err = h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		utils.FeatureFlagStatusToText(*featureFlag, schedules, role),
+		utils.GetFeatureFlagStatusReplyMarkup(*featureFlag, schedules, role),
+	)
+
+	if err != nil {
+		slog.Error(
+			"error sending feature flag status. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.ManageFeatureFlagState})
+}
+
+func (h *HttpHandler) HandleSetFeatureFlagPaused(
+	ctx context.Context,
+	updateId, chatId int,
+	featureFlagName string,
+	paused bool,
+) {
+	featureFlag, _, ok := h.GetFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		featureFlagName,
+		entities.EditorRole,
+	)
+	if !ok {
+		return
+	}
+
+	err := withAudit(
+		ctx,
+		h.db,
+		&entities.AuditLog{
+			ActorId:         chatId,
+			WorkspaceId:     featureFlag.WorkspaceId,
+			FeatureFlagName: featureFlagName,
+			Entity:          entities.FeatureFlagAuditEntity,
+		},
+		featureFlagSnapshot(ctx, featureFlag.WorkspaceId, featureFlagName),
+		func(repo repository.Repository) error {
+			return repo.SetFeatureFlagPaused(
+				ctx,
+				featureFlag.WorkspaceId,
+				featureFlagName,
+				paused,
+			)
+		},
+	)
+	if err != nil {
+		slog.Error(
+			"error setting feature flag paused",
+			slog.String("featureFlag", featureFlagName),
+			slog.Bool("paused", paused),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	if !paused {
+		h.scheduler.RelaunchToday()
+	}
+	h.HandleSendFeatureFlagStatus(ctx, updateId, chatId, featureFlagName)
+}
+
+func (h *HttpHandler) HandleSetSchedulePaused(
+	ctx context.Context,
+	updateId, chatId int,
+	callbackData string,
+	prefix string,
+	paused bool,
+) {
+	scheduleId, err := utils.GetScheduleIdFromCallbackData(callbackData, prefix)
+	if err != nil {
+		slog.Error(
+			"invalid schedule callback data",
+			slog.String("data", callbackData),
+			slog.Any("error", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	schedule, err := h.db.GetScheduleById(ctx, scheduleId)
+	if errors.Is(err, repository.ErrScheduleNotFound) {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"این برنامه زمانی دیگر وجود ندارد.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+		return
+	}
+	if err != nil {
+		slog.Error(
+			"error getting schedule",
+			slog.Int("scheduleId", scheduleId),
+			slog.Any("error", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	_, _, ok := h.GetWorkspaceFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		schedule.WorkspaceId,
+		schedule.FeatureFlagName,
+		entities.EditorRole,
+	)
+	if !ok {
+		return
+	}
+
+	err = withAudit(
+		ctx,
+		h.db,
+		&entities.AuditLog{
+			ActorId:         chatId,
+			WorkspaceId:     schedule.WorkspaceId,
+			FeatureFlagName: schedule.FeatureFlagName,
+			Entity:          entities.ScheduleAuditEntity,
+		},
+		scheduleSnapshot(ctx, &scheduleId),
+		func(repo repository.Repository) error {
+			return repo.SetSchedulePaused(ctx, scheduleId, paused)
+		},
+	)
+	if err != nil {
+		slog.Error(
+			"error setting schedule paused",
+			slog.Int("scheduleId", scheduleId),
+			slog.Bool("paused", paused),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	if !paused {
+		schedule.Paused = false
+		h.scheduler.OnNewSchedule(*schedule)
+	}
+	h.HandleSendFeatureFlagStatus(ctx, updateId, chatId, schedule.FeatureFlagName)
+}
+
+func (h *HttpHandler) HandleSetSchedulingPaused(
+	ctx context.Context,
+	updateId, chatId int,
+	paused bool,
+) {
+	if !h.IsAdmin(ctx, chatId) {
+		slog.Error(
+			"non admin user tried to change scheduling status",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	err := withAudit(
+		ctx,
+		h.db,
+		&entities.AuditLog{
+			ActorId: chatId,
+			Entity:  entities.SchedulingAuditEntity,
+		},
+		schedulingSnapshot(ctx),
+		func(repo repository.Repository) error {
+			return repo.SetSchedulingPaused(ctx, paused)
+		},
+	)
+	if err != nil {
+		slog.Error(
+			"error setting scheduling paused",
+			slog.Bool("paused", paused),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	text := "همه‌ی برنامه‌های زمانی ادامه می‌یابند."
+	if paused {
+		text = "همه‌ی برنامه‌های زمانی متوقف شدند."
+	} else {
+		h.scheduler.RelaunchToday()
+	}
+
+	_, err = h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		text,
+		h.MainReplyMarkup(ctx, chatId),
+	)
+	if err != nil {
+		slog.Error(
+			"error sending scheduling status. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+	}
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+}
@@ -0,0 +1,578 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	api "github.com/fatemehkarimi/chronos_bot/api"
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/pkg/utils"
+	"github.com/fatemehkarimi/chronos_bot/repository"
+)
+
+// NotifyUser sends text to userId on behalf of chatId. chatId is told if the
+// message can not be delivered because userId has not started the bot.
+func (h *HttpHandler) NotifyUser(ctx context.Context, updateId, chatId, userId int, text string) {
+	_, err := h.api.SendMessage(
+		ctx,
+		fmt.Sprint(userId),
+		text,
+		h.MainReplyMarkup(ctx, userId),
+	)
+	if err == nil {
+		return
+	}
+
+	if api.IsChatUnreachable(err) {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			fmt.Sprintf(
+				"پیام به کاربر %d نرسید. این کاربر هنوز ربات را شروع نکرده یا آن را مسدود کرده است.",
+				userId,
+			),
+			nil,
+		)
+		return
+	}
+
+	slog.Error(
+		"error notifying user",
+		slog.Int("updateId", updateId),
+		slog.Int("userId", userId),
+		slog.Any("error", err),
+	)
+}
+
+func (h *HttpHandler) RecordBotUser(ctx context.Context, user entities.User) {
+	if user.IsBot {
+		return
+	}
+
+	err := h.db.UpsertBotUser(ctx, h.bot, user)
+	if err != nil {
+		slog.Error(
+			"error recording bot user",
+			slog.Int("userId", user.Id),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// GetFeatureFlagForRole returns the feature flag of the current workspace of
+// the user and the role of the user on it if the user has at least minRole.
+// the user is notified and false is returned otherwise.
+func (h *HttpHandler) GetFeatureFlagForRole(
+	ctx context.Context,
+	updateId, chatId int,
+	featureFlagName string,
+	minRole entities.Role,
+) (*entities.FeatureFlag, entities.Role, bool) {
+	workspaceId, ok := h.CurrentWorkspaceId(ctx, updateId, chatId)
+	if !ok {
+		return nil, 0, false
+	}
+
+	return h.GetWorkspaceFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		workspaceId,
+		featureFlagName,
+		minRole,
+	)
+}
+
+func (h *HttpHandler) GetWorkspaceFeatureFlagForRole(
+	ctx context.Context,
+	updateId, chatId int,
+	workspaceId int,
+	featureFlagName string,
+	minRole entities.Role,
+) (*entities.FeatureFlag, entities.Role, bool) {
+	role, err := h.db.GetFeatureFlagRole(ctx, workspaceId, featureFlagName, chatId)
+	if err != nil {
+		slog.Error(
+			"error getting feature flag role",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.String("featureFlag", featureFlagName),
+			slog.Any("error", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return nil, 0, false
+	}
+
+	if role < minRole {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"شما دسترسی لازم برای این کار را روی این پرچم ندارید.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+		return nil, role, false
+	}
+
+	featureFlag, err := h.db.GetFeatureFlagByName(ctx, workspaceId, featureFlagName)
+	if err != nil {
+		slog.Error(
+			"error getting feature flag",
+			slog.Int("updateId", updateId),
+			slog.String("featureFlag", featureFlagName),
+			slog.Any("error", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return nil, role, false
+	}
+
+	return featureFlag, role, true
+}
+
+func (h *HttpHandler) HandleInviteCallbackData(
+	ctx context.Context,
+	updateId, chatId int,
+	featureFlagName string,
+	role entities.Role,
+) {
+	featureFlag, _, ok := h.GetFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		featureFlagName,
+		entities.OwnerRole,
+	)
+	if !ok {
+		return
+	}
+
+	_, err := h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		fmt.Sprintf(
+			"شناسه‌ی کاربری(id) یا نام کاربری(@username) کسی را که می‌خواهید %s پرچم %s شود بفرستید. او باید پیش از این ربات را /start کرده باشد.",
+			utils.RoleToText(role),
+			featureFlagName,
+		),
+		nil,
+	)
+	if err != nil {
+		slog.Error(
+			"error sending invite message. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{
+		StateName:       entities.GetInviteeState,
+		WorkspaceId:     featureFlag.WorkspaceId,
+		FeatureFlagName: featureFlagName,
+		Role:            role,
+	})
+}
+
+func (h *HttpHandler) HandleGetInvitee(
+	ctx context.Context,
+	updateId, chatId int,
+	message entities.Message,
+) {
+	userState := h.GetUserState(ctx, chatId)
+	_, _, ok := h.GetWorkspaceFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		userState.WorkspaceId,
+		userState.FeatureFlagName,
+		entities.OwnerRole,
+	)
+	if !ok {
+		return
+	}
+
+	inviteeId, ok := h.ResolveUser(ctx, updateId, chatId, message)
+	if !ok {
+		return
+	}
+
+	if inviteeId == chatId {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"شما مالک این پرچم هستید. شناسه‌ی کاربر دیگری را بفرستید.",
+			nil,
+		)
+		return
+	}
+
+	err := withAudit(
+		ctx,
+		h.db,
+		&entities.AuditLog{
+			ActorId:         chatId,
+			WorkspaceId:     userState.WorkspaceId,
+			FeatureFlagName: userState.FeatureFlagName,
+			Entity:          entities.FeatureFlagMemberAuditEntity,
+		},
+		featureFlagMemberSnapshot(
+			ctx,
+			userState.WorkspaceId,
+			userState.FeatureFlagName,
+			inviteeId,
+		),
+		func(repo repository.Repository) error {
+			return repo.SetFeatureFlagMember(
+				ctx,
+				userState.WorkspaceId,
+				userState.FeatureFlagName,
+				inviteeId,
+				userState.Role,
+			)
+		},
+	)
+	if err != nil {
+		slog.Error(
+			"error setting feature flag member",
+			slog.Int("updateId", updateId),
+			slog.String("featureFlag", userState.FeatureFlagName),
+			slog.Int("inviteeId", inviteeId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+	h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		fmt.Sprintf(
+			"کاربر %d اکنون %s پرچم %s است.",
+			inviteeId,
+			utils.RoleToText(userState.Role),
+			userState.FeatureFlagName,
+		),
+		h.MainReplyMarkup(ctx, chatId),
+	)
+
+	h.NotifyUser(
+		ctx,
+		updateId,
+		chatId,
+		inviteeId,
+		fmt.Sprintf(
+			"شما به عنوان %s پرچم %s اضافه شدید.",
+			utils.RoleToText(userState.Role),
+			userState.FeatureFlagName,
+		),
+	)
+}
+
+func (h *HttpHandler) HandleTransferOwnershipCallbackData(
+	ctx context.Context,
+	updateId, chatId int,
+	featureFlagName string,
+) {
+	featureFlag, _, ok := h.GetFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		featureFlagName,
+		entities.OwnerRole,
+	)
+	if !ok {
+		return
+	}
+
+	_, err := h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		fmt.Sprintf(
+			"شناسه‌ی کاربری(id) یا نام کاربری(@username) مالک جدید پرچم %s را بفرستید. شما پس از انتقال، ویرایشگر این پرچم خواهید بود.",
+			featureFlagName,
+		),
+		nil,
+	)
+	if err != nil {
+		slog.Error(
+			"error sending transfer ownership message. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{
+		StateName:       entities.GetNewOwnerState,
+		WorkspaceId:     featureFlag.WorkspaceId,
+		FeatureFlagName: featureFlagName,
+	})
+}
+
+func (h *HttpHandler) HandleGetNewOwner(
+	ctx context.Context,
+	updateId, chatId int,
+	message entities.Message,
+) {
+	userState := h.GetUserState(ctx, chatId)
+	_, _, ok := h.GetWorkspaceFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		userState.WorkspaceId,
+		userState.FeatureFlagName,
+		entities.OwnerRole,
+	)
+	if !ok {
+		return
+	}
+
+	newOwnerId, ok := h.ResolveUser(ctx, updateId, chatId, message)
+	if !ok {
+		return
+	}
+
+	if newOwnerId == chatId {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"شما هم‌اکنون مالک این پرچم هستید. شناسه‌ی کاربر دیگری را بفرستید.",
+			nil,
+		)
+		return
+	}
+
+	err := withAudit(
+		ctx,
+		h.db,
+		&entities.AuditLog{
+			ActorId:         chatId,
+			WorkspaceId:     userState.WorkspaceId,
+			FeatureFlagName: userState.FeatureFlagName,
+			Entity:          entities.FeatureFlagAuditEntity,
+		},
+		featureFlagSnapshot(ctx, userState.WorkspaceId, userState.FeatureFlagName),
+		func(repo repository.Repository) error {
+			return repo.TransferFeatureFlagOwnership(
+				ctx,
+				userState.WorkspaceId,
+				userState.FeatureFlagName,
+				newOwnerId,
+			)
+		},
+	)
+	if err != nil {
+		slog.Error(
+			"error transferring feature flag ownership",
+			slog.Int("updateId", updateId),
+			slog.String("featureFlag", userState.FeatureFlagName),
+			slog.Int("newOwnerId", newOwnerId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+	h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		fmt.Sprintf(
+			"مالکیت پرچم %s به کاربر %d منتقل شد.",
+			userState.FeatureFlagName,
+			newOwnerId,
+		),
+		h.MainReplyMarkup(ctx, chatId),
+	)
+
+	h.NotifyUser(
+		ctx,
+		updateId,
+		chatId,
+		newOwnerId,
+		fmt.Sprintf("شما اکنون مالک پرچم %s هستید.", userState.FeatureFlagName),
+	)
+}
+
+func (h *HttpHandler) HandleSendFeatureFlagMembers(
+	ctx context.Context,
+	updateId, chatId int,
+	featureFlagName string,
+) {
+	featureFlag, _, ok := h.GetFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		featureFlagName,
+		entities.OwnerRole,
+	)
+	if !ok {
+		return
+	}
+
+	members, err := h.db.GetFeatureFlagMembers(
+		ctx,
+		featureFlag.WorkspaceId,
+		featureFlagName,
+	)
+	if err != nil {
+		slog.Error(
+			"error getting feature flag members",
+			slog.Int("updateId", updateId),
+			slog.String("featureFlag", featureFlagName),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	_, err = h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		utils.FeatureFlagMembersToText(featureFlagName, members),
+		utils.GetFeatureFlagMembersReplyMarkup(featureFlagName, members),
+	)
+	if err != nil {
+		slog.Error(
+			"error sending feature flag members. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+	}
+}
+
+func (h *HttpHandler) HandleRemoveFeatureFlagMember(
+	ctx context.Context,
+	updateId, chatId int,
+	callbackData string,
+) {
+	memberId, featureFlagName, err := utils.GetMemberFromCallbackData(
+		callbackData,
+		utils.RemoveMemberCallbackDataPrefix,
+	)
+	if err != nil {
+		slog.Error(
+			"invalid remove member callback data",
+			slog.String("data", callbackData),
+			slog.Any("error", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	featureFlag, _, ok := h.GetFeatureFlagForRole(
+		ctx,
+		updateId,
+		chatId,
+		featureFlagName,
+		entities.OwnerRole,
+	)
+	if !ok {
+		return
+	}
+
+	if memberId == chatId {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"مالک پرچم را نمی‌توان حذف کرد. ابتدا مالکیت را منتقل کنید.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		return
+	}
+
+	err = withAudit(
+		ctx,
+		h.db,
+		&entities.AuditLog{
+			ActorId:         chatId,
+			WorkspaceId:     featureFlag.WorkspaceId,
+			FeatureFlagName: featureFlagName,
+			Entity:          entities.FeatureFlagMemberAuditEntity,
+		},
+		featureFlagMemberSnapshot(ctx, featureFlag.WorkspaceId, featureFlagName, memberId),
+		func(repo repository.Repository) error {
+			return repo.RemoveFeatureFlagMember(
+				ctx,
+				featureFlag.WorkspaceId,
+				featureFlagName,
+				memberId,
+			)
+		},
+	)
+	if err != nil {
+		slog.Error(
+			"error removing feature flag member",
+			slog.Int("updateId", updateId),
+			slog.String("featureFlag", featureFlagName),
+			slog.Int("memberId", memberId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	h.HandleSendFeatureFlagMembers(ctx, updateId, chatId, featureFlagName)
+}
+
+// ResolveUser returns the id of the user that the message refers to, either
+// by id or by username. usernames are resolved among the users who have
+// already talked to the bot.
+func (h *HttpHandler) ResolveUser(
+	ctx context.Context,
+	updateId, chatId int,
+	message entities.Message,
+) (int, bool) {
+	if message.Text == nil {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"شناسه یا نام کاربری را به صورت متن بفرستید.",
+			nil,
+		)
+		return 0, false
+	}
+
+	userId, userName, err := utils.ParseUserReference(*message.Text)
+	if err != nil {
+		h.api.SendMessage(ctx, fmt.Sprint(chatId), err.Error(), nil)
+		return 0, false
+	}
+
+	if userName == "" {
+		return userId, true
+	}
+
+	user, err := h.db.GetBotUserByUserName(ctx, userName)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			h.api.SendMessage(
+				ctx,
+				fmt.Sprint(chatId),
+				"کاربری با این نام کاربری پیدا نشد. از او بخواهید ابتدا ربات را /start کند.",
+				nil,
+			)
+			return 0, false
+		}
+
+		slog.Error(
+			"error getting bot user by username",
+			slog.Int("updateId", updateId),
+			slog.String("userName", userName),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return 0, false
+	}
+
+	return user.UserId, true
+}
@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/pkg/utils"
+	"github.com/fatemehkarimi/chronos_bot/repository"
+)
+
+// trashDays returns retention in whole days, rounded up.
+func trashDays(retention time.Duration) int {
+	day := 24 * time.Hour
+	return int((retention + day - 1) / day)
+}
+
+// HandleTrashCallbackData lists the deleted feature flags of the current
+// workspace that the user owned, with the days left to restore each of them.
+func (h *HttpHandler) HandleTrashCallbackData(ctx context.Context, updateId, chatId int) {
+	workspaceId, ok := h.CurrentWorkspaceId(ctx, updateId, chatId)
+	if !ok {
+		return
+	}
+
+	featureFlags, err := h.db.GetDeletedFeatureFlagsByUserId(
+		ctx,
+		workspaceId,
+		chatId,
+		entities.OwnerRole,
+	)
+	if err != nil {
+		slog.Error(
+			"error getting deleted feature flags",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	if len(featureFlags) == 0 {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"سطل زباله خالی است.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString("پرچم‌های سطل زباله:\n")
+	for _, featureFlag := range featureFlags {
+		deletedAt := time.Unix(featureFlag.DeletedAt, 0)
+		remaining := time.Until(deletedAt.Add(h.trashRetention))
+		if remaining < 0 {
+			remaining = 0
+		}
+		fmt.Fprintf(
+			&text,
+			"%s: %d روز تا پاک شدن کامل\n",
+			featureFlag.Name,
+			trashDays(remaining),
+		)
+	}
+
+	_, err = h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		text.String(),
+		utils.GetTrashReplyMarkup(featureFlags),
+	)
+	if err != nil {
+		slog.Error(
+			"error sending trash. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+}
+
+// HandleRestoreFeatureFlag takes a feature flag the user owned out of the
+// trash of the current workspace, along with its members and schedules.
+func (h *HttpHandler) HandleRestoreFeatureFlag(
+	ctx context.Context,
+	updateId, chatId int,
+	featureFlagName string,
+) {
+	workspaceId, ok := h.CurrentWorkspaceId(ctx, updateId, chatId)
+	if !ok {
+		return
+	}
+
+	featureFlags, err := h.db.GetDeletedFeatureFlagsByUserId(
+		ctx,
+		workspaceId,
+		chatId,
+		entities.OwnerRole,
+	)
+	if err != nil {
+		slog.Error(
+			"error getting deleted feature flags",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	inTrash := false
+	for _, featureFlag := range featureFlags {
+		if featureFlag.Name == featureFlagName {
+			inTrash = true
+			break
+		}
+	}
+
+	if inTrash {
+		err = withAudit(
+			ctx,
+			h.db,
+			&entities.AuditLog{
+				ActorId:         chatId,
+				WorkspaceId:     workspaceId,
+				FeatureFlagName: featureFlagName,
+				Entity:          entities.FeatureFlagAuditEntity,
+				// the feature flag cannot be read while it is in the trash.
+				Action: entities.UpdateAuditAction,
+			},
+			featureFlagSnapshot(ctx, workspaceId, featureFlagName),
+			func(repo repository.Repository) error {
+				return repo.RestoreFeatureFlag(ctx, workspaceId, featureFlagName)
+			},
+		)
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			inTrash = false
+		} else if err != nil {
+			slog.Error(
+				"error restoring feature flag",
+				slog.Int("updateId", updateId),
+				slog.String("featureFlag", featureFlagName),
+				slog.Any("error", err),
+			)
+			h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+			return
+		}
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+	if !inTrash {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"این پرچم در سطل زباله نیست.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		return
+	}
+
+	h.scheduler.RelaunchToday()
+	_, err = h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		fmt.Sprintf("پرچم %s بازگردانی شد.", featureFlagName),
+		h.MainReplyMarkup(ctx, chatId),
+	)
+	if err != nil {
+		slog.Error(
+			"error sending restored feature flag. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+	}
+}
+
+// PurgeTrash deletes the feature flags that were moved to the trash before t
+// and records each of them in the audit log as deleted by the system.
+func PurgeTrash(ctx context.Context, repo repository.Repository, t time.Time) error {
+	return repo.WithTx(ctx, func(tx repository.Repository) error {
+		featureFlags, err := tx.PurgeFeatureFlagsDeletedBefore(ctx, t)
+		if err != nil {
+			return err
+		}
+
+		for _, featureFlag := range featureFlags {
+			before, err := json.Marshal(featureFlag)
+			if err != nil {
+				return err
+			}
+
+			err = tx.AddAuditLog(ctx, entities.AuditLog{
+				ActorId:         entities.SystemActorId,
+				WorkspaceId:     featureFlag.WorkspaceId,
+				FeatureFlagName: featureFlag.Name,
+				Entity:          entities.FeatureFlagAuditEntity,
+				Action:          entities.DeleteAuditAction,
+				Before:          string(before),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/pkg/utils"
+	"github.com/fatemehkarimi/chronos_bot/repository"
+)
+
+// HandleAddFeatureFlagWithScheduleCallbackData starts the conversation that
+// creates a feature flag together with its first schedule. nothing is saved
+// until the schedule is complete.
+func (h *HttpHandler) HandleAddFeatureFlagWithScheduleCallbackData(
+	ctx context.Context,
+	updateId, chatId int,
+) {
+	workspaceId, ok := h.CurrentWorkspaceIdForRole(
+		ctx,
+		updateId,
+		chatId,
+		entities.EditorRole,
+	)
+	if !ok {
+		return
+	}
+
+	_, err := h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		"نام پرچم(feature flag) را بنویسید. پرچم پس از تکمیل برنامه زمانی آن ثبت می‌شود.",
+		nil,
+	)
+	if err != nil {
+		slog.Error(
+			"error asking feature flag name",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{
+		StateName:      entities.AddFeatureFlagWithScheduleState,
+		Schedule:       &entities.Schedule{WorkspaceId: workspaceId},
+		NewFeatureFlag: true,
+	})
+}
+
+func (h *HttpHandler) HandleFeatureFlagWithScheduleName(
+	ctx context.Context,
+	updateId, chatId int,
+	message entities.Message,
+) {
+	userState := h.GetUserState(ctx, chatId)
+	if userState.Schedule == nil {
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	if message.Text == nil || strings.TrimSpace(*message.Text) == "" {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"نام پرچم را به صورت متن بفرستید.",
+			nil,
+		)
+		return
+	}
+	name := strings.TrimSpace(*message.Text)
+
+	_, err := h.db.GetFeatureFlagByName(ctx, userState.Schedule.WorkspaceId, name)
+	if err == nil {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"این پرچم قبلا در این فضای کاری ثبت شده است. نام دیگری بفرستید.",
+			nil,
+		)
+		return
+	}
+	if !errors.Is(err, repository.ErrFlagNotFound) {
+		slog.Error(
+			"error getting feature flag",
+			slog.Int("updateId", updateId),
+			slog.String("featureFlag", name),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	_, err = h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		"تقویم برنامه زمانی را انتخاب کنید",
+		utils.GetScheduleReplyMarkup(),
+	)
+	if err != nil {
+		slog.Error(
+			"error send choose calendar message. err = ",
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	userState.Schedule.FeatureFlagName = name
+	h.SetUserState(ctx, chatId, entities.UserState{
+		StateName:      entities.ChooseCalendarTypeState,
+		Schedule:       userState.Schedule,
+		NewFeatureFlag: true,
+	})
+}
+
+// SaveFeatureFlagWithSchedule creates the feature flag of schedule and the
+// schedule in one transaction, so a failure leaves neither of them behind.
+func (h *HttpHandler) SaveFeatureFlagWithSchedule(
+	ctx context.Context,
+	updateId, chatId int,
+	schedule *entities.Schedule,
+) {
+	role, err := h.db.GetWorkspaceRole(ctx, schedule.WorkspaceId, chatId)
+	if err != nil {
+		slog.Error(
+			"error getting workspace role",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+	if role < entities.EditorRole {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"شما دسترسی لازم برای این کار را در این فضای کاری ندارید.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+		return
+	}
+
+	schedule.CreatorId = chatId
+	schedule.Approval = h.NewScheduleApproval()
+	err = h.db.WithTx(ctx, func(tx repository.Repository) error {
+		err := withAudit(
+			ctx,
+			tx,
+			&entities.AuditLog{
+				ActorId:         chatId,
+				WorkspaceId:     schedule.WorkspaceId,
+				FeatureFlagName: schedule.FeatureFlagName,
+				Entity:          entities.FeatureFlagAuditEntity,
+			},
+			featureFlagSnapshot(ctx, schedule.WorkspaceId, schedule.FeatureFlagName),
+			func(repo repository.Repository) error {
+				return repo.AddFeatureFlag(
+					ctx,
+					schedule.WorkspaceId,
+					chatId,
+					schedule.FeatureFlagName,
+				)
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		return withAudit(
+			ctx,
+			tx,
+			&entities.AuditLog{
+				ActorId:         chatId,
+				WorkspaceId:     schedule.WorkspaceId,
+				FeatureFlagName: schedule.FeatureFlagName,
+				Entity:          entities.ScheduleAuditEntity,
+			},
+			scheduleSnapshot(ctx, &schedule.ScheduleId),
+			func(repo repository.Repository) error {
+				var err error
+				schedule.ScheduleId, err = repo.AddSchedule(ctx, *schedule)
+				return err
+			},
+		)
+	})
+	if errors.Is(err, repository.ErrFlagExists) {
+		h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"پرچمی با این نام در این فاصله در فضای کاری ثبت شده است. پرچم و برنامه زمانی ذخیره نشدند.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		return
+	}
+	if errors.Is(err, repository.ErrFlagDeleted) {
+		h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"پرچمی با این نام در سطل زباله است. آن را بازگردانی کنید یا نام دیگری انتخاب کنید.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		return
+	}
+	if err != nil {
+		slog.Error(
+			"error saving feature flag with schedule",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.String("featureFlag", schedule.FeatureFlagName),
+			slog.Any("error", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	text := fmt.Sprintf(
+		"پرچم %s و برنامه زمانی آن با شناسه %d با موفقیت ذخیره شدند",
+		schedule.FeatureFlagName,
+		schedule.ScheduleId,
+	)
+	if schedule.Approval == entities.PendingApproval {
+		text += ". برنامه زمانی پس از تایید اجرا می‌شود"
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+	h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		text,
+		h.MainReplyMarkup(ctx, chatId),
+	)
+
+	h.OnScheduleSaved(ctx, updateId, *schedule)
+}
@@ -0,0 +1,604 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/pkg/utils"
+	"github.com/fatemehkarimi/chronos_bot/repository"
+)
+
+// personalWorkspacePrefix starts the names of the workspaces created for users
+// who have none. users can not take such names for their own workspaces.
+const personalWorkspacePrefix = "personal-"
+
+// CurrentWorkspaceId returns the workspace the user is working in. users who
+// have not chosen a workspace yet are moved to their first workspace, or to
+// a personal workspace created for them.
+func (h *HttpHandler) CurrentWorkspaceId(ctx context.Context, updateId, chatId int) (int, bool) {
+	workspaceId, err := h.db.GetCurrentWorkspaceId(ctx, chatId)
+	if err != nil {
+		slog.Error(
+			"error getting current workspace",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return 0, false
+	}
+
+	if workspaceId != 0 {
+		role, err := h.db.GetWorkspaceRole(ctx, workspaceId, chatId)
+		if err != nil {
+			slog.Error(
+				"error getting workspace role",
+				slog.Int("updateId", updateId),
+				slog.Int("chatId", chatId),
+				slog.Any("error", err),
+			)
+			h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+			return 0, false
+		}
+		if role != 0 {
+			return workspaceId, true
+		}
+	}
+
+	workspaces, err := h.db.GetWorkspacesByUserId(ctx, chatId)
+	if err != nil {
+		slog.Error(
+			"error getting workspaces of user",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return 0, false
+	}
+
+	if len(workspaces) > 0 {
+		workspaceId = workspaces[0].WorkspaceId
+	} else {
+		workspaceId, err = h.CreateWorkspace(ctx, chatId, fmt.Sprint(personalWorkspacePrefix, chatId))
+		if err != nil {
+			slog.Error(
+				"error creating personal workspace",
+				slog.Int("updateId", updateId),
+				slog.Int("chatId", chatId),
+				slog.Any("error", err),
+			)
+			h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+			return 0, false
+		}
+	}
+
+	err = h.db.SetCurrentWorkspaceId(ctx, chatId, workspaceId)
+	if err != nil {
+		slog.Error(
+			"error setting current workspace",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return 0, false
+	}
+
+	return workspaceId, true
+}
+
+// CurrentWorkspaceIdForRole returns the current workspace of the user if the
+// user has at least minRole in it. the user is notified otherwise.
+func (h *HttpHandler) CurrentWorkspaceIdForRole(
+	ctx context.Context,
+	updateId, chatId int,
+	minRole entities.Role,
+) (int, bool) {
+	workspaceId, ok := h.CurrentWorkspaceId(ctx, updateId, chatId)
+	if !ok {
+		return 0, false
+	}
+
+	role, err := h.db.GetWorkspaceRole(ctx, workspaceId, chatId)
+	if err != nil {
+		slog.Error(
+			"error getting workspace role",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return 0, false
+	}
+
+	if role < minRole && !(minRole == entities.OwnerRole && h.IsAdmin(ctx, chatId)) {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"شما دسترسی لازم برای این کار را در این فضای کاری ندارید.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+		return 0, false
+	}
+
+	return workspaceId, true
+}
+
+func (h *HttpHandler) HandleSendWorkspaces(ctx context.Context, updateId, chatId int) {
+	currentWorkspaceId, ok := h.CurrentWorkspaceId(ctx, updateId, chatId)
+	if !ok {
+		return
+	}
+
+	workspaces, err := h.db.GetWorkspacesByUserId(ctx, chatId)
+	if err != nil {
+		slog.Error(
+			"error getting workspaces of user",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	role, err := h.db.GetWorkspaceRole(ctx, currentWorkspaceId, chatId)
+	if err != nil {
+		slog.Error(
+			"error getting workspace role",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	_, err = h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		"فضای کاری را انتخاب کنید. نام پرچم‌ها در هر فضای کاری جداگانه است.",
+		utils.GetWorkspacesReplyMarkup(
+			workspaces,
+			currentWorkspaceId,
+			role == entities.OwnerRole || h.IsAdmin(ctx, chatId),
+		),
+	)
+	if err != nil {
+		slog.Error(
+			"error sending workspaces. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+}
+
+func (h *HttpHandler) HandleSwitchWorkspace(
+	ctx context.Context,
+	updateId, chatId int,
+	callbackData string,
+) {
+	workspaceId, err := utils.GetIdFromCallbackData(
+		callbackData,
+		utils.SwitchWorkspaceCallbackDataPrefix,
+	)
+	if err != nil {
+		slog.Error(
+			"invalid switch workspace callback data",
+			slog.String("data", callbackData),
+			slog.Any("error", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	role, err := h.db.GetWorkspaceRole(ctx, workspaceId, chatId)
+	if err != nil {
+		slog.Error(
+			"error getting workspace role",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+	if role == 0 {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"شما عضو این فضای کاری نیستید.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		return
+	}
+
+	workspace, err := h.db.GetWorkspaceById(ctx, workspaceId)
+	if err == nil {
+		err = h.db.SetCurrentWorkspaceId(ctx, chatId, workspaceId)
+	}
+	if err != nil {
+		slog.Error(
+			"error switching workspace",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Int("workspaceId", workspaceId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+	h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		fmt.Sprintf("فضای کاری فعلی شما: %s", workspace.Name),
+		h.MainReplyMarkup(ctx, chatId),
+	)
+}
+
+func (h *HttpHandler) HandleCreateWorkspaceCallbackData(ctx context.Context, updateId, chatId int) {
+	_, err := h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		"نام فضای کاری جدید را بنویسید.",
+		nil,
+	)
+	if err != nil {
+		slog.Error(
+			"error sending create workspace message. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.GetWorkspaceNameState})
+}
+
+// CreateWorkspace creates a workspace owned by the user and records it in
+// the audit log.
+func (h *HttpHandler) CreateWorkspace(
+	ctx context.Context,
+	chatId int,
+	name string,
+) (int, error) {
+	var workspaceId int
+	auditLog := entities.AuditLog{
+		ActorId: chatId,
+		Entity:  entities.WorkspaceAuditEntity,
+	}
+	err := withAudit(
+		ctx,
+		h.db,
+		&auditLog,
+		workspaceSnapshot(ctx, &workspaceId),
+		func(repo repository.Repository) error {
+			var err error
+			workspaceId, err = repo.CreateWorkspace(ctx, name, chatId)
+			auditLog.WorkspaceId = workspaceId
+			return err
+		},
+	)
+	return workspaceId, err
+}
+
+func (h *HttpHandler) HandleGetWorkspaceName(
+	ctx context.Context,
+	updateId, chatId int,
+	message entities.Message,
+) {
+	if message.Text == nil || strings.TrimSpace(*message.Text) == "" {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"نام فضای کاری را به صورت متن بفرستید.",
+			nil,
+		)
+		return
+	}
+	name := strings.TrimSpace(*message.Text)
+	if strings.HasPrefix(strings.ToLower(name), personalWorkspacePrefix) {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			fmt.Sprintf("نام فضای کاری نمی‌تواند با %s شروع شود. نام دیگری بفرستید.", personalWorkspacePrefix),
+			nil,
+		)
+		return
+	}
+
+	workspaceId, err := h.CreateWorkspace(ctx, chatId, name)
+	if err != nil {
+		if errors.Is(err, repository.ErrWorkspaceExists) {
+			h.api.SendMessage(
+				ctx,
+				fmt.Sprint(chatId),
+				"فضای کاری دیگری با این نام وجود دارد. نام دیگری بفرستید.",
+				nil,
+			)
+			return
+		}
+
+		slog.Error(
+			"error creating workspace",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.String("name", name),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	err = h.db.SetCurrentWorkspaceId(ctx, chatId, workspaceId)
+	if err != nil {
+		slog.Error(
+			"error setting current workspace",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("error", err),
+		)
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+	h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		fmt.Sprintf("فضای کاری %s ساخته شد و اکنون فضای کاری فعلی شماست.", name),
+		h.MainReplyMarkup(ctx, chatId),
+	)
+}
+
+func (h *HttpHandler) HandleSendWorkspaceMembers(ctx context.Context, updateId, chatId int) {
+	workspaceId, ok := h.CurrentWorkspaceIdForRole(
+		ctx,
+		updateId,
+		chatId,
+		entities.OwnerRole,
+	)
+	if !ok {
+		return
+	}
+
+	workspace, err := h.db.GetWorkspaceById(ctx, workspaceId)
+	if err != nil {
+		slog.Error(
+			"error getting workspace",
+			slog.Int("updateId", updateId),
+			slog.Int("workspaceId", workspaceId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	members, err := h.db.GetWorkspaceMembers(ctx, workspaceId)
+	if err != nil {
+		slog.Error(
+			"error getting workspace members",
+			slog.Int("updateId", updateId),
+			slog.Int("workspaceId", workspaceId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	_, err = h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		utils.WorkspaceMembersToText(*workspace, members),
+		utils.GetWorkspaceMembersReplyMarkup(members),
+	)
+	if err != nil {
+		slog.Error(
+			"error sending workspace members. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+	}
+}
+
+func (h *HttpHandler) HandleAddWorkspaceMemberCallbackData(ctx context.Context, updateId, chatId int) {
+	workspaceId, ok := h.CurrentWorkspaceIdForRole(
+		ctx,
+		updateId,
+		chatId,
+		entities.OwnerRole,
+	)
+	if !ok {
+		return
+	}
+
+	_, err := h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		"شناسه‌ی کاربری(id) یا نام کاربری(@username) عضو جدید را بفرستید. اعضا می‌توانند در این فضای کاری پرچم بسازند و پرچم‌های آن را ببینند.",
+		nil,
+	)
+	if err != nil {
+		slog.Error(
+			"error sending add workspace member message. err = ",
+			slog.Int("updateId", updateId),
+			slog.Int("chatId", chatId),
+			slog.Any("err", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{
+		StateName:   entities.GetWorkspaceMemberState,
+		WorkspaceId: workspaceId,
+	})
+}
+
+func (h *HttpHandler) HandleGetWorkspaceMember(
+	ctx context.Context,
+	updateId, chatId int,
+	message entities.Message,
+) {
+	workspaceId, ok := h.CurrentWorkspaceIdForRole(
+		ctx,
+		updateId,
+		chatId,
+		entities.OwnerRole,
+	)
+	if !ok {
+		return
+	}
+
+	userState := h.GetUserState(ctx, chatId)
+	if userState.WorkspaceId != workspaceId {
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	memberId, ok := h.ResolveUser(ctx, updateId, chatId, message)
+	if !ok {
+		return
+	}
+
+	role, err := h.db.GetWorkspaceRole(ctx, workspaceId, memberId)
+	if err == nil && role < entities.EditorRole {
+		err = withAudit(
+			ctx,
+			h.db,
+			&entities.AuditLog{
+				ActorId:     chatId,
+				WorkspaceId: workspaceId,
+				Entity:      entities.WorkspaceMemberAuditEntity,
+			},
+			workspaceMemberSnapshot(ctx, workspaceId, memberId),
+			func(repo repository.Repository) error {
+				return repo.SetWorkspaceMember(ctx, workspaceId, memberId, entities.EditorRole)
+			},
+		)
+	}
+	if err != nil {
+		slog.Error(
+			"error adding workspace member",
+			slog.Int("updateId", updateId),
+			slog.Int("workspaceId", workspaceId),
+			slog.Int("memberId", memberId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	h.SetUserState(ctx, chatId, entities.UserState{StateName: entities.StartState})
+	h.api.SendMessage(
+		ctx,
+		fmt.Sprint(chatId),
+		fmt.Sprintf("کاربر %d به فضای کاری اضافه شد.", memberId),
+		h.MainReplyMarkup(ctx, chatId),
+	)
+
+	workspace, err := h.db.GetWorkspaceById(ctx, workspaceId)
+	if err != nil {
+		slog.Error(
+			"error getting workspace",
+			slog.Int("workspaceId", workspaceId),
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	h.NotifyUser(
+		ctx,
+		updateId,
+		chatId,
+		memberId,
+		fmt.Sprintf(
+			"شما به فضای کاری %s اضافه شدید. از منوی فضای کاری می‌توانید به آن بروید.",
+			workspace.Name,
+		),
+	)
+}
+
+func (h *HttpHandler) HandleRemoveWorkspaceMember(
+	ctx context.Context,
+	updateId, chatId int,
+	callbackData string,
+) {
+	workspaceId, ok := h.CurrentWorkspaceIdForRole(
+		ctx,
+		updateId,
+		chatId,
+		entities.OwnerRole,
+	)
+	if !ok {
+		return
+	}
+
+	memberId, err := utils.GetIdFromCallbackData(
+		callbackData,
+		utils.RemoveWorkspaceMemberCallbackDataPrefix,
+	)
+	if err != nil {
+		slog.Error(
+			"invalid remove workspace member callback data",
+			slog.String("data", callbackData),
+			slog.Any("error", err),
+		)
+		h.ResetUserStateAndSendResetMessage(ctx, chatId)
+		return
+	}
+
+	role, err := h.db.GetWorkspaceRole(ctx, workspaceId, memberId)
+	if err == nil && role == entities.OwnerRole {
+		h.api.SendMessage(
+			ctx,
+			fmt.Sprint(chatId),
+			"مالک فضای کاری را نمی‌توان حذف کرد.",
+			h.MainReplyMarkup(ctx, chatId),
+		)
+		return
+	}
+	if err == nil {
+		err = withAudit(
+			ctx,
+			h.db,
+			&entities.AuditLog{
+				ActorId:     chatId,
+				WorkspaceId: workspaceId,
+				Entity:      entities.WorkspaceMemberAuditEntity,
+			},
+			workspaceMemberSnapshot(ctx, workspaceId, memberId),
+			func(repo repository.Repository) error {
+				return repo.RemoveWorkspaceMember(ctx, workspaceId, memberId)
+			},
+		)
+	}
+	if err != nil {
+		slog.Error(
+			"error removing workspace member",
+			slog.Int("updateId", updateId),
+			slog.Int("workspaceId", workspaceId),
+			slog.Int("memberId", memberId),
+			slog.Any("error", err),
+		)
+		h.SendContactDeveloperErrorMessage(ctx, updateId, chatId)
+		return
+	}
+
+	h.HandleSendWorkspaceMembers(ctx, updateId, chatId)
+}
@@ -1,17 +1,24 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/fatemehkarimi/chronos_bot/dispatcher"
 	"github.com/fatemehkarimi/chronos_bot/handler"
 	"github.com/fatemehkarimi/chronos_bot/scheduler"
+	"github.com/fatemehkarimi/chronos_bot/state"
+	"github.com/fatemehkarimi/chronos_bot/updates"
 
 	"github.com/fatemehkarimi/chronos_bot/api"
 	"github.com/fatemehkarimi/chronos_bot/entities"
@@ -20,10 +27,83 @@ import (
 	"github.com/spf13/viper"
 )
 
+// processedUpdateRetention is how long processed update ids are kept for
+// deduplication.
+const processedUpdateRetention = 7 * 24 * time.Hour
+
 type Config struct {
-	Database   repository.DatabaseConfig
+	Database repository.DatabaseConfig
+	// BotToken, LogChannel, Updates and Api configure a single bale bot
+	// when Bots is empty.
 	BotToken   string
 	LogChannel string
+	Admins     []int
+	StateTTL   time.Duration
+	Dispatcher dispatcher.Config
+	Updates    updates.Config
+	Api        api.Config
+	Bots       []BotConfig
+	// TrashRetention is how long deleted feature flags can be restored.
+	TrashRetention time.Duration
+	Approval       handler.ApprovalConfig
+	Access         handler.AccessConfig
+}
+
+// BotConfig configures one of the bots served by the deployment. every bot
+// needs a unique name, and webhook bots need their own address. user ids of
+// the bots share the same membership tables and admin lists, so all bots of
+// a deployment must be on the same messenger. see CheckPlatforms.
+type BotConfig struct {
+	Name       string
+	Platform   string
+	Token      string
+	LogChannel string
+	Updates    updates.Config
+	Api        api.Config
+}
+
+// GetBots returns the configured bots, falling back to the single bale bot
+// of the top level config.
+func (c Config) GetBots() []BotConfig {
+	if len(c.Bots) > 0 {
+		return c.Bots
+	}
+
+	return []BotConfig{
+		{
+			Platform:   api.BalePlatform,
+			Token:      c.BotToken,
+			LogChannel: c.LogChannel,
+			Updates:    c.Updates,
+			Api:        c.Api,
+		},
+	}
+}
+
+// CheckPlatforms returns an error if bots are on more than one messenger.
+// user ids are only unique within a messenger, so a telegram user could
+// otherwise get the workspaces and the admin rights of a bale user with the
+// same id.
+func CheckPlatforms(bots []BotConfig) error {
+	platform := ""
+	for _, bot := range bots {
+		botPlatform := bot.Platform
+		if botPlatform == "" {
+			botPlatform = api.BalePlatform
+		}
+		if platform == "" {
+			platform = botPlatform
+		}
+		if botPlatform != platform {
+			return fmt.Errorf(
+				"bot %q is on %s while other bots are on %s; bots of different messengers need separate deployments",
+				bot.Name,
+				botPlatform,
+				platform,
+			)
+		}
+	}
+	return nil
 }
 
 func LoadConfig() (Config, error) {
@@ -47,189 +127,308 @@ func main() {
 		os.Exit(-1)
 	}
 
-	connectionCredentials := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		config.Database.Host,
-		config.Database.Port,
-		config.Database.User,
-		config.Database.Password,
-		config.Database.DBName,
-	)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = RunMigrate(config.Database, os.Args[2:])
+		if err != nil {
+			slog.Error("failed to migrate database", slog.Any("err", err))
+			os.Exit(1)
+		}
+		return
+	}
 
-	db, err := sql.Open("postgres", connectionCredentials)
+	bots := config.GetBots()
+	err = CheckPlatforms(bots)
 	if err != nil {
-		slog.Error("failed to open db", slog.Any("err", err))
+		slog.Error("invalid bots config", slog.Any("err", err))
 		os.Exit(1)
 	}
-	defer func(db *sql.DB) {
-		err := db.Close()
-		if err != nil {
-			slog.Error("failed to close db", slog.Any("err", err))
-		}
-	}(db)
 
-	err = db.Ping()
+	repo, db, err := OpenRepository(config.Database)
 	if err != nil {
-		slog.Error("failed to ping database. err = ", slog.Any("err", err))
+		slog.Error("failed to open repository", slog.Any("err", err))
 		os.Exit(1)
 	}
+	if db != nil {
+		defer func(db *sql.DB) {
+			err := db.Close()
+			if err != nil {
+				slog.Error("failed to close db", slog.Any("err", err))
+			}
+		}(db)
+	}
+
+	ctx, stop := signal.NotifyContext(
+		context.Background(),
+		os.Interrupt,
+		syscall.SIGTERM,
+	)
+	defer stop()
 
-	postgresRepo := repository.PostgresRepository{DB: db}
-	err = postgresRepo.Init()
+	err = repo.Init(ctx)
 
 	if err != nil {
 		slog.Error("failed to init service. error = ", slog.Any("err", err))
 		os.Exit(1)
 	}
 
-	baleApi := api.NewBaleApi(config.BotToken)
-	awxScheduler := scheduler.NewScheduler(
-		&postgresRepo,
-		baleApi,
-		config.LogChannel,
-	)
-	go RunDailyJob(awxScheduler)
+	botApis := make([]api.BotApi, len(bots))
+	logChannels := make([]scheduler.LogChannel, 0, len(bots))
+	for i, bot := range bots {
+		botApis[i], err = api.NewPlatformApi(bot.Platform, bot.Token, bot.Api)
+		if err != nil {
+			slog.Error(
+				"failed to create bot api",
+				slog.String("bot", bot.Name),
+				slog.Any("err", err),
+			)
+			os.Exit(1)
+		}
 
-	httpHandler := handler.NewHttpHandler(&postgresRepo, baleApi, awxScheduler)
+		if bot.LogChannel != "" {
+			logChannels = append(
+				logChannels,
+				scheduler.LogChannel{Api: botApis[i], ChatId: bot.LogChannel},
+			)
+		}
+	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/getUpdates", httpHandler.GetUpdates)
-	mux.HandleFunc(
-		"/healthcheck", func(w http.ResponseWriter, r *http.Request) {
-			_, err := w.Write([]byte("hello from chronos bot"))
-			if err != nil {
-				return
-			}
-			w.WriteHeader(http.StatusOK)
-		},
-	)
+	trashRetention := config.TrashRetention
+	if trashRetention <= 0 {
+		trashRetention = repository.DefaultTrashRetention
+	}
 
-	server := &http.Server{
-		Addr:         ":8080",
-		Handler:      mux,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  120 * time.Second,
+	if config.Approval.Required && len(config.Approval.Approvers) == 0 {
+		slog.Warn("schedules require approval but no approvers are configured")
 	}
 
-	//go checkForUpdates(config.BotToken, httpHandler)
-	err = server.ListenAndServe()
-	if err != nil {
+	awxScheduler := scheduler.NewScheduler(ctx, repo, logChannels)
+	go RunDailyJob(ctx, awxScheduler)
+
+	stateStores := make([]state.StateStore, len(bots))
+	handlers := make([]handler.Handler, len(bots))
+	updateSources := make([]updates.UpdateSource, len(bots))
+	for i, bot := range bots {
+		stateStores[i] = NewStateStore(
+			config.Database.Driver,
+			db,
+			bot.Name,
+			config.StateTTL,
+			config.Database.QueryTimeout,
+		)
+
+		handlers[i] = handler.NewHttpHandler(
+			ctx,
+			bot.Name,
+			repo,
+			botApis[i],
+			awxScheduler,
+			stateStores[i],
+			config.Dispatcher,
+			config.Admins,
+			trashRetention,
+			config.Approval,
+			config.Access,
+			bot.LogChannel,
+		)
+
+		updateSources[i], err = updates.NewUpdateSource(
+			bot.Updates,
+			botApis[i],
+			handlers[i],
+		)
+		if err != nil {
+			slog.Error(
+				"failed to create update source",
+				slog.String("bot", bot.Name),
+				slog.Any("err", err),
+			)
+			os.Exit(1)
+		}
+	}
+	go RunCleanupJob(ctx, stateStores, repo, trashRetention)
+
+	failed := RunUpdateSources(ctx, stop, bots, updateSources)
+	for _, h := range handlers {
+		h.Stop()
+	}
+	if failed {
 		os.Exit(1)
 	}
+	slog.Info("chronos bot stopped")
 }
 
-func checkForUpdates(botToken string, handler handler.Handler) {
-	client := &http.Client{}
-	for {
-		time.Sleep(5 * time.Second)
-		requestStruct := entities.RequestGetUpdates{}
-		requestBytes, err := json.MarshalIndent(requestStruct, "", "  ")
-
+// OpenRepository opens the repository of the configured driver. the returned
+// db is nil for the memory driver.
+func OpenRepository(
+	config repository.DatabaseConfig,
+) (repository.Repository, *sql.DB, error) {
+	switch config.Driver {
+	case repository.MemoryDriver:
+		return repository.NewMemoryRepository(), nil, nil
+	case repository.SqliteDriver:
+		db, err := repository.OpenSqlite(config.Path)
 		if err != nil {
-			slog.Error("error marshaling request", slog.Any("error", err))
+			return nil, nil, err
 		}
-		lastUpdateId := handler.GetLastProcessedUpdateId() + 1
-		slog.Info("fetching updates from ", slog.Int("updateId", lastUpdateId))
-		limit := 1
-		timeout := 1
-		endpoint := fmt.Sprintf(
-			"https://tapi.bale.ai/bot%s/getUpdates?offset=%d&limit=%d&timeout=%d",
-			botToken,
-			lastUpdateId,
-			limit,
-			timeout,
-		)
+		return repository.NewSqliteRepository(db, config.QueryTimeout), db, nil
+	case "", repository.PostgresDriver:
+	default:
+		return nil, nil, fmt.Errorf("unknown database driver %q", config.Driver)
+	}
 
-		go func() {
-			req, err := http.NewRequest(
-				"POST",
-				endpoint,
-				bytes.NewBuffer(requestBytes),
-			)
+	connectionCredentials := fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		config.Host,
+		config.Port,
+		config.User,
+		config.Password,
+		config.DBName,
+	)
 
-			if err != nil {
-				slog.Error("error creating new request", slog.Any("error", err))
-				return
-			}
+	db, err := sql.Open("postgres", connectionCredentials)
+	if err != nil {
+		return nil, nil, err
+	}
 
-			res, err := client.Do(req)
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return repository.CreateNewRepository(db, config.QueryTimeout), db, nil
+}
 
-			if err != nil {
-				slog.Error(
-					"error creating sending request",
-					slog.Any("error", err),
-				)
-				return
-			}
-			defer res.Body.Close()
+// RunMigrate runs the migrate subcommand:
+//
+//	chronos_bot migrate [up | down [steps] | status]
+func RunMigrate(config repository.DatabaseConfig, args []string) error {
+	_, db, err := OpenRepository(config)
+	if err != nil {
+		return err
+	}
+	if db == nil {
+		return errors.New("the memory database has no migrations")
+	}
+	defer db.Close()
 
-			slog.Info(
-				"getUpdates response from tapi",
-				slog.Int("status", res.StatusCode),
-			)
+	driver := config.Driver
+	if driver == "" {
+		driver = repository.PostgresDriver
+	}
+	migrator := repository.NewMigrator(db, driver)
+	ctx := context.Background()
 
-			updateResponse := entities.ResponseGetUpdates{}
-			err = json.NewDecoder(res.Body).Decode(&updateResponse)
-			if err != nil {
-				slog.Error("error parsing response", slog.Any("error", err))
-				return
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "up":
+		migrations, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		fmt.Printf("%d migrations applied\n", len(migrations))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
 			}
+		}
 
-			// sending updates
-			for _, update := range updateResponse.Result {
-				requestBytes, err := json.MarshalIndent(update, "", "  ")
-
-				if err != nil {
-					slog.Error(
-						"error marshaling request",
-						slog.Any("error", err),
-					)
-				}
-
-				req, err := http.NewRequest(
-					"POST",
-					"http://localhost:8080/getUpdates",
-					bytes.NewBuffer(requestBytes),
-				)
+		migrations, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		fmt.Printf("%d migrations reverted\n", len(migrations))
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%04d_%s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, use up, down or status", command)
+	}
+	return nil
+}
 
-				if err != nil {
-					slog.Error(
-						"error creating new request",
-						slog.Any("error", err),
-					)
-					continue
-				}
-
-				res, err := client.Do(req)
-
-				if err != nil {
-					slog.Error(
-						"error creating sending request",
-						slog.Any("error", err),
-					)
-					continue
-				}
-
-				slog.Info(
-					"getUpdates response",
-					slog.Int("status", res.StatusCode),
-				)
+// NewStateStore creates the state store of bot in the database of the
+// configured driver. its table is created by the migrations of the
+// repository.
+func NewStateStore(
+	driver string,
+	db *sql.DB,
+	bot string,
+	ttl time.Duration,
+	queryTimeout time.Duration,
+) state.StateStore {
+	switch driver {
+	case repository.MemoryDriver:
+		return state.NewMemoryStateStore(ttl)
+	case repository.SqliteDriver:
+		return state.NewSqliteStateStore(db, bot, ttl, queryTimeout)
+	default:
+		return state.NewPostgresStateStore(db, bot, ttl, queryTimeout)
+	}
+}
 
+// RunUpdateSources runs the update sources of the bots until ctx is done.
+// all of them are stopped if one fails. it reports whether any failed.
+func RunUpdateSources(
+	ctx context.Context,
+	stop context.CancelFunc,
+	bots []BotConfig,
+	updateSources []updates.UpdateSource,
+) bool {
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	for i, updateSource := range updateSources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := updateSource.Run(ctx)
+			if err != nil {
+				slog.Error(
+					"update source stopped",
+					slog.String("bot", bots[i].Name),
+					slog.Any("err", err),
+				)
+				failed.Store(true)
+				stop()
 			}
 		}()
-
 	}
+	wg.Wait()
+	return failed.Load()
 }
 
-func RunDailyJob(scheduler scheduler.Scheduler) {
+// RunDailyJob launches the schedules of every day until ctx is done.
+func RunDailyJob(ctx context.Context, awxScheduler scheduler.Scheduler) {
+	// the schedules of the current minute may have fired before a restart.
 	now := time.Now()
-	startTime := entities.CalendarTime{Hour: now.Hour(), Minute: now.Minute()}
+	startTime, ok := scheduler.NextMinuteOfToday(now)
 	endTime := entities.CalendarTime{Hour: 23, Minute: 59}
 	for {
-		err := LunchDailyScheduler(scheduler, startTime, endTime)
-		if err != nil {
-			slog.Error("error running daily job", slog.Any("error", err))
+		if ok {
+			err := LunchDailyScheduler(awxScheduler, startTime, endTime)
+			if err != nil {
+				slog.Error("error running daily job", slog.Any("error", err))
+			}
 		}
 
 		todayMidnight := time.Date(
@@ -245,12 +444,67 @@ func RunDailyJob(scheduler scheduler.Scheduler) {
 		tomorrowMidnight := todayMidnight.AddDate(0, 0, 1)
 		duration := tomorrowMidnight.Sub(now)
 
-		time.Sleep(duration)
+		if !sleep(ctx, duration) {
+			return
+		}
 		now = time.Now()
-		startTime.Hour = 0
-		startTime.Minute = 0
+		startTime = entities.CalendarTime{}
+		ok = true
 	}
+}
 
+func RunCleanupJob(
+	ctx context.Context,
+	stateStores []state.StateStore,
+	repo repository.Repository,
+	trashRetention time.Duration,
+) {
+	for {
+		for _, stateStore := range stateStores {
+			err := stateStore.DeleteExpired(ctx)
+			if err != nil {
+				slog.Error("error deleting expired states", slog.Any("error", err))
+			}
+		}
+
+		err := repo.DeleteProcessedUpdatesBefore(
+			ctx,
+			time.Now().Add(-processedUpdateRetention),
+		)
+		if err != nil {
+			slog.Error(
+				"error deleting processed updates",
+				slog.Any("error", err),
+			)
+		}
+
+		err = handler.PurgeTrash(
+			ctx,
+			repo,
+			time.Now().Add(-trashRetention),
+		)
+		if err != nil {
+			slog.Error(
+				"error purging deleted feature flags",
+				slog.Any("error", err),
+			)
+		}
+		if !sleep(ctx, time.Hour) {
+			return
+		}
+	}
+}
+
+// sleep waits for d and reports whether ctx is still alive.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
 }
 
 func LunchDailyScheduler(
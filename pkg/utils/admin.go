@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+// FeatureFlagsToMessages lists the feature flags with their workspace and
+// owner, split into as many messages as needed.
+func FeatureFlagsToMessages(featureFlags []entities.FeatureFlag) []string {
+	entries := make([]string, 0, len(featureFlags))
+	for _, featureFlag := range featureFlags {
+		entry := fmt.Sprintf(
+			"فضای کاری %d | %s | مالک %d",
+			featureFlag.WorkspaceId,
+			featureFlag.Name,
+			featureFlag.OwnerId,
+		)
+		if featureFlag.Paused {
+			entry += " | متوقف"
+		}
+		entries = append(entries, entry+"\n")
+	}
+	return SplitMessages(entries)
+}
+
+func HealthToText(
+	uptime time.Duration,
+	dbLatency time.Duration,
+	schedulingPaused bool,
+	stats entities.Stats,
+) string {
+	scheduling := "در حال اجرا"
+	if schedulingPaused {
+		scheduling = "متوقف"
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "مدت فعالیت: %s\n", uptime.Round(time.Second))
+	fmt.Fprintf(&text, "تاخیر پایگاه داده: %s\n", dbLatency.Round(time.Millisecond))
+	fmt.Fprintf(&text, "اجرای برنامه‌ها: %s\n", scheduling)
+	fmt.Fprintf(&text, "پرچم‌ها: %d\n", stats.FeatureFlags)
+	fmt.Fprintf(&text, "پرچم‌های سطل زباله: %d\n", stats.DeletedFeatureFlags)
+	fmt.Fprintf(&text, "برنامه‌های زمانی: %d\n", stats.Schedules)
+	fmt.Fprintf(&text, "برنامه‌های در انتظار تایید: %d\n", stats.PendingSchedules)
+	fmt.Fprintf(&text, "فضاهای کاری: %d\n", stats.Workspaces)
+	fmt.Fprintf(&text, "کاربران: %d", stats.BotUsers)
+	return text.String()
+}
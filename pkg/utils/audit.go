@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+// maxMessageLength keeps messages below the 4096 characters bale accepts.
+const maxMessageLength = 4000
+
+// SplitMessages joins entries into as few messages as possible while
+// staying under the message length limit. entries are never split.
+func SplitMessages(entries []string) []string {
+	var messages []string
+	var text strings.Builder
+	for _, entry := range entries {
+		if text.Len() > 0 && len([]rune(text.String()+entry)) > maxMessageLength {
+			messages = append(messages, text.String())
+			text.Reset()
+		}
+		text.WriteString(entry)
+	}
+	return append(messages, text.String())
+}
+
+// AuditLogsToMessages describes the audit logs, split into as many messages
+// as needed to stay under the message length limit.
+func AuditLogsToMessages(auditLogs []entities.AuditLog) []string {
+	entries := make([]string, 0, len(auditLogs))
+	for _, auditLog := range auditLogs {
+		entries = append(entries, AuditLogToText(auditLog))
+	}
+	return SplitMessages(entries)
+}
+
+func AuditLogToText(auditLog entities.AuditLog) string {
+	actor := fmt.Sprintf("کاربر %d", auditLog.ActorId)
+	if auditLog.ActorId == entities.SystemActorId {
+		actor = "سیستم"
+	}
+
+	var text strings.Builder
+	text.WriteString(
+		fmt.Sprintf(
+			"%s | %s | %s %s",
+			time.Unix(auditLog.UnixTime, 0).Format("2006-01-02 15:04"),
+			actor,
+			AuditActionToText(auditLog.Action),
+			AuditEntityToText(auditLog.Entity),
+		),
+	)
+	if auditLog.FeatureFlagName != "" {
+		text.WriteString(fmt.Sprintf(" | پرچم %s", auditLog.FeatureFlagName))
+	}
+	if auditLog.WorkspaceId != 0 {
+		text.WriteString(fmt.Sprintf(" | فضای کاری %d", auditLog.WorkspaceId))
+	}
+	text.WriteString("\n")
+
+	if auditLog.Before != "" {
+		text.WriteString(fmt.Sprintf("قبل: %s\n", auditLog.Before))
+	}
+	if auditLog.After != "" {
+		text.WriteString(fmt.Sprintf("بعد: %s\n", auditLog.After))
+	}
+	text.WriteString("\n")
+	return text.String()
+}
+
+func AuditActionToText(action entities.AuditAction) string {
+	switch action {
+	case entities.CreateAuditAction:
+		return "ایجاد"
+	case entities.UpdateAuditAction:
+		return "تغییر"
+	case entities.DeleteAuditAction:
+		return "حذف"
+	default:
+		return string(action)
+	}
+}
+
+func AuditEntityToText(entity entities.AuditEntity) string {
+	switch entity {
+	case entities.FeatureFlagAuditEntity:
+		return "پرچم"
+	case entities.ScheduleAuditEntity:
+		return "برنامه زمانی"
+	case entities.FeatureFlagMemberAuditEntity:
+		return "عضو پرچم"
+	case entities.WorkspaceAuditEntity:
+		return "فضای کاری"
+	case entities.WorkspaceMemberAuditEntity:
+		return "عضو فضای کاری"
+	case entities.SchedulingAuditEntity:
+		return "اجرای برنامه‌ها"
+	case entities.AdminAuditEntity:
+		return "مدیر ربات"
+	case entities.AccessAuditEntity:
+		return "دسترسی به ربات"
+	default:
+		return string(entity)
+	}
+}
@@ -12,3 +12,14 @@ func GetCalendarByType(cType entities.CalendarType) entities.Calendar {
 		return entities.GeorgianCalendar{}
 	}
 }
+
+func CalendarTypeToText(cType entities.CalendarType) string {
+	switch cType {
+	case entities.KhorshidiCalendarType:
+		return "خورشیدی"
+	case entities.QamariCalendarType:
+		return "قمری"
+	default:
+		return "میلادی"
+	}
+}
@@ -1,8 +1,11 @@
 package utils
 
 import (
-	"github.com/fatemehkarimi/chronos_bot/entities"
+	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
 )
 
 func CallbackDataToCalendarType(data string) entities.CalendarType {
@@ -23,3 +26,27 @@ func GetFeatureFlagNameFromCallbackData(data string) string {
 	)
 	return featureFlagName
 }
+
+// GetIdFromCallbackData parses callback data in the form of "<prefix><id>".
+func GetIdFromCallbackData(data, prefix string) (int, error) {
+	return strconv.Atoi(strings.TrimPrefix(data, prefix))
+}
+
+func GetScheduleIdFromCallbackData(data, prefix string) (int, error) {
+	return GetIdFromCallbackData(data, prefix)
+}
+
+// GetMemberFromCallbackData parses callback data in the form of
+// "<prefix><user id> <feature flag>".
+func GetMemberFromCallbackData(data, prefix string) (int, string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(data, prefix), " ", 2)
+	if len(parts) != 2 {
+		return 0, "", fmt.Errorf("invalid member callback data %q", data)
+	}
+
+	userId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", err
+	}
+	return userId, parts[1], nil
+}
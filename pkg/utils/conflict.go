@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+const (
+	// ConflictWindow is the largest distance between two fire times of a
+	// feature flag that is still reported as a conflict.
+	ConflictWindow = 30 * time.Minute
+
+	// conflictOccurrenceCount is the number of upcoming fire times of each
+	// schedule that are compared with each other.
+	conflictOccurrenceCount = 10
+)
+
+type ScheduleConflict struct {
+	Existing     entities.Schedule
+	NewTime      time.Time
+	ExistingTime time.Time
+}
+
+// FindScheduleConflicts compares the upcoming fire times of schedule with the
+// existing schedules of the same feature flag, whatever their calendar, and
+// returns the first pair of fire times closer than window for each of them.
+// both schedules of a pair are expanded up to the later of their
+// conflictOccurrenceCount-th fire times, so that a frequent schedule is not
+// cut off before a rare one first fires.
+func FindScheduleConflicts(
+	ctx context.Context,
+	schedule entities.Schedule,
+	existing []entities.Schedule,
+	from time.Time,
+	window time.Duration,
+) ([]ScheduleConflict, error) {
+	newOccurrences, err := GetCalendarByType(schedule.Calendar.Type).NextOccurrences(
+		ctx,
+		schedule.Calendar,
+		from,
+		conflictOccurrenceCount,
+	)
+	if err != nil || len(newOccurrences) == 0 {
+		return nil, err
+	}
+
+	var conflicts []ScheduleConflict
+	for _, other := range existing {
+		if other.ScheduleId == schedule.ScheduleId ||
+			!UsersListsOverlap(schedule.UsersList, other.UsersList) {
+			continue
+		}
+
+		otherOccurrences, err := GetCalendarByType(other.Calendar.Type).NextOccurrences(
+			ctx,
+			other.Calendar,
+			from,
+			conflictOccurrenceCount,
+		)
+		if err != nil {
+			return conflicts, err
+		}
+		if len(otherOccurrences) == 0 {
+			continue
+		}
+
+		horizon := newOccurrences[len(newOccurrences)-1].Time
+		if last := otherOccurrences[len(otherOccurrences)-1].Time; last.After(horizon) {
+			horizon = last
+		}
+		horizon = horizon.Add(window)
+
+		expandedNew, err := occurrencesUntil(ctx, schedule.Calendar, from, horizon, newOccurrences)
+		if err != nil {
+			return conflicts, err
+		}
+		expandedOther, err := occurrencesUntil(ctx, other.Calendar, from, horizon, otherOccurrences)
+		if err != nil {
+			return conflicts, err
+		}
+
+		if conflict, ok := findClosePair(
+			expandedNew,
+			expandedOther,
+			window,
+		); ok {
+			conflict.Existing = other
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	return conflicts, nil
+}
+
+// occurrencesUntil extends occurrences, the first fire times of pattern from
+// from, until they pass until or the calendar has no more of them.
+// occurrences must not be empty.
+func occurrencesUntil(
+	ctx context.Context,
+	pattern entities.CalendarTime,
+	from time.Time,
+	until time.Time,
+	occurrences []entities.Occurrence,
+) ([]entities.Occurrence, error) {
+	calendar := GetCalendarByType(pattern.Type)
+	for !occurrences[len(occurrences)-1].Time.After(until) {
+		more, err := calendar.NextOccurrences(ctx, pattern, from, 2*len(occurrences))
+		if err != nil {
+			return occurrences, err
+		}
+		if len(more) <= len(occurrences) {
+			break
+		}
+		occurrences = more
+	}
+	return occurrences, nil
+}
+
+func findClosePair(
+	newOccurrences, otherOccurrences []entities.Occurrence,
+	window time.Duration,
+) (ScheduleConflict, bool) {
+	for _, newOccurrence := range newOccurrences {
+		for _, otherOccurrence := range otherOccurrences {
+			diff := newOccurrence.Time.Sub(otherOccurrence.Time)
+			if diff.Abs() <= window {
+				return ScheduleConflict{
+					NewTime:      newOccurrence.Time,
+					ExistingTime: otherOccurrence.Time,
+				}, true
+			}
+		}
+	}
+	return ScheduleConflict{}, false
+}
+
+// UsersListsOverlap reports whether two users lists of schedules share a
+// user. "*" stands for all users.
+func UsersListsOverlap(a, b string) bool {
+	usersA := splitUsersList(a)
+	usersB := splitUsersList(b)
+	if usersA["*"] || usersB["*"] {
+		return true
+	}
+
+	for user := range usersA {
+		if usersB[user] {
+			return true
+		}
+	}
+	return false
+}
+
+func splitUsersList(usersList string) map[string]bool {
+	users := map[string]bool{}
+	fields := strings.FieldsFunc(usersList, func(r rune) bool {
+		return r == ',' || r == '،' || r == ' ' || r == '\n'
+	})
+	for _, field := range fields {
+		users[field] = true
+	}
+	return users
+}
+
+func ScheduleConflictsToText(
+	schedule entities.Schedule,
+	conflicts []ScheduleConflict,
+) string {
+	var text strings.Builder
+	text.WriteString("این برنامه زمانی با برنامه‌های زیر از همین پرچم هم‌پوشانی دارد:\n")
+	for _, conflict := range conflicts {
+		kind := "هم‌زمان"
+		if !conflict.NewTime.Equal(conflict.ExistingTime) {
+			kind = fmt.Sprintf(
+				"با فاصله %d دقیقه",
+				int((conflict.NewTime.Sub(conflict.ExistingTime)).Abs().Minutes()),
+			)
+		}
+
+		valueText := "مقدار یکسان"
+		if conflict.Existing.Value != schedule.Value {
+			valueText = fmt.Sprintf("مقدار متفاوت: %s", conflict.Existing.Value)
+		}
+
+		text.WriteString(
+			fmt.Sprintf(
+				"- برنامه %d (%s): %s در %s، %s\n",
+				conflict.Existing.ScheduleId,
+				CalendarTypeToText(conflict.Existing.Calendar.Type),
+				kind,
+				conflict.ExistingTime.Format("2006-01-02 15:04"),
+				valueText,
+			),
+		)
+	}
+	text.WriteString("\nآیا می‌خواهید برنامه را در هر صورت ذخیره کنید؟")
+	return text.String()
+}
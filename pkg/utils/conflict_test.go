@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+func TestFindScheduleConflictsBeyondFirstOccurrences(t *testing.T) {
+	yearly := entities.Schedule{
+		ScheduleId: 1,
+		UsersList:  "*",
+		Calendar: entities.CalendarTime{
+			Type:  entities.GeorgianCalendarType,
+			Month: 5,
+			Day:   1,
+			Hour:  10,
+		},
+	}
+	monthly := entities.Schedule{
+		ScheduleId: 2,
+		UsersList:  "*",
+		Calendar: entities.CalendarTime{
+			Type: entities.GeorgianCalendarType,
+			Day:  1,
+			Hour: 10,
+		},
+	}
+	from := time.Date(2026, 6, 15, 0, 0, 0, 0, time.UTC)
+	want := time.Date(2027, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule entities.Schedule
+		existing entities.Schedule
+	}{
+		{"rare new schedule", yearly, monthly},
+		{"rare existing schedule", monthly, yearly},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conflicts, err := FindScheduleConflicts(
+				t.Context(),
+				test.schedule,
+				[]entities.Schedule{test.existing},
+				from,
+				ConflictWindow,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(conflicts) != 1 {
+				t.Fatalf("got %d conflicts, want 1", len(conflicts))
+			}
+			if !conflicts[0].NewTime.Equal(want) || !conflicts[0].ExistingTime.Equal(want) {
+				t.Errorf(
+					"got conflict at %v and %v, want both at %v",
+					conflicts[0].NewTime,
+					conflicts[0].ExistingTime,
+					want,
+				)
+			}
+		})
+	}
+}
+
+func TestFindScheduleConflictsDisjointSchedules(t *testing.T) {
+	schedule := entities.Schedule{
+		ScheduleId: 1,
+		UsersList:  "*",
+		Calendar:   entities.CalendarTime{Type: entities.GeorgianCalendarType, Day: 1, Hour: 10},
+	}
+	other := entities.Schedule{
+		ScheduleId: 2,
+		UsersList:  "*",
+		Calendar:   entities.CalendarTime{Type: entities.GeorgianCalendarType, Day: 2, Hour: 10},
+	}
+
+	conflicts, err := FindScheduleConflicts(
+		t.Context(),
+		schedule,
+		[]entities.Schedule{other},
+		time.Date(2026, 6, 15, 0, 0, 0, 0, time.UTC),
+		ConflictWindow,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("got conflicts %+v for schedules a day apart", conflicts)
+	}
+}
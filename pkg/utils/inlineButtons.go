@@ -7,8 +7,9 @@ import (
 )
 
 const (
-	AddFeatureFlagCallbackData = "add feature_flag"
-	AddScheduleCallbackData    = "add scheduler"
+	AddFeatureFlagCallbackData             = "add feature_flag"
+	AddScheduleCallbackData                = "add scheduler"
+	AddFeatureFlagWithScheduleCallbackData = "add feature_flag_with_schedule"
 
 	KhorshidiCalendarCallbackData = "khorshidi calendar"
 	GeorgianCalendarCallbackData  = "georgian calendar"
@@ -18,13 +19,49 @@ const (
 
 	ViewFeatureFlagsCallbackData = "view feature_flags"
 	DeleteFeatureFlagCallbakData = "delete feature_flag"
+
+	TrashCallbackData                    = "view trash"
+	RestoreFeatureFlagCallbackDataPrefix = "restore feature_flag "
+
+	ManageFeatureFlagsCallbackData      = "manage feature_flags"
+	PauseFeatureFlagCallbackDataPrefix  = "pause feature_flag "
+	ResumeFeatureFlagCallbackDataPrefix = "resume feature_flag "
+	PauseScheduleCallbackDataPrefix     = "pause schedule "
+	ResumeScheduleCallbackDataPrefix    = "resume schedule "
+	ApproveScheduleCallbackDataPrefix   = "approve schedule "
+	RejectScheduleCallbackDataPrefix    = "reject schedule "
+	PauseAllCallbackData                = "pause all"
+	ResumeAllCallbackData               = "resume all"
+
+	ConfirmSchedulePatternCallbackData = "confirm schedule_pattern"
+	EditSchedulePatternCallbackData    = "edit schedule_pattern"
+
+	SaveScheduleAnywayCallbackData = "save schedule_anyway"
+	CancelScheduleCallbackData     = "cancel schedule"
+
+	InviteEditorCallbackDataPrefix       = "invite editor "
+	InviteViewerCallbackDataPrefix       = "invite viewer "
+	TransferOwnershipCallbackDataPrefix  = "transfer feature_flag "
+	FeatureFlagMembersCallbackDataPrefix = "members feature_flag "
+	RemoveMemberCallbackDataPrefix       = "remove member "
+
+	WorkspacesCallbackData                  = "view workspaces"
+	SwitchWorkspaceCallbackDataPrefix       = "switch workspace "
+	CreateWorkspaceCallbackData             = "create workspace"
+	WorkspaceMembersCallbackData            = "members workspace"
+	AddWorkspaceMemberCallbackData          = "add workspace_member"
+	RemoveWorkspaceMemberCallbackDataPrefix = "remove workspace_member "
 )
 
 func GetMainReplyMarkup() entities.ReplyMarkup {
 	scheduleCallbackData := AddScheduleCallbackData
 	featureFlagCallbackData := AddFeatureFlagCallbackData
+	featureFlagWithScheduleCallbackData := AddFeatureFlagWithScheduleCallbackData
 	viewFeatureFlagsCallbackData := ViewFeatureFlagsCallbackData
 	deleteFeatureFlagCallbackData := DeleteFeatureFlagCallbakData
+	manageFeatureFlagsCallbackData := ManageFeatureFlagsCallbackData
+	trashCallbackData := TrashCallbackData
+	workspacesCallbackData := WorkspacesCallbackData
 
 	replyMarkup := entities.InlineKeyboardMarkup{
 		InlineKeyboard: [][]entities.InlineKeyboardButton{
@@ -46,17 +83,63 @@ func GetMainReplyMarkup() entities.ReplyMarkup {
 					CallbackData: &deleteFeatureFlagCallbackData,
 				},
 			},
+			{
+				entities.InlineKeyboardButton{
+					Text:         "سطل زباله",
+					CallbackData: &trashCallbackData,
+				},
+			},
 			{
 				entities.InlineKeyboardButton{
 					Text:         "افزودن برنامه زمانی",
 					CallbackData: &scheduleCallbackData,
 				},
 			},
+			{
+				entities.InlineKeyboardButton{
+					Text:         "افزودن پرچم با برنامه زمانی",
+					CallbackData: &featureFlagWithScheduleCallbackData,
+				},
+			},
+			{
+				entities.InlineKeyboardButton{
+					Text:         "توقف و ادامه برنامه‌ها",
+					CallbackData: &manageFeatureFlagsCallbackData,
+				},
+			},
+			{
+				entities.InlineKeyboardButton{
+					Text:         "فضای کاری",
+					CallbackData: &workspacesCallbackData,
+				},
+			},
 		},
 	}
 	return replyMarkup
 }
 
+func GetAdminMainReplyMarkup(schedulingPaused bool) entities.ReplyMarkup {
+	replyMarkup := GetMainReplyMarkup().(entities.InlineKeyboardMarkup)
+
+	text := "توقف همه‌ی برنامه‌ها"
+	callbackData := PauseAllCallbackData
+	if schedulingPaused {
+		text = "ادامه همه‌ی برنامه‌ها"
+		callbackData = ResumeAllCallbackData
+	}
+
+	replyMarkup.InlineKeyboard = append(
+		replyMarkup.InlineKeyboard,
+		[]entities.InlineKeyboardButton{
+			{
+				Text:         text,
+				CallbackData: &callbackData,
+			},
+		},
+	)
+	return replyMarkup
+}
+
 func GetScheduleReplyMarkup() entities.ReplyMarkup {
 	khorshidiCalendarCallbackData := KhorshidiCalendarCallbackData
 	georgianCalendarCallbackData := GeorgianCalendarCallbackData
@@ -104,6 +187,43 @@ func GetReplyMarkupFromFeatureFlags(featureFlags []entities.FeatureFlag) entitie
 	}
 }
 
+// GetTrashReplyMarkup has a restore button for every feature flag in the
+// trash.
+func GetTrashReplyMarkup(featureFlags []entities.FeatureFlag) entities.ReplyMarkup {
+	inlineKeyboard := make([][]entities.InlineKeyboardButton, len(featureFlags))
+	for idx, featureFlag := range featureFlags {
+		callbackData := RestoreFeatureFlagCallbackDataPrefix + featureFlag.Name
+		inlineKeyboard[idx] = []entities.InlineKeyboardButton{
+			{
+				Text:         fmt.Sprintf("بازگردانی %s", featureFlag.Name),
+				CallbackData: &callbackData,
+			},
+		}
+	}
+	return entities.InlineKeyboardMarkup{
+		InlineKeyboard: inlineKeyboard,
+	}
+}
+
+func GetScheduleApprovalReplyMarkup(scheduleId int) entities.ReplyMarkup {
+	approveCallbackData := fmt.Sprintf("%s%d", ApproveScheduleCallbackDataPrefix, scheduleId)
+	rejectCallbackData := fmt.Sprintf("%s%d", RejectScheduleCallbackDataPrefix, scheduleId)
+	return entities.InlineKeyboardMarkup{
+		InlineKeyboard: [][]entities.InlineKeyboardButton{
+			{
+				entities.InlineKeyboardButton{
+					Text:         "تایید",
+					CallbackData: &approveCallbackData,
+				},
+				entities.InlineKeyboardButton{
+					Text:         "رد",
+					CallbackData: &rejectCallbackData,
+				},
+			},
+		},
+	}
+}
+
 func GetUsersListCReplyMarkup() entities.ReplyMarkup {
 	usersListCallbackData := UsersListForAllCallbackData
 	replyMarkup := entities.InlineKeyboardMarkup{
@@ -118,3 +238,276 @@ func GetUsersListCReplyMarkup() entities.ReplyMarkup {
 	}
 	return replyMarkup
 }
+
+func GetFeatureFlagStatusReplyMarkup(
+	featureFlag entities.FeatureFlag,
+	schedules []entities.Schedule,
+	role entities.Role,
+) entities.ReplyMarkup {
+	inlineKeyboard := make([][]entities.InlineKeyboardButton, 0, len(schedules)+5)
+	if role < entities.EditorRole {
+		return entities.InlineKeyboardMarkup{
+			InlineKeyboard: inlineKeyboard,
+		}
+	}
+
+	flagText := fmt.Sprintf("توقف پرچم %s", featureFlag.Name)
+	flagCallbackData := PauseFeatureFlagCallbackDataPrefix + featureFlag.Name
+	if featureFlag.Paused {
+		flagText = fmt.Sprintf("ادامه پرچم %s", featureFlag.Name)
+		flagCallbackData = ResumeFeatureFlagCallbackDataPrefix + featureFlag.Name
+	}
+	inlineKeyboard = append(
+		inlineKeyboard,
+		[]entities.InlineKeyboardButton{
+			{
+				Text:         flagText,
+				CallbackData: &flagCallbackData,
+			},
+		},
+	)
+
+	for _, schedule := range schedules {
+		text := fmt.Sprintf("توقف برنامه %d", schedule.ScheduleId)
+		callbackData := fmt.Sprintf(
+			"%s%d",
+			PauseScheduleCallbackDataPrefix,
+			schedule.ScheduleId,
+		)
+		if schedule.Paused {
+			text = fmt.Sprintf("ادامه برنامه %d", schedule.ScheduleId)
+			callbackData = fmt.Sprintf(
+				"%s%d",
+				ResumeScheduleCallbackDataPrefix,
+				schedule.ScheduleId,
+			)
+		}
+		inlineKeyboard = append(
+			inlineKeyboard,
+			[]entities.InlineKeyboardButton{
+				{
+					Text:         text,
+					CallbackData: &callbackData,
+				},
+			},
+		)
+	}
+
+	if role == entities.OwnerRole {
+		inlineKeyboard = append(
+			inlineKeyboard,
+			GetFeatureFlagOwnerButtons(featureFlag.Name)...,
+		)
+	}
+
+	return entities.InlineKeyboardMarkup{
+		InlineKeyboard: inlineKeyboard,
+	}
+}
+
+func GetFeatureFlagOwnerButtons(
+	featureFlagName string,
+) [][]entities.InlineKeyboardButton {
+	inviteEditorCallbackData := InviteEditorCallbackDataPrefix + featureFlagName
+	inviteViewerCallbackData := InviteViewerCallbackDataPrefix + featureFlagName
+	membersCallbackData := FeatureFlagMembersCallbackDataPrefix + featureFlagName
+	transferCallbackData := TransferOwnershipCallbackDataPrefix + featureFlagName
+
+	return [][]entities.InlineKeyboardButton{
+		{
+			entities.InlineKeyboardButton{
+				Text:         "افزودن ویرایشگر",
+				CallbackData: &inviteEditorCallbackData,
+			},
+		},
+		{
+			entities.InlineKeyboardButton{
+				Text:         "افزودن بیننده",
+				CallbackData: &inviteViewerCallbackData,
+			},
+		},
+		{
+			entities.InlineKeyboardButton{
+				Text:         "اعضای پرچم",
+				CallbackData: &membersCallbackData,
+			},
+		},
+		{
+			entities.InlineKeyboardButton{
+				Text:         "انتقال مالکیت",
+				CallbackData: &transferCallbackData,
+			},
+		},
+	}
+}
+
+func GetFeatureFlagMembersReplyMarkup(
+	featureFlagName string,
+	members []entities.FeatureFlagMember,
+) entities.ReplyMarkup {
+	var inlineKeyboard [][]entities.InlineKeyboardButton
+	for _, member := range members {
+		if member.Role == entities.OwnerRole {
+			continue
+		}
+
+		callbackData := fmt.Sprintf(
+			"%s%d %s",
+			RemoveMemberCallbackDataPrefix,
+			member.UserId,
+			featureFlagName,
+		)
+		inlineKeyboard = append(
+			inlineKeyboard,
+			[]entities.InlineKeyboardButton{
+				{
+					Text:         fmt.Sprintf("حذف %d", member.UserId),
+					CallbackData: &callbackData,
+				},
+			},
+		)
+	}
+
+	return entities.InlineKeyboardMarkup{
+		InlineKeyboard: inlineKeyboard,
+	}
+}
+
+func GetConfirmSchedulePatternReplyMarkup() entities.ReplyMarkup {
+	confirmCallbackData := ConfirmSchedulePatternCallbackData
+	editCallbackData := EditSchedulePatternCallbackData
+	replyMarkup := entities.InlineKeyboardMarkup{
+		InlineKeyboard: [][]entities.InlineKeyboardButton{
+			{
+				entities.InlineKeyboardButton{
+					Text:         "تایید الگو",
+					CallbackData: &confirmCallbackData,
+				},
+			},
+			{
+				entities.InlineKeyboardButton{
+					Text:         "اصلاح الگو",
+					CallbackData: &editCallbackData,
+				},
+			},
+		},
+	}
+	return replyMarkup
+}
+
+func GetConfirmScheduleConflictReplyMarkup() entities.ReplyMarkup {
+	saveCallbackData := SaveScheduleAnywayCallbackData
+	cancelCallbackData := CancelScheduleCallbackData
+	replyMarkup := entities.InlineKeyboardMarkup{
+		InlineKeyboard: [][]entities.InlineKeyboardButton{
+			{
+				entities.InlineKeyboardButton{
+					Text:         "ذخیره در هر صورت",
+					CallbackData: &saveCallbackData,
+				},
+			},
+			{
+				entities.InlineKeyboardButton{
+					Text:         "لغو",
+					CallbackData: &cancelCallbackData,
+				},
+			},
+		},
+	}
+	return replyMarkup
+}
+
+func GetWorkspacesReplyMarkup(
+	workspaces []entities.Workspace,
+	currentWorkspaceId int,
+	canManage bool,
+) entities.ReplyMarkup {
+	inlineKeyboard := make([][]entities.InlineKeyboardButton, 0, len(workspaces)+2)
+	for _, workspace := range workspaces {
+		text := workspace.Name
+		if workspace.WorkspaceId == currentWorkspaceId {
+			text = fmt.Sprintf("%s (فعلی)", workspace.Name)
+		}
+		callbackData := fmt.Sprintf(
+			"%s%d",
+			SwitchWorkspaceCallbackDataPrefix,
+			workspace.WorkspaceId,
+		)
+		inlineKeyboard = append(
+			inlineKeyboard,
+			[]entities.InlineKeyboardButton{
+				{
+					Text:         text,
+					CallbackData: &callbackData,
+				},
+			},
+		)
+	}
+
+	createCallbackData := CreateWorkspaceCallbackData
+	inlineKeyboard = append(
+		inlineKeyboard,
+		[]entities.InlineKeyboardButton{
+			{
+				Text:         "ساخت فضای کاری",
+				CallbackData: &createCallbackData,
+			},
+		},
+	)
+
+	if canManage {
+		membersCallbackData := WorkspaceMembersCallbackData
+		inlineKeyboard = append(
+			inlineKeyboard,
+			[]entities.InlineKeyboardButton{
+				{
+					Text:         "اعضای فضای کاری",
+					CallbackData: &membersCallbackData,
+				},
+			},
+		)
+	}
+
+	return entities.InlineKeyboardMarkup{
+		InlineKeyboard: inlineKeyboard,
+	}
+}
+
+func GetWorkspaceMembersReplyMarkup(
+	members []entities.WorkspaceMember,
+) entities.ReplyMarkup {
+	addCallbackData := AddWorkspaceMemberCallbackData
+	inlineKeyboard := [][]entities.InlineKeyboardButton{
+		{
+			entities.InlineKeyboardButton{
+				Text:         "افزودن عضو",
+				CallbackData: &addCallbackData,
+			},
+		},
+	}
+
+	for _, member := range members {
+		if member.Role == entities.OwnerRole {
+			continue
+		}
+
+		callbackData := fmt.Sprintf(
+			"%s%d",
+			RemoveWorkspaceMemberCallbackDataPrefix,
+			member.UserId,
+		)
+		inlineKeyboard = append(
+			inlineKeyboard,
+			[]entities.InlineKeyboardButton{
+				{
+					Text:         fmt.Sprintf("حذف %d", member.UserId),
+					CallbackData: &callbackData,
+				},
+			},
+		)
+	}
+
+	return entities.InlineKeyboardMarkup{
+		InlineKeyboard: inlineKeyboard,
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -70,6 +71,7 @@ func ParseSchedulePattern(pattern string) (*entities.Schedule, error) {
 }
 
 func ScheduleTaskOnSameDay(
+	ctx context.Context,
 	dayTime entities.CalendarTime,
 	task func() error,
 ) error {
@@ -87,7 +89,14 @@ func ScheduleTaskOnSameDay(
 	diff := scheduleTime.Sub(now)
 
 	timer := time.NewTimer(diff)
-	tick := <-timer.C
+	defer timer.Stop()
+
+	var tick time.Time
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case tick = <-timer.C:
+	}
 
 	slog.Info("performing task at time = ", slog.Time("time", tick))
 	err := task()
@@ -124,3 +133,113 @@ func ShouldRunToday(
 		((schedule.Calendar.Hour == hour && schedule.Calendar.Minute >= minute) ||
 			(schedule.Calendar.Hour > hour))
 }
+
+func FeatureFlagStatusToText(
+	featureFlag entities.FeatureFlag,
+	schedules []entities.Schedule,
+	role entities.Role,
+) string {
+	var text strings.Builder
+	text.WriteString(
+		fmt.Sprintf(
+			"پرچم: %s\nوضعیت: %s\nنقش شما: %s\n",
+			featureFlag.Name,
+			PausedToText(featureFlag.Paused),
+			RoleToText(role),
+		),
+	)
+
+	if len(schedules) == 0 {
+		text.WriteString("برنامه زمانی برای این پرچم ثبت نشده است.")
+		return text.String()
+	}
+
+	text.WriteString("\nبرنامه‌های زمانی:\n")
+	for _, schedule := range schedules {
+		status := PausedToText(schedule.Paused)
+		if approval := ApprovalToText(schedule.Approval); approval != "" {
+			status = approval
+		}
+		text.WriteString(
+			fmt.Sprintf(
+				"%d. y:%d m:%d d:%d hh:%d mm:%d | مقدار: %s | %s\n",
+				schedule.ScheduleId,
+				schedule.Calendar.Year,
+				schedule.Calendar.Month,
+				schedule.Calendar.Day,
+				schedule.Calendar.Hour,
+				schedule.Calendar.Minute,
+				schedule.Value,
+				status,
+			),
+		)
+	}
+	return text.String()
+}
+
+// ApprovalToText describes approvals that keep a schedule from running. it
+// is empty for approved schedules.
+func ApprovalToText(approval entities.Approval) string {
+	switch approval {
+	case entities.PendingApproval:
+		return "در انتظار تایید"
+	case entities.RejectedApproval:
+		return "رد شده"
+	default:
+		return ""
+	}
+}
+
+func ScheduleApprovalRequestToText(schedule entities.Schedule) string {
+	return fmt.Sprintf(
+		"کاربر %d برنامه زمانی %d را ثبت کرده است و منتظر تایید شماست.\n%sزمان: y:%d m:%d d:%d hh:%d mm:%d (%s)",
+		schedule.CreatorId,
+		schedule.ScheduleId,
+		ScheduleToText(schedule),
+		schedule.Calendar.Year,
+		schedule.Calendar.Month,
+		schedule.Calendar.Day,
+		schedule.Calendar.Hour,
+		schedule.Calendar.Minute,
+		CalendarTypeToText(schedule.Calendar.Type),
+	)
+}
+
+func PausedToText(paused bool) string {
+	if paused {
+		return "متوقف"
+	}
+	return "فعال"
+}
+
+func OccurrencesToText(
+	calendarType entities.CalendarType,
+	occurrences []entities.Occurrence,
+) string {
+	if len(occurrences) == 0 {
+		return "این الگو در سال‌های آینده اجرا نمی‌شود. لطفا الگو را بررسی کنید."
+	}
+
+	var text strings.Builder
+	text.WriteString(
+		fmt.Sprintf(
+			"زمان‌های اجرای بعدی (%s / میلادی):\n",
+			CalendarTypeToText(calendarType),
+		),
+	)
+	for i, occurrence := range occurrences {
+		text.WriteString(
+			fmt.Sprintf(
+				"%d. %04d/%02d/%02d %02d:%02d (%s)\n",
+				i+1,
+				occurrence.Date.Year,
+				occurrence.Date.Month,
+				occurrence.Date.Day,
+				occurrence.Date.Hour,
+				occurrence.Date.Minute,
+				occurrence.Time.Format("2006-01-02 15:04"),
+			),
+		)
+	}
+	return text.String()
+}
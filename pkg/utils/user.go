@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+// ParseUserReference parses a user given either by numeric id or by
+// username. exactly one of the returned id and username is set.
+func ParseUserReference(text string) (int, string, error) {
+	text = strings.TrimSpace(text)
+	if userId, err := strconv.Atoi(text); err == nil {
+		return userId, "", nil
+	}
+
+	userName := strings.TrimPrefix(text, "@")
+	if userName == "" || strings.ContainsAny(userName, " \n") {
+		return 0, "", fmt.Errorf("شناسه یا نام کاربری معتبر نیست")
+	}
+	return 0, userName, nil
+}
+
+func RoleToText(role entities.Role) string {
+	switch role {
+	case entities.OwnerRole:
+		return "مالک"
+	case entities.EditorRole:
+		return "ویرایشگر"
+	case entities.ViewerRole:
+		return "بیننده"
+	default:
+		return "بدون دسترسی"
+	}
+}
+
+func FeatureFlagMembersToText(
+	featureFlagName string,
+	members []entities.FeatureFlagMember,
+) string {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("اعضای پرچم %s:\n", featureFlagName))
+	for i, member := range members {
+		text.WriteString(
+			fmt.Sprintf(
+				"%d. %d (%s)\n",
+				i+1,
+				member.UserId,
+				RoleToText(member.Role),
+			),
+		)
+	}
+	return text.String()
+}
+
+func WorkspaceMembersToText(
+	workspace entities.Workspace,
+	members []entities.WorkspaceMember,
+) string {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("اعضای فضای کاری %s:\n", workspace.Name))
+	for i, member := range members {
+		text.WriteString(
+			fmt.Sprintf(
+				"%d. %d (%s)\n",
+				i+1,
+				member.UserId,
+				RoleToText(member.Role),
+			),
+		)
+	}
+	return text.String()
+}
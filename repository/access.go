@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+// AddInviteCode stores a new invite code. it returns ErrInviteCodeExists if
+// the code is taken.
+func (repo *PostgresRepository) AddInviteCode(
+	ctx context.Context,
+	code string,
+	creatorId int,
+) error {
+	ctx, done := repo.start(ctx, "AddInviteCode")
+	defer done()
+
+	query := `INSERT INTO invite_code(code, creator_id, unix_time) VALUES ($1, $2, $3);`
+	_, err := repo.conn().ExecContext(ctx, query, code, creatorId, time.Now().Unix())
+	return conflict(err, ErrInviteCodeExists, nil)
+}
+
+// RedeemInviteCode uses up the invite code and lets the user in. it returns
+// ErrInviteCodeInvalid if the code does not exist or is already used.
+func (repo *PostgresRepository) RedeemInviteCode(
+	ctx context.Context,
+	code string,
+	userId int,
+) error {
+	ctx, done := repo.start(ctx, "RedeemInviteCode")
+	defer done()
+
+	tx, err := repo.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	query := `UPDATE invite_code SET used_by=$2, used_at=$3 WHERE code=$1 AND used_by=0;`
+	result, err := tx.ExecContext(ctx, query, code, userId, now)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrInviteCodeInvalid
+	}
+
+	query = `
+	INSERT INTO allowed_user(user_id, invite_code, unix_time) VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING;
+	`
+	_, err = tx.ExecContext(ctx, query, userId, code, now)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (repo *PostgresRepository) IsAllowedUser(ctx context.Context, userId int) (bool, error) {
+	ctx, done := repo.start(ctx, "IsAllowedUser")
+	defer done()
+
+	query := `SELECT EXISTS (SELECT 1 FROM allowed_user WHERE user_id=$1);`
+	var allowed bool
+	err := repo.conn().QueryRowContext(ctx, query, userId).Scan(&allowed)
+	return allowed, err
+}
+
+func (repo *PostgresRepository) GetAllowedUsers(
+	ctx context.Context,
+) ([]entities.AllowedUser, error) {
+	ctx, done := repo.start(ctx, "GetAllowedUsers")
+	defer done()
+
+	query := `
+	SELECT user_id, invite_code, unix_time FROM allowed_user ORDER BY unix_time, user_id;
+	`
+	return queryAll(ctx, repo.conn(), scanAllowedUser, query)
+}
+
+func (repo *PostgresRepository) RemoveAllowedUser(ctx context.Context, userId int) error {
+	ctx, done := repo.start(ctx, "RemoveAllowedUser")
+	defer done()
+
+	query := `DELETE FROM allowed_user WHERE user_id=$1;`
+	_, err := repo.conn().ExecContext(ctx, query, userId)
+	return err
+}
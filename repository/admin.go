@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+func (repo *PostgresRepository) IsAdmin(ctx context.Context, userId int) (bool, error) {
+	ctx, done := repo.start(ctx, "IsAdmin")
+	defer done()
+
+	query := `SELECT EXISTS (SELECT 1 FROM admin WHERE user_id=$1);`
+	var admin bool
+	err := repo.conn().QueryRowContext(ctx, query, userId).Scan(&admin)
+	return admin, err
+}
+
+func (repo *PostgresRepository) GetAdmins(ctx context.Context) ([]entities.Admin, error) {
+	ctx, done := repo.start(ctx, "GetAdmins")
+	defer done()
+
+	query := `SELECT user_id, unix_time FROM admin ORDER BY user_id;`
+	return queryAll(ctx, repo.conn(), scanAdmin, query)
+}
+
+// AddAdmin makes the user an admin. adding an admin again does nothing.
+func (repo *PostgresRepository) AddAdmin(ctx context.Context, userId int) error {
+	ctx, done := repo.start(ctx, "AddAdmin")
+	defer done()
+
+	query := `
+	INSERT INTO admin(user_id, unix_time) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO NOTHING;
+	`
+	_, err := repo.conn().ExecContext(ctx, query, userId, time.Now().Unix())
+	return err
+}
+
+func (repo *PostgresRepository) RemoveAdmin(ctx context.Context, userId int) error {
+	ctx, done := repo.start(ctx, "RemoveAdmin")
+	defer done()
+
+	query := `DELETE FROM admin WHERE user_id=$1;`
+	_, err := repo.conn().ExecContext(ctx, query, userId)
+	return err
+}
+
+// GetAllFeatureFlags returns the feature flags of every workspace, except
+// those in the trash.
+func (repo *PostgresRepository) GetAllFeatureFlags(
+	ctx context.Context,
+) ([]entities.FeatureFlag, error) {
+	ctx, done := repo.start(ctx, "GetAllFeatureFlags")
+	defer done()
+
+	query := `
+	SELECT workspace_id, feature_flag, owner_id, paused, unix_time, COALESCE(deleted_at, 0)
+	FROM feature_flag WHERE deleted_at IS NULL
+	ORDER BY workspace_id, feature_flag;
+	`
+	return queryAll(ctx, repo.conn(), scanFeatureFlag, query)
+}
+
+// PurgeFeatureFlag deletes the feature flag right away, together with its
+// schedules and members, whether it is in the trash or not.
+func (repo *PostgresRepository) PurgeFeatureFlag(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+) error {
+	ctx, done := repo.start(ctx, "PurgeFeatureFlag")
+	defer done()
+
+	query := `DELETE FROM feature_flag WHERE workspace_id=$1 AND feature_flag=$2;`
+	result, err := repo.conn().ExecContext(ctx, query, workspaceId, featureFlag)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		return ErrFlagNotFound
+	}
+	return err
+}
+
+func (repo *PostgresRepository) GetBotUserIds(
+	ctx context.Context,
+	bot string,
+) ([]int, error) {
+	ctx, done := repo.start(ctx, "GetBotUserIds")
+	defer done()
+
+	query := `SELECT user_id FROM user_bot WHERE bot = $1 ORDER BY user_id;`
+	return queryAll(ctx, repo.conn(), scanInt, query, bot)
+}
+
+func (repo *PostgresRepository) GetStats(ctx context.Context) (entities.Stats, error) {
+	ctx, done := repo.start(ctx, "GetStats")
+	defer done()
+
+	query := `
+	SELECT
+		(SELECT COUNT(*) FROM feature_flag WHERE deleted_at IS NULL),
+		(SELECT COUNT(*) FROM feature_flag WHERE deleted_at IS NOT NULL),
+		(SELECT COUNT(*) FROM schedule s
+			JOIN feature_flag f ON f.workspace_id = s.workspace_id AND f.feature_flag = s.feature_flag
+			WHERE f.deleted_at IS NULL),
+		(SELECT COUNT(*) FROM schedule s
+			JOIN feature_flag f ON f.workspace_id = s.workspace_id AND f.feature_flag = s.feature_flag
+			WHERE f.deleted_at IS NULL AND s.approval = $1),
+		(SELECT COUNT(*) FROM workspace),
+		(SELECT COUNT(*) FROM bot_user);
+	`
+	var stats entities.Stats
+	err := repo.conn().QueryRowContext(ctx, query, entities.PendingApproval).Scan(
+		&stats.FeatureFlags,
+		&stats.DeletedFeatureFlags,
+		&stats.Schedules,
+		&stats.PendingSchedules,
+		&stats.Workspaces,
+		&stats.BotUsers,
+	)
+	return stats, err
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+// AddAuditLog records auditLog at the current time. call it in the
+// transaction of the change it describes so that neither is kept without
+// the other.
+func (repo *PostgresRepository) AddAuditLog(
+	ctx context.Context,
+	auditLog entities.AuditLog,
+) error {
+	ctx, done := repo.start(ctx, "AddAuditLog")
+	defer done()
+
+	query := `
+	INSERT INTO audit_log(
+		actor_id,
+		workspace_id,
+		feature_flag,
+		entity,
+		action,
+		before_value,
+		after_value,
+		unix_time
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := repo.conn().ExecContext(ctx,
+		query,
+		auditLog.ActorId,
+		auditLog.WorkspaceId,
+		auditLog.FeatureFlagName,
+		auditLog.Entity,
+		auditLog.Action,
+		auditLog.Before,
+		auditLog.After,
+		time.Now().Unix(),
+	)
+	return err
+}
+
+// GetAuditLogsByFeatureFlag returns the last limit changes of the feature
+// flag of the workspace, most recent first.
+func (repo *PostgresRepository) GetAuditLogsByFeatureFlag(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+	limit int,
+) ([]entities.AuditLog, error) {
+	ctx, done := repo.start(ctx, "GetAuditLogsByFeatureFlag")
+	defer done()
+
+	query := `
+	SELECT audit_id, actor_id, workspace_id, feature_flag, entity, action,
+		before_value, after_value, unix_time
+	FROM audit_log WHERE workspace_id=$1 AND feature_flag=$2
+	ORDER BY unix_time DESC, audit_id DESC LIMIT $3`
+	return queryAll(ctx, repo.conn(), scanAuditLog, query, workspaceId, featureFlag, limit)
+}
+
+// GetAuditLogsByActorId returns the last limit changes made by the user,
+// most recent first.
+func (repo *PostgresRepository) GetAuditLogsByActorId(
+	ctx context.Context,
+	actorId int,
+	limit int,
+) ([]entities.AuditLog, error) {
+	ctx, done := repo.start(ctx, "GetAuditLogsByActorId")
+	defer done()
+
+	query := `
+	SELECT audit_id, actor_id, workspace_id, feature_flag, entity, action,
+		before_value, after_value, unix_time
+	FROM audit_log WHERE actor_id=$1
+	ORDER BY unix_time DESC, audit_id DESC LIMIT $2`
+	return queryAll(ctx, repo.conn(), scanAuditLog, query, actorId, limit)
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var (
+	ErrFlagNotFound      = errors.New("feature flag not found")
+	ErrFlagExists        = errors.New("feature flag already exists")
+	ErrFlagDeleted       = errors.New("feature flag is in the trash")
+	ErrScheduleNotFound  = errors.New("schedule not found")
+	ErrScheduleReviewed  = errors.New("schedule is already reviewed")
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrWorkspaceExists   = errors.New("workspace already exists")
+	ErrUserNotFound      = errors.New("user not found")
+	ErrInviteCodeExists  = errors.New("invite code already exists")
+	ErrInviteCodeInvalid = errors.New("invite code not found or already used")
+)
+
+// notFound returns notFoundErr if err means the row does not exist.
+func notFound(err error, notFoundErr error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFoundErr
+	}
+	return err
+}
+
+// conflict maps the unique violations of err to existsErr and the foreign key
+// violations to notFoundErr. either may be nil to leave those errors as they
+// are. the driver error is kept in the chain of the returned error.
+func conflict(err error, existsErr error, notFoundErr error) error {
+	if err == nil {
+		return nil
+	}
+
+	if existsErr != nil && isUniqueViolation(err) {
+		return fmt.Errorf("%w: %w", existsErr, err)
+	}
+	if notFoundErr != nil && isForeignKeyViolation(err) {
+		return fmt.Errorf("%w: %w", notFoundErr, err)
+	}
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE ||
+			sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23503"
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+	}
+	return false
+}
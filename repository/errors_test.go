@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// sqliteErrors returns the errors sqlite reports for a unique violation and
+// a foreign key violation.
+func sqliteErrors(t *testing.T) (unique error, foreignKey error) {
+	db, err := OpenSqlite(filepath.Join(t.TempDir(), "errors.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`
+	CREATE TABLE parent(id INTEGER PRIMARY KEY, name TEXT UNIQUE);
+	CREATE TABLE child(parent_id INTEGER REFERENCES parent(id));
+	INSERT INTO parent(id, name) VALUES (1, 'a');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, unique = db.Exec("INSERT INTO parent(id, name) VALUES (2, 'a')")
+	_, foreignKey = db.Exec("INSERT INTO child(parent_id) VALUES (2)")
+	if unique == nil || foreignKey == nil {
+		t.Fatalf("constraints were not violated: %v, %v", unique, foreignKey)
+	}
+	return unique, foreignKey
+}
+
+func TestNotFound(t *testing.T) {
+	other := errors.New("connection refused")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"no rows", sql.ErrNoRows, ErrFlagNotFound},
+		{"wrapped no rows", fmt.Errorf("scanning: %w", sql.ErrNoRows), ErrFlagNotFound},
+		{"other error", other, other},
+		{"no error", nil, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := notFound(test.err, ErrFlagNotFound)
+			if got != test.want {
+				t.Fatalf("notFound(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestConflict(t *testing.T) {
+	sqliteUnique, sqliteForeignKey := sqliteErrors(t)
+	pqUnique := &pq.Error{Code: "23505"}
+	pqForeignKey := &pq.Error{Code: "23503"}
+	pqOther := &pq.Error{Code: "23502"}
+	other := errors.New("connection refused")
+
+	tests := []struct {
+		name        string
+		err         error
+		existsErr   error
+		notFoundErr error
+		want        error
+	}{
+		{"no error", nil, ErrFlagExists, ErrWorkspaceNotFound, nil},
+		{"pq unique", pqUnique, ErrFlagExists, ErrWorkspaceNotFound, ErrFlagExists},
+		{"pq foreign key", pqForeignKey, ErrFlagExists, ErrWorkspaceNotFound, ErrWorkspaceNotFound},
+		{"pq other", pqOther, ErrFlagExists, ErrWorkspaceNotFound, pqOther},
+		{"pq unique unmapped", pqUnique, nil, ErrWorkspaceNotFound, pqUnique},
+		{"pq foreign key unmapped", pqForeignKey, ErrFlagExists, nil, pqForeignKey},
+		{"wrapped pq unique", fmt.Errorf("insert: %w", pqUnique), ErrFlagExists, nil, ErrFlagExists},
+		{"sqlite unique", sqliteUnique, ErrFlagExists, ErrWorkspaceNotFound, ErrFlagExists},
+		{"sqlite foreign key", sqliteForeignKey, ErrFlagExists, ErrWorkspaceNotFound, ErrWorkspaceNotFound},
+		{"sqlite unique unmapped", sqliteUnique, nil, ErrWorkspaceNotFound, sqliteUnique},
+		{"other error", other, ErrFlagExists, ErrWorkspaceNotFound, other},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := conflict(test.err, test.existsErr, test.notFoundErr)
+			if test.want == nil {
+				if got != nil {
+					t.Fatalf("conflict(nil) = %v, want nil", got)
+				}
+				return
+			}
+			if !errors.Is(got, test.want) {
+				t.Fatalf("conflict(%v) = %v, want %v", test.err, got, test.want)
+			}
+			if !errors.Is(got, test.err) {
+				t.Fatalf("conflict(%v) = %v, lost the driver error", test.err, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,1249 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+type featureFlagKey struct {
+	workspaceId int
+	name        string
+}
+
+type featureFlagMemberKey struct {
+	featureFlagKey
+	userId int
+}
+
+type workspaceMemberKey struct {
+	workspaceId int
+	userId      int
+}
+
+type processedUpdateKey struct {
+	bot      string
+	updateId int
+}
+
+type userBotKey struct {
+	bot    string
+	userId int
+}
+
+// MemoryRepository keeps everything in memory. it behaves like
+// PostgresRepository and is meant for tests and local runs.
+type MemoryRepository struct {
+	mu sync.Mutex
+
+	memoryData
+}
+
+type memoryData struct {
+	featureFlags       map[featureFlagKey]entities.FeatureFlag
+	featureFlagMembers map[featureFlagMemberKey]entities.FeatureFlagMember
+	schedules          map[int]entities.Schedule
+	lastScheduleId     int
+	workspaces         map[int]entities.Workspace
+	lastWorkspaceId    int
+	workspaceMembers   map[workspaceMemberKey]entities.WorkspaceMember
+	botUsers           map[int]entities.BotUser
+	currentWorkspaces  map[int]int
+	settings           map[string]string
+	processedUpdates   map[processedUpdateKey]int64
+	auditLogs          []entities.AuditLog
+	admins             map[int]entities.Admin
+	inviteCodes        map[string]entities.InviteCode
+	allowedUsers       map[int]entities.AllowedUser
+	userBots           map[userBotKey]bool
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		memoryData: memoryData{
+			featureFlags:       map[featureFlagKey]entities.FeatureFlag{},
+			featureFlagMembers: map[featureFlagMemberKey]entities.FeatureFlagMember{},
+			schedules:          map[int]entities.Schedule{},
+			workspaces:         map[int]entities.Workspace{},
+			workspaceMembers:   map[workspaceMemberKey]entities.WorkspaceMember{},
+			botUsers:           map[int]entities.BotUser{},
+			currentWorkspaces:  map[int]int{},
+			settings:           map[string]string{},
+			processedUpdates:   map[processedUpdateKey]int64{},
+			admins:             map[int]entities.Admin{},
+			inviteCodes:        map[string]entities.InviteCode{},
+			allowedUsers:       map[int]entities.AllowedUser{},
+			userBots:           map[userBotKey]bool{},
+		},
+	}
+}
+
+func (data *memoryData) clone() memoryData {
+	return memoryData{
+		featureFlags:       maps.Clone(data.featureFlags),
+		featureFlagMembers: maps.Clone(data.featureFlagMembers),
+		schedules:          maps.Clone(data.schedules),
+		lastScheduleId:     data.lastScheduleId,
+		workspaces:         maps.Clone(data.workspaces),
+		lastWorkspaceId:    data.lastWorkspaceId,
+		workspaceMembers:   maps.Clone(data.workspaceMembers),
+		botUsers:           maps.Clone(data.botUsers),
+		currentWorkspaces:  maps.Clone(data.currentWorkspaces),
+		settings:           maps.Clone(data.settings),
+		processedUpdates:   maps.Clone(data.processedUpdates),
+		auditLogs:          slices.Clone(data.auditLogs),
+		admins:             maps.Clone(data.admins),
+		inviteCodes:        maps.Clone(data.inviteCodes),
+		allowedUsers:       maps.Clone(data.allowedUsers),
+		userBots:           maps.Clone(data.userBots),
+	}
+}
+
+// WithTx calls fn with a copy of the data and keeps the changes fn made to
+// it only if fn succeeds. the other methods wait until the transaction
+// ends, so nothing they change is lost when the copy is kept.
+func (repo *MemoryRepository) WithTx(
+	ctx context.Context,
+	fn func(repo Repository) error,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	tx := &MemoryRepository{memoryData: repo.clone()}
+	err := fn(memoryTx{tx})
+	if err != nil {
+		return err
+	}
+
+	repo.memoryData = tx.memoryData
+	return nil
+}
+
+// memoryTx is the repository passed to the functions of WithTx.
+type memoryTx struct {
+	*MemoryRepository
+}
+
+// WithTx runs fn in the transaction the repository is already in.
+func (tx memoryTx) WithTx(
+	ctx context.Context,
+	fn func(repo Repository) error,
+) error {
+	return fn(tx)
+}
+
+// Init creates the default workspace.
+func (repo *MemoryRepository) Init(ctx context.Context) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.workspaces[DefaultWorkspaceId]; !ok {
+		repo.workspaces[DefaultWorkspaceId] = entities.Workspace{
+			WorkspaceId: DefaultWorkspaceId,
+			Name:        "default",
+			UnixTime:    time.Now().Unix(),
+		}
+	}
+	repo.lastWorkspaceId = max(repo.lastWorkspaceId, DefaultWorkspaceId)
+	return nil
+}
+
+func (repo *MemoryRepository) AddFeatureFlag(
+	ctx context.Context,
+	workspaceId int,
+	ownerId int,
+	featureFlag string,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := featureFlagKey{workspaceId, featureFlag}
+	if flag, ok := repo.featureFlags[key]; ok {
+		if flag.DeletedAt != 0 {
+			return ErrFlagDeleted
+		}
+		return ErrFlagExists
+	}
+
+	now := time.Now().Unix()
+	repo.featureFlags[key] = entities.FeatureFlag{
+		WorkspaceId: workspaceId,
+		Name:        featureFlag,
+		OwnerId:     ownerId,
+		UnixTime:    now,
+	}
+	repo.featureFlagMembers[featureFlagMemberKey{key, ownerId}] = entities.FeatureFlagMember{
+		WorkspaceId:     workspaceId,
+		FeatureFlagName: featureFlag,
+		UserId:          ownerId,
+		Role:            entities.OwnerRole,
+		UnixTime:        now,
+	}
+	return nil
+}
+
+func (repo *MemoryRepository) AddSchedule(
+	ctx context.Context,
+	schedule entities.Schedule,
+) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := featureFlagKey{schedule.WorkspaceId, schedule.FeatureFlagName}
+	if _, ok := repo.liveFeatureFlag(key); !ok {
+		return 0, ErrFlagNotFound
+	}
+
+	repo.lastScheduleId++
+	schedule.ScheduleId = repo.lastScheduleId
+	schedule.Paused = false
+	schedule.Approval = scheduleApproval(schedule)
+	schedule.UnixTime = time.Now().Unix()
+	repo.schedules[schedule.ScheduleId] = schedule
+	return schedule.ScheduleId, nil
+}
+
+func (repo *MemoryRepository) RemoveFeatureFlag(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := featureFlagKey{workspaceId, featureFlag}
+	if flag, ok := repo.liveFeatureFlag(key); ok {
+		flag.DeletedAt = time.Now().Unix()
+		repo.featureFlags[key] = flag
+	}
+	return nil
+}
+
+// liveFeatureFlag returns the feature flag if it exists and is not in the
+// trash. repo.mu must be held.
+func (repo *MemoryRepository) liveFeatureFlag(
+	key featureFlagKey,
+) (entities.FeatureFlag, bool) {
+	flag, ok := repo.featureFlags[key]
+	return flag, ok && flag.DeletedAt == 0
+}
+
+func (repo *MemoryRepository) GetDeletedFeatureFlagsByUserId(
+	ctx context.Context,
+	workspaceId int,
+	userId int,
+	minRole entities.Role,
+) ([]entities.FeatureFlag, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var featureFlags []entities.FeatureFlag
+	for key, featureFlag := range repo.featureFlags {
+		if key.workspaceId == workspaceId && featureFlag.DeletedAt != 0 &&
+			repo.featureFlagRole(key, userId) >= minRole {
+			featureFlags = append(featureFlags, featureFlag)
+		}
+	}
+	sort.Slice(featureFlags, func(i, j int) bool {
+		if featureFlags[i].DeletedAt != featureFlags[j].DeletedAt {
+			return featureFlags[i].DeletedAt > featureFlags[j].DeletedAt
+		}
+		return featureFlags[i].Name < featureFlags[j].Name
+	})
+	return featureFlags, nil
+}
+
+func (repo *MemoryRepository) RestoreFeatureFlag(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := featureFlagKey{workspaceId, featureFlag}
+	flag, ok := repo.featureFlags[key]
+	if !ok || flag.DeletedAt == 0 {
+		return ErrFlagNotFound
+	}
+
+	flag.DeletedAt = 0
+	repo.featureFlags[key] = flag
+	return nil
+}
+
+func (repo *MemoryRepository) PurgeFeatureFlagsDeletedBefore(
+	ctx context.Context,
+	t time.Time,
+) ([]entities.FeatureFlag, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var purged []entities.FeatureFlag
+	for key, flag := range repo.featureFlags {
+		if flag.DeletedAt != 0 && flag.DeletedAt < t.Unix() {
+			repo.purgeFeatureFlag(key)
+			purged = append(purged, flag)
+		}
+	}
+	sortFeatureFlags(purged)
+	return purged, nil
+}
+
+// purgeFeatureFlag deletes the feature flag with its schedules and members.
+// repo.mu must be held.
+func (repo *MemoryRepository) purgeFeatureFlag(key featureFlagKey) {
+	delete(repo.featureFlags, key)
+	for memberKey := range repo.featureFlagMembers {
+		if memberKey.featureFlagKey == key {
+			delete(repo.featureFlagMembers, memberKey)
+		}
+	}
+	for id, schedule := range repo.schedules {
+		if schedule.WorkspaceId == key.workspaceId &&
+			schedule.FeatureFlagName == key.name {
+			delete(repo.schedules, id)
+		}
+	}
+}
+
+func (repo *MemoryRepository) RemoveSchedule(
+	ctx context.Context,
+	scheduleId int,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.schedules, scheduleId)
+	return nil
+}
+
+func (repo *MemoryRepository) GetFeatureFlagByName(
+	ctx context.Context,
+	workspaceId int,
+	name string,
+) (*entities.FeatureFlag, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	featureFlag, ok := repo.liveFeatureFlag(featureFlagKey{workspaceId, name})
+	if !ok {
+		return nil, ErrFlagNotFound
+	}
+	return &featureFlag, nil
+}
+
+func (repo *MemoryRepository) GetFeatureFlagsByOwnerId(
+	ctx context.Context,
+	ownerId int,
+) (
+	[]entities.FeatureFlag,
+	error,
+) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var featureFlags []entities.FeatureFlag
+	for _, featureFlag := range repo.featureFlags {
+		if featureFlag.OwnerId == ownerId && featureFlag.DeletedAt == 0 {
+			featureFlags = append(featureFlags, featureFlag)
+		}
+	}
+	sortFeatureFlags(featureFlags)
+	return featureFlags, nil
+}
+
+// featureFlagRole returns the role of the user on the feature flag the same
+// way GetFeatureFlagRole does. repo.mu must be held.
+func (repo *MemoryRepository) featureFlagRole(
+	key featureFlagKey,
+	userId int,
+) entities.Role {
+	return repo.featureFlagMembers[featureFlagMemberKey{key, userId}].Role
+}
+
+func (repo *MemoryRepository) GetFeatureFlagsByUserId(
+	ctx context.Context,
+	workspaceId int,
+	userId int,
+	minRole entities.Role,
+) ([]entities.FeatureFlag, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var featureFlags []entities.FeatureFlag
+	for key, featureFlag := range repo.featureFlags {
+		if key.workspaceId == workspaceId && featureFlag.DeletedAt == 0 &&
+			repo.featureFlagRole(key, userId) >= minRole {
+			featureFlags = append(featureFlags, featureFlag)
+		}
+	}
+	sortFeatureFlags(featureFlags)
+	return featureFlags, nil
+}
+
+func (repo *MemoryRepository) GetFeatureFlagRole(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+	userId int,
+) (entities.Role, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := featureFlagKey{workspaceId, featureFlag}
+	if _, ok := repo.liveFeatureFlag(key); !ok {
+		return 0, nil
+	}
+	return repo.featureFlagRole(key, userId), nil
+}
+
+func (repo *MemoryRepository) GetFeatureFlagMembers(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+) ([]entities.FeatureFlagMember, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := featureFlagKey{workspaceId, featureFlag}
+	var members []entities.FeatureFlagMember
+	for memberKey, member := range repo.featureFlagMembers {
+		if memberKey.featureFlagKey == key {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Role != members[j].Role {
+			return members[i].Role > members[j].Role
+		}
+		return members[i].UnixTime < members[j].UnixTime
+	})
+	return members, nil
+}
+
+func (repo *MemoryRepository) SetFeatureFlagMember(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+	userId int,
+	role entities.Role,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := featureFlagKey{workspaceId, featureFlag}
+	if _, ok := repo.featureFlags[key]; !ok {
+		return ErrFlagNotFound
+	}
+
+	repo.setFeatureFlagMember(key, userId, role)
+	repo.joinWorkspace(workspaceId, userId)
+	return nil
+}
+
+// setFeatureFlagMember upserts a member of the feature flag. repo.mu must be
+// held.
+func (repo *MemoryRepository) setFeatureFlagMember(
+	key featureFlagKey,
+	userId int,
+	role entities.Role,
+) {
+	memberKey := featureFlagMemberKey{key, userId}
+	member, ok := repo.featureFlagMembers[memberKey]
+	if !ok {
+		member = entities.FeatureFlagMember{
+			WorkspaceId:     key.workspaceId,
+			FeatureFlagName: key.name,
+			UserId:          userId,
+			UnixTime:        time.Now().Unix(),
+		}
+	}
+	member.Role = role
+	repo.featureFlagMembers[memberKey] = member
+}
+
+// joinWorkspace makes the user a viewer of the workspace if not a member.
+// repo.mu must be held.
+func (repo *MemoryRepository) joinWorkspace(workspaceId int, userId int) {
+	key := workspaceMemberKey{workspaceId, userId}
+	if _, ok := repo.workspaceMembers[key]; ok {
+		return
+	}
+	repo.workspaceMembers[key] = entities.WorkspaceMember{
+		WorkspaceId: workspaceId,
+		UserId:      userId,
+		Role:        entities.ViewerRole,
+		UnixTime:    time.Now().Unix(),
+	}
+}
+
+func (repo *MemoryRepository) RemoveFeatureFlagMember(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+	userId int,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := featureFlagMemberKey{featureFlagKey{workspaceId, featureFlag}, userId}
+	delete(repo.featureFlagMembers, key)
+	return nil
+}
+
+func (repo *MemoryRepository) TransferFeatureFlagOwnership(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+	newOwnerId int,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := featureFlagKey{workspaceId, featureFlag}
+	flag, ok := repo.featureFlags[key]
+	if !ok {
+		return ErrFlagNotFound
+	}
+
+	for memberKey, member := range repo.featureFlagMembers {
+		if memberKey.featureFlagKey == key && member.Role == entities.OwnerRole {
+			member.Role = entities.EditorRole
+			repo.featureFlagMembers[memberKey] = member
+		}
+	}
+	repo.setFeatureFlagMember(key, newOwnerId, entities.OwnerRole)
+	repo.joinWorkspace(workspaceId, newOwnerId)
+
+	flag.OwnerId = newOwnerId
+	repo.featureFlags[key] = flag
+	return nil
+}
+
+func (repo *MemoryRepository) UpsertBotUser(
+	ctx context.Context,
+	bot string,
+	user entities.User,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var userName string
+	if user.UserName != nil {
+		userName = *user.UserName
+	}
+
+	// the username moved to user, so whoever had it before lost it.
+	for userId, botUser := range repo.botUsers {
+		if userName != "" && userId != user.Id && strings.EqualFold(botUser.UserName, userName) {
+			botUser.UserName = ""
+			repo.botUsers[userId] = botUser
+		}
+	}
+
+	botUser, ok := repo.botUsers[user.Id]
+	if !ok {
+		botUser = entities.BotUser{UserId: user.Id, UnixTime: time.Now().Unix()}
+	}
+	botUser.UserName = userName
+	botUser.FirstName = user.FirstName
+	repo.botUsers[user.Id] = botUser
+	repo.userBots[userBotKey{bot: bot, userId: user.Id}] = true
+	return nil
+}
+
+func (repo *MemoryRepository) GetBotUserByUserName(
+	ctx context.Context,
+	userName string,
+) (
+	*entities.BotUser,
+	error,
+) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, user := range repo.botUsers {
+		if strings.EqualFold(user.UserName, userName) {
+			return &user, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (repo *MemoryRepository) CreateWorkspace(
+	ctx context.Context,
+	name string,
+	ownerId int,
+) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, workspace := range repo.workspaces {
+		if workspace.Name == name {
+			return 0, ErrWorkspaceExists
+		}
+	}
+
+	now := time.Now().Unix()
+	repo.lastWorkspaceId++
+	workspaceId := repo.lastWorkspaceId
+	repo.workspaces[workspaceId] = entities.Workspace{
+		WorkspaceId: workspaceId,
+		Name:        name,
+		OwnerId:     ownerId,
+		UnixTime:    now,
+	}
+	repo.workspaceMembers[workspaceMemberKey{workspaceId, ownerId}] = entities.WorkspaceMember{
+		WorkspaceId: workspaceId,
+		UserId:      ownerId,
+		Role:        entities.OwnerRole,
+		UnixTime:    now,
+	}
+	return workspaceId, nil
+}
+
+func (repo *MemoryRepository) GetWorkspaceById(
+	ctx context.Context,
+	workspaceId int,
+) (
+	*entities.Workspace,
+	error,
+) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	workspace, ok := repo.workspaces[workspaceId]
+	if !ok {
+		return nil, ErrWorkspaceNotFound
+	}
+	return &workspace, nil
+}
+
+func (repo *MemoryRepository) GetWorkspacesByUserId(
+	ctx context.Context,
+	userId int,
+) (
+	[]entities.Workspace,
+	error,
+) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var workspaces []entities.Workspace
+	for key := range repo.workspaceMembers {
+		if key.userId == userId {
+			workspaces = append(workspaces, repo.workspaces[key.workspaceId])
+		}
+	}
+	sort.Slice(workspaces, func(i, j int) bool {
+		return workspaces[i].WorkspaceId < workspaces[j].WorkspaceId
+	})
+	return workspaces, nil
+}
+
+func (repo *MemoryRepository) GetWorkspaceRole(
+	ctx context.Context,
+	workspaceId int,
+	userId int,
+) (entities.Role, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	return repo.workspaceMembers[workspaceMemberKey{workspaceId, userId}].Role, nil
+}
+
+func (repo *MemoryRepository) GetWorkspaceMembers(
+	ctx context.Context,
+	workspaceId int,
+) (
+	[]entities.WorkspaceMember,
+	error,
+) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var members []entities.WorkspaceMember
+	for key, member := range repo.workspaceMembers {
+		if key.workspaceId == workspaceId {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Role != members[j].Role {
+			return members[i].Role > members[j].Role
+		}
+		return members[i].UnixTime < members[j].UnixTime
+	})
+	return members, nil
+}
+
+func (repo *MemoryRepository) SetWorkspaceMember(
+	ctx context.Context,
+	workspaceId int,
+	userId int,
+	role entities.Role,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.workspaces[workspaceId]; !ok {
+		return ErrWorkspaceNotFound
+	}
+
+	key := workspaceMemberKey{workspaceId, userId}
+	member, ok := repo.workspaceMembers[key]
+	if !ok {
+		member = entities.WorkspaceMember{
+			WorkspaceId: workspaceId,
+			UserId:      userId,
+			UnixTime:    time.Now().Unix(),
+		}
+	}
+	member.Role = role
+	repo.workspaceMembers[key] = member
+	return nil
+}
+
+func (repo *MemoryRepository) RemoveWorkspaceMember(
+	ctx context.Context,
+	workspaceId int,
+	userId int,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for key := range repo.featureFlagMembers {
+		if key.workspaceId == workspaceId && key.userId == userId {
+			delete(repo.featureFlagMembers, key)
+		}
+	}
+	delete(repo.workspaceMembers, workspaceMemberKey{workspaceId, userId})
+	return nil
+}
+
+func (repo *MemoryRepository) GetCurrentWorkspaceId(
+	ctx context.Context,
+	userId int,
+) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	return repo.currentWorkspaces[userId], nil
+}
+
+func (repo *MemoryRepository) SetCurrentWorkspaceId(
+	ctx context.Context,
+	userId int,
+	workspaceId int,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.currentWorkspaces[userId] = workspaceId
+	return nil
+}
+
+func (repo *MemoryRepository) GetScheduleById(
+	ctx context.Context,
+	scheduleId int,
+) (
+	*entities.Schedule,
+	error,
+) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	schedule, ok := repo.schedules[scheduleId]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	key := featureFlagKey{schedule.WorkspaceId, schedule.FeatureFlagName}
+	if _, ok := repo.liveFeatureFlag(key); !ok {
+		return nil, ErrScheduleNotFound
+	}
+	return &schedule, nil
+}
+
+func (repo *MemoryRepository) GetSchedulesByFeatureFlag(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+) ([]entities.Schedule, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.liveFeatureFlag(featureFlagKey{workspaceId, featureFlag}); !ok {
+		return nil, nil
+	}
+
+	var schedules []entities.Schedule
+	for _, schedule := range repo.schedules {
+		if schedule.WorkspaceId == workspaceId &&
+			schedule.FeatureFlagName == featureFlag {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sortSchedules(schedules)
+	return schedules, nil
+}
+
+func (repo *MemoryRepository) SetFeatureFlagPaused(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+	paused bool,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := featureFlagKey{workspaceId, featureFlag}
+	if flag, ok := repo.featureFlags[key]; ok {
+		flag.Paused = paused
+		repo.featureFlags[key] = flag
+	}
+	return nil
+}
+
+func (repo *MemoryRepository) SetSchedulePaused(
+	ctx context.Context,
+	scheduleId int,
+	paused bool,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if schedule, ok := repo.schedules[scheduleId]; ok {
+		schedule.Paused = paused
+		repo.schedules[scheduleId] = schedule
+	}
+	return nil
+}
+
+func (repo *MemoryRepository) ReviewSchedule(
+	ctx context.Context,
+	scheduleId int,
+	approval entities.Approval,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	schedule, ok := repo.schedules[scheduleId]
+	if !ok {
+		return ErrScheduleNotFound
+	}
+	if schedule.Approval != entities.PendingApproval {
+		return ErrScheduleReviewed
+	}
+
+	schedule.Approval = approval
+	repo.schedules[scheduleId] = schedule
+	return nil
+}
+
+func (repo *MemoryRepository) SetSchedulingPaused(
+	ctx context.Context,
+	paused bool,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if paused {
+		repo.settings[schedulingPausedSetting] = "true"
+	} else {
+		repo.settings[schedulingPausedSetting] = "false"
+	}
+	return nil
+}
+
+func (repo *MemoryRepository) IsSchedulingPaused(ctx context.Context) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	return repo.settings[schedulingPausedSetting] == "true", nil
+}
+
+// isScheduleActive is IsScheduleActive for a schedule that exists. repo.mu
+// must be held.
+func (repo *MemoryRepository) isScheduleActive(schedule entities.Schedule) bool {
+	flag, ok := repo.liveFeatureFlag(featureFlagKey{
+		schedule.WorkspaceId,
+		schedule.FeatureFlagName,
+	})
+	return ok && !schedule.Paused && !flag.Paused &&
+		schedule.Approval == entities.ApprovedApproval &&
+		repo.settings[schedulingPausedSetting] != "true"
+}
+
+func (repo *MemoryRepository) IsScheduleActive(
+	ctx context.Context,
+	scheduleId int,
+) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	schedule, ok := repo.schedules[scheduleId]
+	return ok && repo.isScheduleActive(schedule), nil
+}
+
+func (repo *MemoryRepository) GetScheduleByTime(
+	ctx context.Context,
+	calendarType entities.CalendarType,
+	year int,
+	month int,
+	day int,
+	startTime entities.CalendarTime,
+	endTime entities.CalendarTime,
+) ([]entities.Schedule, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	start := startTime.Hour*60 + startTime.Minute
+	end := endTime.Hour*60 + endTime.Minute
+	var schedules []entities.Schedule
+	for _, schedule := range repo.schedules {
+		calendar := schedule.Calendar
+		minute := calendar.Hour*60 + calendar.Minute
+		if calendar.Type != calendarType ||
+			calendar.Day != day ||
+			(calendar.Year != 0 && calendar.Year != year) ||
+			(calendar.Month != 0 && calendar.Month != month) ||
+			minute < start || minute > end ||
+			!repo.isScheduleActive(schedule) {
+			continue
+		}
+		schedules = append(schedules, schedule)
+	}
+	sortSchedules(schedules)
+	return schedules, nil
+}
+
+func (repo *MemoryRepository) MarkUpdateProcessed(
+	ctx context.Context,
+	bot string,
+	updateId int,
+) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := processedUpdateKey{bot, updateId}
+	if _, ok := repo.processedUpdates[key]; ok {
+		return false, nil
+	}
+	repo.processedUpdates[key] = time.Now().Unix()
+	return true, nil
+}
+
+func (repo *MemoryRepository) GetLastProcessedUpdateId(
+	ctx context.Context,
+	bot string,
+) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	last := 0
+	for key := range repo.processedUpdates {
+		if key.bot == bot {
+			last = max(last, key.updateId)
+		}
+	}
+	return last, nil
+}
+
+func (repo *MemoryRepository) DeleteProcessedUpdatesBefore(
+	ctx context.Context,
+	t time.Time,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	last := map[string]int{}
+	for key := range repo.processedUpdates {
+		last[key.bot] = max(last[key.bot], key.updateId)
+	}
+	for key, unixTime := range repo.processedUpdates {
+		if unixTime < t.Unix() && key.updateId < last[key.bot] {
+			delete(repo.processedUpdates, key)
+		}
+	}
+	return nil
+}
+
+func (repo *MemoryRepository) AddAuditLog(
+	ctx context.Context,
+	auditLog entities.AuditLog,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	auditLog.AuditId = len(repo.auditLogs) + 1
+	auditLog.UnixTime = time.Now().Unix()
+	repo.auditLogs = append(repo.auditLogs, auditLog)
+	return nil
+}
+
+func (repo *MemoryRepository) GetAuditLogsByFeatureFlag(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+	limit int,
+) ([]entities.AuditLog, error) {
+	return repo.lastAuditLogs(limit, func(auditLog entities.AuditLog) bool {
+		return auditLog.WorkspaceId == workspaceId &&
+			auditLog.FeatureFlagName == featureFlag
+	}), nil
+}
+
+func (repo *MemoryRepository) GetAuditLogsByActorId(
+	ctx context.Context,
+	actorId int,
+	limit int,
+) ([]entities.AuditLog, error) {
+	return repo.lastAuditLogs(limit, func(auditLog entities.AuditLog) bool {
+		return auditLog.ActorId == actorId
+	}), nil
+}
+
+// lastAuditLogs returns the last limit audit logs that match, most recent
+// first.
+func (repo *MemoryRepository) lastAuditLogs(
+	limit int,
+	match func(auditLog entities.AuditLog) bool,
+) []entities.AuditLog {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var auditLogs []entities.AuditLog
+	for i := len(repo.auditLogs) - 1; i >= 0 && len(auditLogs) < limit; i-- {
+		if match(repo.auditLogs[i]) {
+			auditLogs = append(auditLogs, repo.auditLogs[i])
+		}
+	}
+	return auditLogs
+}
+
+func (repo *MemoryRepository) IsAdmin(ctx context.Context, userId int) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	_, ok := repo.admins[userId]
+	return ok, nil
+}
+
+func (repo *MemoryRepository) GetAdmins(ctx context.Context) ([]entities.Admin, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var admins []entities.Admin
+	for _, admin := range repo.admins {
+		admins = append(admins, admin)
+	}
+	sort.Slice(admins, func(i, j int) bool {
+		return admins[i].UserId < admins[j].UserId
+	})
+	return admins, nil
+}
+
+func (repo *MemoryRepository) AddAdmin(ctx context.Context, userId int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.admins[userId]; !ok {
+		repo.admins[userId] = entities.Admin{
+			UserId:   userId,
+			UnixTime: time.Now().Unix(),
+		}
+	}
+	return nil
+}
+
+func (repo *MemoryRepository) RemoveAdmin(ctx context.Context, userId int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.admins, userId)
+	return nil
+}
+
+func (repo *MemoryRepository) GetAllFeatureFlags(
+	ctx context.Context,
+) ([]entities.FeatureFlag, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var featureFlags []entities.FeatureFlag
+	for _, featureFlag := range repo.featureFlags {
+		if featureFlag.DeletedAt == 0 {
+			featureFlags = append(featureFlags, featureFlag)
+		}
+	}
+	sort.Slice(featureFlags, func(i, j int) bool {
+		if featureFlags[i].WorkspaceId != featureFlags[j].WorkspaceId {
+			return featureFlags[i].WorkspaceId < featureFlags[j].WorkspaceId
+		}
+		return featureFlags[i].Name < featureFlags[j].Name
+	})
+	return featureFlags, nil
+}
+
+func (repo *MemoryRepository) PurgeFeatureFlag(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := featureFlagKey{workspaceId, featureFlag}
+	if _, ok := repo.featureFlags[key]; !ok {
+		return ErrFlagNotFound
+	}
+
+	repo.purgeFeatureFlag(key)
+	return nil
+}
+
+func (repo *MemoryRepository) GetBotUserIds(
+	ctx context.Context,
+	bot string,
+) ([]int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var userIds []int
+	for key := range repo.userBots {
+		if key.bot == bot {
+			userIds = append(userIds, key.userId)
+		}
+	}
+	sort.Ints(userIds)
+	return userIds, nil
+}
+
+func (repo *MemoryRepository) GetStats(ctx context.Context) (entities.Stats, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stats := entities.Stats{
+		Workspaces: len(repo.workspaces),
+		BotUsers:   len(repo.botUsers),
+	}
+	for _, featureFlag := range repo.featureFlags {
+		if featureFlag.DeletedAt == 0 {
+			stats.FeatureFlags++
+		} else {
+			stats.DeletedFeatureFlags++
+		}
+	}
+	for _, schedule := range repo.schedules {
+		_, ok := repo.liveFeatureFlag(featureFlagKey{
+			schedule.WorkspaceId,
+			schedule.FeatureFlagName,
+		})
+		if !ok {
+			continue
+		}
+
+		stats.Schedules++
+		if schedule.Approval == entities.PendingApproval {
+			stats.PendingSchedules++
+		}
+	}
+	return stats, nil
+}
+
+func sortFeatureFlags(featureFlags []entities.FeatureFlag) {
+	sort.Slice(featureFlags, func(i, j int) bool {
+		return featureFlags[i].Name < featureFlags[j].Name
+	})
+}
+
+func sortSchedules(schedules []entities.Schedule) {
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ScheduleId < schedules[j].ScheduleId
+	})
+}
+
+func (repo *MemoryRepository) AddInviteCode(
+	ctx context.Context,
+	code string,
+	creatorId int,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.inviteCodes[code]; ok {
+		return ErrInviteCodeExists
+	}
+	repo.inviteCodes[code] = entities.InviteCode{
+		Code:      code,
+		CreatorId: creatorId,
+		UnixTime:  time.Now().Unix(),
+	}
+	return nil
+}
+
+func (repo *MemoryRepository) RedeemInviteCode(
+	ctx context.Context,
+	code string,
+	userId int,
+) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	inviteCode, ok := repo.inviteCodes[code]
+	if !ok || inviteCode.UsedBy != 0 {
+		return ErrInviteCodeInvalid
+	}
+
+	now := time.Now().Unix()
+	inviteCode.UsedBy = userId
+	inviteCode.UsedAt = now
+	repo.inviteCodes[code] = inviteCode
+	if _, ok := repo.allowedUsers[userId]; !ok {
+		repo.allowedUsers[userId] = entities.AllowedUser{
+			UserId:     userId,
+			InviteCode: code,
+			UnixTime:   now,
+		}
+	}
+	return nil
+}
+
+func (repo *MemoryRepository) IsAllowedUser(ctx context.Context, userId int) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	_, ok := repo.allowedUsers[userId]
+	return ok, nil
+}
+
+func (repo *MemoryRepository) GetAllowedUsers(
+	ctx context.Context,
+) ([]entities.AllowedUser, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	allowedUsers := slices.Collect(maps.Values(repo.allowedUsers))
+	sort.Slice(allowedUsers, func(i, j int) bool {
+		if allowedUsers[i].UnixTime != allowedUsers[j].UnixTime {
+			return allowedUsers[i].UnixTime < allowedUsers[j].UnixTime
+		}
+		return allowedUsers[i].UserId < allowedUsers[j].UserId
+	})
+	return allowedUsers, nil
+}
+
+func (repo *MemoryRepository) RemoveAllowedUser(ctx context.Context, userId int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.allowedUsers, userId)
+	return nil
+}
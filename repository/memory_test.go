@@ -0,0 +1,19 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/fatemehkarimi/chronos_bot/repository"
+	"github.com/fatemehkarimi/chronos_bot/repository/repositorytest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		repo := repository.NewMemoryRepository()
+		err := repo.Init(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
@@ -0,0 +1,361 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationLockId is the postgres advisory lock held while migrating, so
+// that replicas starting together do not migrate the same database twice.
+const migrationLockId = 0x6368726f6e6f73
+
+// Migration is a version of the schema. migrations are files named
+// <version>_<name>.up.sql and <version>_<name>.down.sql in the directory of
+// their driver under migrations.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+// LoadMigrations returns the migrations of driver ordered by version.
+func LoadMigrations(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %q: %w", driver, err)
+	}
+
+	migrations := map[int]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(fileName, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %s", fileName)
+		}
+		versionText, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionText)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", fileName)
+		}
+
+		script, err := fs.ReadFile(migrationFiles, path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			migrations[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf(
+				"migrations %s and %s share version %d",
+				migration.Name,
+				name,
+				version,
+			)
+		}
+
+		if direction == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+
+	var sorted []Migration
+	for _, migration := range migrations {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf(
+				"migration %d_%s needs both an up and a down script",
+				migration.Version,
+				migration.Name,
+			)
+		}
+		sorted = append(sorted, *migration)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	return sorted, nil
+}
+
+// Migrator applies and reverts the embedded migrations of a driver, and
+// records the applied versions in the schema_migrations table.
+type Migrator struct {
+	DB     *sql.DB
+	Driver string
+}
+
+func NewMigrator(db *sql.DB, driver string) *Migrator {
+	return &Migrator{DB: db, Driver: driver}
+}
+
+// Up applies every migration that is not applied yet and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	migrations, err := LoadMigrations(m.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if versions[migration.Version] {
+				continue
+			}
+
+			ran, err := m.run(ctx, conn, migration, true)
+			if err != nil {
+				return err
+			}
+			if ran {
+				applied = append(applied, migration)
+			}
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations and returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	migrations, err := LoadMigrations(m.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := migrations[i]
+			if !versions[migration.Version] {
+				continue
+			}
+
+			ran, err := m.run(ctx, conn, migration, false)
+			if err != nil {
+				return err
+			}
+			if ran {
+				reverted = append(reverted, migration)
+			}
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status returns every migration and whether it is applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations(m.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			statuses = append(statuses, MigrationStatus{
+				Migration: migration,
+				Applied:   versions[migration.Version],
+			})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// withLock runs f on a single connection that holds the migration lock and
+// has the schema_migrations table. sqlite locks the database for the
+// duration of each migration transaction instead.
+func (m *Migrator) withLock(
+	ctx context.Context,
+	f func(conn *sql.Conn) error,
+) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.Driver == PostgresDriver {
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockId)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_, err := conn.ExecContext(
+				context.Background(),
+				"SELECT pg_advisory_unlock($1)",
+				migrationLockId,
+			)
+			if err != nil {
+				slog.Error("failed to release migration lock", slog.Any("error", err))
+			}
+		}()
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations(
+		version BIGINT PRIMARY KEY,
+		name VARCHAR NOT NULL,
+		unix_time BIGINT NOT NULL
+	);`
+	_, err = conn.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return f(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int]bool{}
+	for rows.Next() {
+		var version int
+		err := rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+		versions[version] = true
+	}
+	return versions, rows.Err()
+}
+
+var errMigrationRaced = errors.New("migration was run by another migrator")
+
+// run applies or reverts the migration in a transaction together with its
+// record in schema_migrations. it reports false if another migrator did it
+// first.
+func (m *Migrator) run(
+	ctx context.Context,
+	conn *sql.Conn,
+	migration Migration,
+	up bool,
+) (bool, error) {
+	err := m.runTx(ctx, conn, migration, up)
+	if errors.Is(err, errMigrationRaced) {
+		return false, nil
+	}
+	if err != nil {
+		direction := "up"
+		if !up {
+			direction = "down"
+		}
+		return false, fmt.Errorf(
+			"migration %d_%s %s: %w",
+			migration.Version,
+			migration.Name,
+			direction,
+			err,
+		)
+	}
+
+	slog.Info(
+		"migrated database",
+		slog.Int("version", migration.Version),
+		slog.String("name", migration.Name),
+		slog.Bool("up", up),
+	)
+	return true, nil
+}
+
+func (m *Migrator) runTx(
+	ctx context.Context,
+	conn *sql.Conn,
+	migration Migration,
+	up bool,
+) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the version is checked again inside the transaction, since sqlite has
+	// no lock around the whole migration.
+	var applied bool
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)",
+		migration.Version,
+	).Scan(&applied)
+	if err != nil {
+		return err
+	}
+	if applied == up {
+		return errMigrationRaced
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, migration.Up)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO schema_migrations(version, name, unix_time)
+			VALUES ($1, $2, $3)`,
+			migration.Version,
+			migration.Name,
+			time.Now().Unix(),
+		)
+	} else {
+		_, err = tx.ExecContext(ctx, migration.Down)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			"DELETE FROM schema_migrations WHERE version = $1",
+			migration.Version,
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS processed_update;
+DROP TABLE IF EXISTS bot_user;
+DROP TABLE IF EXISTS feature_flag_member;
+DROP TABLE IF EXISTS setting;
+DROP TABLE IF EXISTS schedule;
+DROP TABLE IF EXISTS feature_flag;
+DROP TABLE IF EXISTS workspace_member;
+DROP TABLE IF EXISTS workspace;
@@ -0,0 +1,141 @@
+-- the schema as it was created before versioned migrations. every statement
+-- is idempotent, so deployments that already have the tables adopt it as is.
+CREATE TABLE IF NOT EXISTS workspace(
+	workspace_id SERIAL PRIMARY KEY,
+	name VARCHAR UNIQUE,
+	owner_id INT,
+	unix_time BIGINT
+);
+CREATE TABLE IF NOT EXISTS workspace_member(
+	workspace_id INT REFERENCES workspace(workspace_id) ON DELETE CASCADE,
+	user_id INT,
+	role SMALLINT,
+	unix_time BIGINT,
+	PRIMARY KEY (workspace_id, user_id)
+);
+
+-- feature flags created before workspaces existed belong to workspace 1.
+INSERT INTO workspace(workspace_id, name, owner_id, unix_time)
+VALUES (1, 'default', 0, EXTRACT(EPOCH FROM now())::BIGINT)
+ON CONFLICT DO NOTHING;
+SELECT setval(
+	pg_get_serial_sequence('workspace', 'workspace_id'),
+	(SELECT MAX(workspace_id) FROM workspace)
+);
+
+CREATE TABLE IF NOT EXISTS feature_flag(
+	workspace_id INT NOT NULL DEFAULT 1 REFERENCES workspace(workspace_id) ON DELETE CASCADE,
+	feature_flag VARCHAR,
+	owner_id INT,
+	paused BOOLEAN NOT NULL DEFAULT FALSE,
+	unix_time BIGINT,
+	CONSTRAINT feature_flag_pkey PRIMARY KEY (workspace_id, feature_flag)
+);
+ALTER TABLE feature_flag ADD COLUMN IF NOT EXISTS paused BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE feature_flag ADD COLUMN IF NOT EXISTS workspace_id INT NOT NULL DEFAULT 1 REFERENCES workspace(workspace_id) ON DELETE CASCADE;
+
+CREATE TABLE IF NOT EXISTS schedule(
+	schedule_id SERIAL PRIMARY KEY,
+	workspace_id INT NOT NULL DEFAULT 1,
+	feature_flag VARCHAR,
+	value TEXT,
+	calendar_type SMALLINT,
+	users_list TEXT,
+	year INT,
+	month INT,
+	day INT,
+	hour INT,
+	minute INT,
+	paused BOOLEAN NOT NULL DEFAULT FALSE,
+	unix_time BIGINT,
+	CONSTRAINT schedule_feature_flag_fkey FOREIGN KEY (workspace_id, feature_flag)
+		REFERENCES feature_flag(workspace_id, feature_flag) ON DELETE CASCADE
+);
+ALTER TABLE schedule ADD COLUMN IF NOT EXISTS paused BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE schedule ADD COLUMN IF NOT EXISTS workspace_id INT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS setting(
+	name VARCHAR PRIMARY KEY,
+	value TEXT
+);
+
+-- the owner of each existing feature flag becomes its first member.
+CREATE TABLE IF NOT EXISTS feature_flag_member(
+	workspace_id INT NOT NULL DEFAULT 1,
+	feature_flag VARCHAR,
+	user_id INT,
+	role SMALLINT,
+	unix_time BIGINT,
+	CONSTRAINT feature_flag_member_pkey PRIMARY KEY (workspace_id, feature_flag, user_id),
+	CONSTRAINT feature_flag_member_feature_flag_fkey FOREIGN KEY (workspace_id, feature_flag)
+		REFERENCES feature_flag(workspace_id, feature_flag) ON DELETE CASCADE
+);
+ALTER TABLE feature_flag_member ADD COLUMN IF NOT EXISTS workspace_id INT NOT NULL DEFAULT 1;
+INSERT INTO feature_flag_member(workspace_id, feature_flag, user_id, role, unix_time)
+SELECT workspace_id, feature_flag, owner_id, 3, unix_time FROM feature_flag
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS bot_user(
+	user_id INT PRIMARY KEY,
+	username VARCHAR,
+	first_name VARCHAR,
+	workspace_id INT,
+	unix_time BIGINT
+);
+ALTER TABLE bot_user ADD COLUMN IF NOT EXISTS workspace_id INT;
+CREATE INDEX IF NOT EXISTS bot_user_username_idx ON bot_user(LOWER(username));
+
+-- scope the names of feature flags created before workspaces existed to
+-- their workspace.
+DO $$
+BEGIN
+	IF (
+		SELECT COUNT(*) FROM information_schema.key_column_usage
+		WHERE table_name = 'feature_flag' AND constraint_name = 'feature_flag_pkey'
+	) = 1 THEN
+		ALTER TABLE schedule DROP CONSTRAINT IF EXISTS schedule_feature_flag_fkey;
+		ALTER TABLE feature_flag_member DROP CONSTRAINT IF EXISTS feature_flag_member_feature_flag_fkey;
+		ALTER TABLE feature_flag_member DROP CONSTRAINT IF EXISTS feature_flag_member_pkey;
+		ALTER TABLE feature_flag DROP CONSTRAINT feature_flag_pkey;
+
+		ALTER TABLE feature_flag ADD CONSTRAINT feature_flag_pkey
+			PRIMARY KEY (workspace_id, feature_flag);
+		ALTER TABLE schedule ADD CONSTRAINT schedule_feature_flag_fkey
+			FOREIGN KEY (workspace_id, feature_flag)
+			REFERENCES feature_flag(workspace_id, feature_flag) ON DELETE CASCADE;
+		ALTER TABLE feature_flag_member ADD CONSTRAINT feature_flag_member_pkey
+			PRIMARY KEY (workspace_id, feature_flag, user_id);
+		ALTER TABLE feature_flag_member ADD CONSTRAINT feature_flag_member_feature_flag_fkey
+			FOREIGN KEY (workspace_id, feature_flag)
+			REFERENCES feature_flag(workspace_id, feature_flag) ON DELETE CASCADE;
+	END IF;
+END $$;
+
+-- everyone who already has access to a feature flag of the default workspace
+-- becomes an editor of it.
+INSERT INTO workspace_member(workspace_id, user_id, role, unix_time)
+SELECT DISTINCT ON (workspace_id, user_id) workspace_id, user_id, 2::SMALLINT, unix_time
+FROM feature_flag_member WHERE workspace_id = 1
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS processed_update(
+	bot VARCHAR NOT NULL DEFAULT '',
+	update_id BIGINT,
+	unix_time BIGINT,
+	CONSTRAINT processed_update_pkey PRIMARY KEY (bot, update_id)
+);
+ALTER TABLE processed_update ADD COLUMN IF NOT EXISTS bot VARCHAR NOT NULL DEFAULT '';
+DO $$
+BEGIN
+	IF (
+		SELECT count(*)
+		FROM pg_constraint c
+		JOIN pg_attribute a
+			ON a.attrelid = c.conrelid AND a.attnum = ANY(c.conkey)
+		WHERE c.conname = 'processed_update_pkey'
+	) = 1 THEN
+		ALTER TABLE processed_update DROP CONSTRAINT processed_update_pkey;
+		ALTER TABLE processed_update
+			ADD CONSTRAINT processed_update_pkey PRIMARY KEY (bot, update_id);
+	END IF;
+END $$;
@@ -0,0 +1,15 @@
+-- audit_log keeps the changes users made. it has no foreign keys so that the
+-- history outlives what it describes.
+CREATE TABLE audit_log(
+	audit_id SERIAL PRIMARY KEY,
+	actor_id INT NOT NULL,
+	workspace_id INT NOT NULL,
+	feature_flag VARCHAR NOT NULL DEFAULT '',
+	entity VARCHAR NOT NULL,
+	action VARCHAR NOT NULL,
+	before_value TEXT NOT NULL DEFAULT '',
+	after_value TEXT NOT NULL DEFAULT '',
+	unix_time BIGINT NOT NULL
+);
+CREATE INDEX audit_log_feature_flag_idx ON audit_log(feature_flag, unix_time);
+CREATE INDEX audit_log_actor_id_idx ON audit_log(actor_id, unix_time);
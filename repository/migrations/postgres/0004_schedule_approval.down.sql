@@ -0,0 +1,5 @@
+-- without approval every schedule would fire, so the schedules that are
+-- pending or rejected are deleted for good.
+DELETE FROM schedule WHERE approval <> 2;
+ALTER TABLE schedule DROP COLUMN creator_id;
+ALTER TABLE schedule DROP COLUMN approval;
@@ -0,0 +1,5 @@
+-- admins added from the bot. the admins of the config are not stored here.
+CREATE TABLE admin(
+	user_id INT PRIMARY KEY,
+	unix_time BIGINT NOT NULL
+);
@@ -0,0 +1,17 @@
+-- single use codes that admins hand out to let users in when access is
+-- restricted. used_by is 0 until the code is redeemed.
+CREATE TABLE invite_code(
+	code TEXT PRIMARY KEY,
+	creator_id INT NOT NULL,
+	unix_time BIGINT NOT NULL,
+	used_by INT NOT NULL DEFAULT 0,
+	used_at BIGINT NOT NULL DEFAULT 0
+);
+
+-- users let in by an invite code. the allowlist of the config is not stored
+-- here.
+CREATE TABLE allowed_user(
+	user_id INT PRIMARY KEY,
+	invite_code TEXT NOT NULL,
+	unix_time BIGINT NOT NULL
+);
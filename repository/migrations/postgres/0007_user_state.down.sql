@@ -0,0 +1,2 @@
+-- users in the middle of a conversation start over.
+DROP TABLE IF EXISTS user_state;
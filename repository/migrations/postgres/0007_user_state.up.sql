@@ -0,0 +1,24 @@
+-- the conversation states of users. deployments that ran before this
+-- migration already have the table, possibly keyed by chat_id alone.
+CREATE TABLE IF NOT EXISTS user_state(
+	bot VARCHAR NOT NULL DEFAULT '',
+	chat_id BIGINT,
+	state JSONB NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	CONSTRAINT user_state_pkey PRIMARY KEY (bot, chat_id)
+);
+ALTER TABLE user_state ADD COLUMN IF NOT EXISTS bot VARCHAR NOT NULL DEFAULT '';
+DO $$
+BEGIN
+	IF (
+		SELECT count(*)
+		FROM pg_constraint c
+		JOIN pg_attribute a
+			ON a.attrelid = c.conrelid AND a.attnum = ANY(c.conkey)
+		WHERE c.conname = 'user_state_pkey'
+	) = 1 THEN
+		ALTER TABLE user_state DROP CONSTRAINT user_state_pkey;
+		ALTER TABLE user_state
+			ADD CONSTRAINT user_state_pkey PRIMARY KEY (bot, chat_id);
+	END IF;
+END $$;
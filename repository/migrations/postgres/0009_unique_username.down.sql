@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS bot_user_username_idx;
+CREATE INDEX bot_user_username_idx ON bot_user(LOWER(username));
@@ -0,0 +1,12 @@
+-- a username belongs to one user at a time. the usernames that more than one
+-- user had are cleared and set again when their current owner talks to a bot.
+DROP INDEX IF EXISTS bot_user_username_idx;
+UPDATE bot_user SET username = ''
+WHERE LOWER(username) IN (
+	SELECT LOWER(username) FROM bot_user
+	WHERE username <> ''
+	GROUP BY LOWER(username)
+	HAVING COUNT(*) > 1
+);
+CREATE UNIQUE INDEX bot_user_username_idx ON bot_user(LOWER(username))
+WHERE username <> '';
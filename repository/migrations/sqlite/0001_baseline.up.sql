@@ -0,0 +1,75 @@
+CREATE TABLE IF NOT EXISTS workspace(
+	workspace_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE,
+	owner_id INTEGER,
+	unix_time INTEGER
+);
+CREATE TABLE IF NOT EXISTS workspace_member(
+	workspace_id INTEGER REFERENCES workspace(workspace_id) ON DELETE CASCADE,
+	user_id INTEGER,
+	role INTEGER,
+	unix_time INTEGER,
+	PRIMARY KEY (workspace_id, user_id)
+);
+INSERT INTO workspace(workspace_id, name, owner_id, unix_time)
+VALUES (1, 'default', 0, unixepoch())
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS feature_flag(
+	workspace_id INTEGER NOT NULL DEFAULT 1 REFERENCES workspace(workspace_id) ON DELETE CASCADE,
+	feature_flag TEXT,
+	owner_id INTEGER,
+	paused BOOLEAN NOT NULL DEFAULT FALSE,
+	unix_time INTEGER,
+	PRIMARY KEY (workspace_id, feature_flag)
+);
+
+CREATE TABLE IF NOT EXISTS schedule(
+	schedule_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	workspace_id INTEGER NOT NULL DEFAULT 1,
+	feature_flag TEXT,
+	value TEXT,
+	calendar_type INTEGER,
+	users_list TEXT,
+	year INTEGER,
+	month INTEGER,
+	day INTEGER,
+	hour INTEGER,
+	minute INTEGER,
+	paused BOOLEAN NOT NULL DEFAULT FALSE,
+	unix_time INTEGER,
+	FOREIGN KEY (workspace_id, feature_flag)
+		REFERENCES feature_flag(workspace_id, feature_flag) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS setting(
+	name TEXT PRIMARY KEY,
+	value TEXT
+);
+
+CREATE TABLE IF NOT EXISTS feature_flag_member(
+	workspace_id INTEGER NOT NULL DEFAULT 1,
+	feature_flag TEXT,
+	user_id INTEGER,
+	role INTEGER,
+	unix_time INTEGER,
+	PRIMARY KEY (workspace_id, feature_flag, user_id),
+	FOREIGN KEY (workspace_id, feature_flag)
+		REFERENCES feature_flag(workspace_id, feature_flag) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS bot_user(
+	user_id INTEGER PRIMARY KEY,
+	username TEXT,
+	first_name TEXT,
+	workspace_id INTEGER,
+	unix_time INTEGER
+);
+CREATE INDEX IF NOT EXISTS bot_user_username_idx ON bot_user(LOWER(username));
+
+CREATE TABLE IF NOT EXISTS processed_update(
+	bot TEXT NOT NULL DEFAULT '',
+	update_id INTEGER,
+	unix_time INTEGER,
+	PRIMARY KEY (bot, update_id)
+);
@@ -0,0 +1,5 @@
+-- the trash can not be kept without deleted_at, so the feature flags in it
+-- are deleted for good along with their schedules and members.
+DELETE FROM feature_flag WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS feature_flag_deleted_at_idx;
+ALTER TABLE feature_flag DROP COLUMN deleted_at;
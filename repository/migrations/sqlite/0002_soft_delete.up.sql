@@ -0,0 +1,3 @@
+ALTER TABLE feature_flag ADD COLUMN deleted_at INTEGER;
+CREATE INDEX feature_flag_deleted_at_idx ON feature_flag(deleted_at)
+	WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,15 @@
+-- audit_log keeps the changes users made. it has no foreign keys so that the
+-- history outlives what it describes.
+CREATE TABLE audit_log(
+	audit_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	actor_id INTEGER NOT NULL,
+	workspace_id INTEGER NOT NULL,
+	feature_flag TEXT NOT NULL DEFAULT '',
+	entity TEXT NOT NULL,
+	action TEXT NOT NULL,
+	before_value TEXT NOT NULL DEFAULT '',
+	after_value TEXT NOT NULL DEFAULT '',
+	unix_time INTEGER NOT NULL
+);
+CREATE INDEX audit_log_feature_flag_idx ON audit_log(feature_flag, unix_time);
+CREATE INDEX audit_log_actor_id_idx ON audit_log(actor_id, unix_time);
@@ -0,0 +1,4 @@
+-- schedules created before approvals existed are approved. 2 is
+-- entities.ApprovedApproval.
+ALTER TABLE schedule ADD COLUMN approval INTEGER NOT NULL DEFAULT 2;
+ALTER TABLE schedule ADD COLUMN creator_id INTEGER NOT NULL DEFAULT 0;
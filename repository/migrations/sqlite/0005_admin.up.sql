@@ -0,0 +1,5 @@
+-- admins added from the bot. the admins of the config are not stored here.
+CREATE TABLE admin(
+	user_id INTEGER PRIMARY KEY,
+	unix_time INTEGER NOT NULL
+);
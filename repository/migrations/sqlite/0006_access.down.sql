@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS allowed_user;
+DROP TABLE IF EXISTS invite_code;
@@ -0,0 +1,9 @@
+-- the conversation states of users. updated_at is in unix seconds.
+-- deployments that ran before this migration already have the table.
+CREATE TABLE IF NOT EXISTS user_state(
+	bot TEXT NOT NULL DEFAULT '',
+	chat_id INTEGER,
+	state TEXT NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (bot, chat_id)
+);
@@ -0,0 +1,11 @@
+-- the bots each user has talked to, so that a bot only messages its own
+-- users. the users recorded before this migration talked to the bot of the
+-- single bot config, whose name is empty.
+CREATE TABLE user_bot(
+	bot TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	unix_time INTEGER NOT NULL,
+	PRIMARY KEY (bot, user_id)
+);
+INSERT INTO user_bot(bot, user_id, unix_time)
+SELECT '', user_id, COALESCE(unix_time, 0) FROM bot_user;
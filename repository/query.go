@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+// querier runs queries on either the database or a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// queryAll runs query and scans every returned row with scan. the rows are
+// closed before it returns, and an error that ended the iteration early is
+// returned along with the rows scanned before it.
+func queryAll[T any](
+	ctx context.Context,
+	db querier,
+	scan func(row rowScanner) (T, error),
+	query string,
+	args ...any,
+) ([]T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// scanFeatureFlag scans the columns
+// workspace_id, feature_flag, owner_id, paused, unix_time,
+// COALESCE(deleted_at, 0).
+func scanFeatureFlag(row rowScanner) (entities.FeatureFlag, error) {
+	var featureFlag entities.FeatureFlag
+	err := row.Scan(
+		&featureFlag.WorkspaceId,
+		&featureFlag.Name,
+		&featureFlag.OwnerId,
+		&featureFlag.Paused,
+		&featureFlag.UnixTime,
+		&featureFlag.DeletedAt,
+	)
+	return featureFlag, err
+}
+
+// scanSchedule scans the columns
+// schedule_id, workspace_id, feature_flag, value, calendar_type, users_list,
+// year, month, day, hour, minute, paused, unix_time, approval, creator_id.
+func scanSchedule(row rowScanner) (entities.Schedule, error) {
+	var schedule entities.Schedule
+	err := row.Scan(
+		&schedule.ScheduleId,
+		&schedule.WorkspaceId,
+		&schedule.FeatureFlagName,
+		&schedule.Value,
+		&schedule.Calendar.Type,
+		&schedule.UsersList,
+		&schedule.Calendar.Year,
+		&schedule.Calendar.Month,
+		&schedule.Calendar.Day,
+		&schedule.Calendar.Hour,
+		&schedule.Calendar.Minute,
+		&schedule.Paused,
+		&schedule.UnixTime,
+		&schedule.Approval,
+		&schedule.CreatorId,
+	)
+	return schedule, err
+}
+
+// scanFeatureFlagMember scans the columns
+// workspace_id, feature_flag, user_id, role, unix_time.
+func scanFeatureFlagMember(row rowScanner) (entities.FeatureFlagMember, error) {
+	var member entities.FeatureFlagMember
+	err := row.Scan(
+		&member.WorkspaceId,
+		&member.FeatureFlagName,
+		&member.UserId,
+		&member.Role,
+		&member.UnixTime,
+	)
+	return member, err
+}
+
+// scanWorkspace scans the columns workspace_id, name, owner_id, unix_time.
+func scanWorkspace(row rowScanner) (entities.Workspace, error) {
+	var workspace entities.Workspace
+	err := row.Scan(
+		&workspace.WorkspaceId,
+		&workspace.Name,
+		&workspace.OwnerId,
+		&workspace.UnixTime,
+	)
+	return workspace, err
+}
+
+// scanWorkspaceMember scans the columns workspace_id, user_id, role,
+// unix_time.
+func scanWorkspaceMember(row rowScanner) (entities.WorkspaceMember, error) {
+	var member entities.WorkspaceMember
+	err := row.Scan(
+		&member.WorkspaceId,
+		&member.UserId,
+		&member.Role,
+		&member.UnixTime,
+	)
+	return member, err
+}
+
+// scanAuditLog scans the columns
+// audit_id, actor_id, workspace_id, feature_flag, entity, action,
+// before_value, after_value, unix_time.
+func scanAuditLog(row rowScanner) (entities.AuditLog, error) {
+	var auditLog entities.AuditLog
+	err := row.Scan(
+		&auditLog.AuditId,
+		&auditLog.ActorId,
+		&auditLog.WorkspaceId,
+		&auditLog.FeatureFlagName,
+		&auditLog.Entity,
+		&auditLog.Action,
+		&auditLog.Before,
+		&auditLog.After,
+		&auditLog.UnixTime,
+	)
+	return auditLog, err
+}
+
+// scanAdmin scans the columns user_id, unix_time.
+func scanAdmin(row rowScanner) (entities.Admin, error) {
+	var admin entities.Admin
+	err := row.Scan(&admin.UserId, &admin.UnixTime)
+	return admin, err
+}
+
+func scanAllowedUser(row rowScanner) (entities.AllowedUser, error) {
+	var allowedUser entities.AllowedUser
+	err := row.Scan(&allowedUser.UserId, &allowedUser.InviteCode, &allowedUser.UnixTime)
+	return allowedUser, err
+}
+
+// scanInt scans a single integer column.
+func scanInt(row rowScanner) (int, error) {
+	var value int
+	err := row.Scan(&value)
+	return value, err
+}
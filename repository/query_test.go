@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestQueryAll(t *testing.T) {
+	db, err := OpenSqlite(filepath.Join(t.TempDir(), "query.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	errScan := errors.New("scan failed")
+	failOn := func(failing int) func(row rowScanner) (int, error) {
+		return func(row rowScanner) (int, error) {
+			value, err := scanInt(row)
+			if err == nil && value == failing {
+				return 0, errScan
+			}
+			return value, err
+		}
+	}
+
+	values := "SELECT column1 FROM (VALUES (1), (2), (3)) ORDER BY column1"
+	tests := []struct {
+		name    string
+		scan    func(row rowScanner) (int, error)
+		query   string
+		want    []int
+		wantErr bool
+	}{
+		{"all rows", scanInt, values, []int{1, 2, 3}, false},
+		{"no rows", scanInt, values + " LIMIT 0", nil, false},
+		{"query error", scanInt, "SELECT * FROM missing", nil, true},
+		{"scan error", failOn(3), values, []int{1, 2}, true},
+		{
+			// the third row overflows while it is stepped to, which ends the
+			// iteration and is reported by rows.Err.
+			"rows error",
+			scanInt,
+			`SELECT CASE WHEN column1 < 3 THEN column1
+				ELSE abs(-9223372036854775807 - column1) END
+			FROM (VALUES (1), (2), (3)) ORDER BY column1`,
+			[]int{1, 2},
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := queryAll(t.Context(), db, test.scan, test.query)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("queryAll() error = %v, want error %v", err, test.wantErr)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("queryAll() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
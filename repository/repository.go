@@ -1,33 +1,181 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"log/slog"
+	"runtime/trace"
+	"strconv"
 	"time"
 
 	"github.com/fatemehkarimi/chronos_bot/entities"
 )
 
+const (
+	PostgresDriver = "postgres"
+	SqliteDriver   = "sqlite"
+	MemoryDriver   = "memory"
+)
+
 type DatabaseConfig struct {
+	// Driver is one of postgres, sqlite and memory. postgres is used if it
+	// is empty.
+	Driver string
+	// Path is the file of the sqlite database.
+	Path     string
 	User     string
 	Password string
 	Host     string
 	Port     int
 	DBName   string
+	// QueryTimeout bounds every query. DefaultQueryTimeout is used if it is
+	// not set.
+	QueryTimeout time.Duration
 }
 
 type Repository interface {
-	Init() error
-	CreateTableFeatureFlag() error
-	CreateTableSchedule() error
-	AddFeatureFlag(ownerId int, featureFlag string) error
-	AddSchedule(schedule entities.Schedule) (int, error)
-	RemoveFeatureFlag(featureFlag string) error
-	RemoveSchedule(scheduleId int) error
-	GetFeatureFlagByName(name string) (*entities.FeatureFlag, error)
-	GetFeatureFlagsByOwnerId(ownerId int) ([]entities.FeatureFlag, error)
+	Init(ctx context.Context) error
+	AddFeatureFlag(
+		ctx context.Context,
+		workspaceId int,
+		ownerId int,
+		featureFlag string,
+	) error
+	AddSchedule(ctx context.Context, schedule entities.Schedule) (int, error)
+	RemoveFeatureFlag(
+		ctx context.Context,
+		workspaceId int,
+		featureFlag string,
+	) error
+	RemoveSchedule(ctx context.Context, scheduleId int) error
+	GetDeletedFeatureFlagsByUserId(
+		ctx context.Context,
+		workspaceId int,
+		userId int,
+		minRole entities.Role,
+	) ([]entities.FeatureFlag, error)
+	RestoreFeatureFlag(
+		ctx context.Context,
+		workspaceId int,
+		featureFlag string,
+	) error
+	PurgeFeatureFlagsDeletedBefore(
+		ctx context.Context,
+		t time.Time,
+	) ([]entities.FeatureFlag, error)
+	GetFeatureFlagByName(
+		ctx context.Context,
+		workspaceId int,
+		name string,
+	) (*entities.FeatureFlag, error)
+	GetFeatureFlagsByOwnerId(
+		ctx context.Context,
+		ownerId int,
+	) ([]entities.FeatureFlag, error)
+	GetFeatureFlagsByUserId(
+		ctx context.Context,
+		workspaceId int,
+		userId int,
+		minRole entities.Role,
+	) ([]entities.FeatureFlag, error)
+	GetFeatureFlagRole(
+		ctx context.Context,
+		workspaceId int,
+		featureFlag string,
+		userId int,
+	) (entities.Role, error)
+	GetFeatureFlagMembers(
+		ctx context.Context,
+		workspaceId int,
+		featureFlag string,
+	) ([]entities.FeatureFlagMember, error)
+	SetFeatureFlagMember(
+		ctx context.Context,
+		workspaceId int,
+		featureFlag string,
+		userId int,
+		role entities.Role,
+	) error
+	RemoveFeatureFlagMember(
+		ctx context.Context,
+		workspaceId int,
+		featureFlag string,
+		userId int,
+	) error
+	TransferFeatureFlagOwnership(
+		ctx context.Context,
+		workspaceId int,
+		featureFlag string,
+		newOwnerId int,
+	) error
+	// UpsertBotUser records user and that the user talked to bot.
+	UpsertBotUser(ctx context.Context, bot string, user entities.User) error
+	GetBotUserByUserName(
+		ctx context.Context,
+		userName string,
+	) (*entities.BotUser, error)
+	CreateWorkspace(ctx context.Context, name string, ownerId int) (int, error)
+	GetWorkspaceById(
+		ctx context.Context,
+		workspaceId int,
+	) (*entities.Workspace, error)
+	GetWorkspacesByUserId(
+		ctx context.Context,
+		userId int,
+	) ([]entities.Workspace, error)
+	GetWorkspaceRole(
+		ctx context.Context,
+		workspaceId int,
+		userId int,
+	) (entities.Role, error)
+	GetWorkspaceMembers(
+		ctx context.Context,
+		workspaceId int,
+	) ([]entities.WorkspaceMember, error)
+	SetWorkspaceMember(
+		ctx context.Context,
+		workspaceId int,
+		userId int,
+		role entities.Role,
+	) error
+	RemoveWorkspaceMember(
+		ctx context.Context,
+		workspaceId int,
+		userId int,
+	) error
+	GetCurrentWorkspaceId(ctx context.Context, userId int) (int, error)
+	SetCurrentWorkspaceId(
+		ctx context.Context,
+		userId int,
+		workspaceId int,
+	) error
+	GetScheduleById(
+		ctx context.Context,
+		scheduleId int,
+	) (*entities.Schedule, error)
+	GetSchedulesByFeatureFlag(
+		ctx context.Context,
+		workspaceId int,
+		featureFlag string,
+	) ([]entities.Schedule, error)
+	SetFeatureFlagPaused(
+		ctx context.Context,
+		workspaceId int,
+		featureFlag string,
+		paused bool,
+	) error
+	SetSchedulePaused(ctx context.Context, scheduleId int, paused bool) error
+	ReviewSchedule(
+		ctx context.Context,
+		scheduleId int,
+		approval entities.Approval,
+	) error
+	SetSchedulingPaused(ctx context.Context, paused bool) error
+	IsSchedulingPaused(ctx context.Context) (bool, error)
+	IsScheduleActive(ctx context.Context, scheduleId int) (bool, error)
 	GetScheduleByTime(
+		ctx context.Context,
 		calendarType entities.CalendarType,
 		year int,
 		month int,
@@ -35,64 +183,211 @@ type Repository interface {
 		startTime entities.CalendarTime,
 		endTime entities.CalendarTime,
 	) ([]entities.Schedule, error)
+	MarkUpdateProcessed(
+		ctx context.Context,
+		bot string,
+		updateId int,
+	) (bool, error)
+	GetLastProcessedUpdateId(ctx context.Context, bot string) (int, error)
+	DeleteProcessedUpdatesBefore(ctx context.Context, t time.Time) error
+	AddAuditLog(ctx context.Context, auditLog entities.AuditLog) error
+	GetAuditLogsByFeatureFlag(
+		ctx context.Context,
+		workspaceId int,
+		featureFlag string,
+		limit int,
+	) ([]entities.AuditLog, error)
+	GetAuditLogsByActorId(
+		ctx context.Context,
+		actorId int,
+		limit int,
+	) ([]entities.AuditLog, error)
+	IsAdmin(ctx context.Context, userId int) (bool, error)
+	GetAdmins(ctx context.Context) ([]entities.Admin, error)
+	AddAdmin(ctx context.Context, userId int) error
+	RemoveAdmin(ctx context.Context, userId int) error
+	GetAllFeatureFlags(ctx context.Context) ([]entities.FeatureFlag, error)
+	PurgeFeatureFlag(
+		ctx context.Context,
+		workspaceId int,
+		featureFlag string,
+	) error
+	// GetBotUserIds returns the users who talked to bot.
+	GetBotUserIds(ctx context.Context, bot string) ([]int, error)
+	GetStats(ctx context.Context) (entities.Stats, error)
+	AddInviteCode(ctx context.Context, code string, creatorId int) error
+	RedeemInviteCode(ctx context.Context, code string, userId int) error
+	IsAllowedUser(ctx context.Context, userId int) (bool, error)
+	GetAllowedUsers(ctx context.Context) ([]entities.AllowedUser, error)
+	RemoveAllowedUser(ctx context.Context, userId int) error
+	// WithTx calls fn with a repository whose methods run in a single
+	// transaction. the transaction is committed if fn returns nil and rolled
+	// back otherwise.
+	WithTx(ctx context.Context, fn func(repo Repository) error) error
 }
 
+const schedulingPausedSetting = "scheduling_paused"
+
+const (
+	DefaultQueryTimeout = 10 * time.Second
+	// slowQuery is how long a query may take before it is logged as slow.
+	slowQuery = time.Second
+)
+
 type PostgresRepository struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration
+
+	// tx is set on the repositories passed to the functions of WithTx.
+	tx *sql.Tx
 }
 
-func CreateNewRepository(db *sql.DB) Repository {
+func CreateNewRepository(db *sql.DB, timeout time.Duration) Repository {
+	if timeout <= 0 {
+		timeout = DefaultQueryTimeout
+	}
+
 	return &PostgresRepository{
-		DB: db,
+		DB:      db,
+		Timeout: timeout,
 	}
 }
 
-func (repo *PostgresRepository) CreateTableFeatureFlag() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS feature_flag(
-		feature_flag VARCHAR PRIMARY KEY,
-		owner_id INT,
-		unix_time BIGINT
-	);`
+// start bounds ctx with the query timeout and traces method as a region.
+// the returned func must be called when the method returns; it logs the
+// method if it was slow.
+func (repo *PostgresRepository) start(
+	ctx context.Context,
+	method string,
+) (context.Context, func()) {
+	var cancel context.CancelFunc = func() {}
+	if repo.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, repo.Timeout)
+	}
 
-	_, err := repo.DB.Exec(query)
-	return err
-}
+	region := trace.StartRegion(ctx, "repository."+method)
+	startTime := time.Now()
+	return ctx, func() {
+		region.End()
+		cancel()
 
-func (repo *PostgresRepository) CreateTableSchedule() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS schedule(
-		schedule_id SERIAL PRIMARY KEY,
-		feature_flag VARCHAR REFERENCES feature_flag(feature_flag) ON DELETE CASCADE,
-		value TEXT,
-		calendar_type SMALLINT,
-	    users_list TEXT,
-		year INT,
-		month INT,
-		day INT,
-		hour INT,
-		minute INT,
-		unix_time BIGINT
-	);`
-	_, err := repo.DB.Exec(query)
-	return err
+		duration := time.Since(startTime)
+		if duration > slowQuery {
+			slog.Warn(
+				"slow repository query",
+				slog.String("method", method),
+				slog.Duration("duration", duration),
+			)
+		}
+	}
 }
 
 func (repo *PostgresRepository) AddFeatureFlag(
+	ctx context.Context,
+	workspaceId int,
 	ownerId int,
 	featureFlag string,
 ) error {
-	query := `INSERT INTO feature_flag(owner_id, feature_flag, unix_time) VALUES ($1, $2, $3);`
-	_, err := repo.DB.Exec(query, ownerId, featureFlag, time.Now().Unix())
-	return err
+	ctx, done := repo.start(ctx, "AddFeatureFlag")
+	defer done()
+
+	tx, err := repo.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	SELECT deleted_at IS NOT NULL FROM feature_flag
+	WHERE workspace_id=$1 AND feature_flag=$2;
+	`
+	var deleted bool
+	err = tx.QueryRowContext(ctx, query, workspaceId, featureFlag).Scan(&deleted)
+	switch {
+	case err == nil && deleted:
+		return ErrFlagDeleted
+	case err == nil:
+		return ErrFlagExists
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	now := time.Now().Unix()
+	query = `
+	INSERT INTO feature_flag(workspace_id, owner_id, feature_flag, unix_time)
+	VALUES ($1, $2, $3, $4);
+	`
+	_, err = tx.ExecContext(ctx, query, workspaceId, ownerId, featureFlag, now)
+	if err != nil {
+		return conflict(err, ErrFlagExists, nil)
+	}
+
+	query = `
+	INSERT INTO feature_flag_member(workspace_id, feature_flag, user_id, role, unix_time)
+	VALUES ($1, $2, $3, $4, $5);
+	`
+	_, err = tx.ExecContext(ctx,
+		query,
+		workspaceId,
+		featureFlag,
+		ownerId,
+		entities.OwnerRole,
+		now,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (repo *PostgresRepository) AddSchedule(schedule entities.Schedule) (
+// scheduleApproval returns the approval a new schedule is stored with.
+// schedules added without one are approved.
+func scheduleApproval(schedule entities.Schedule) entities.Approval {
+	if schedule.Approval == 0 {
+		return entities.ApprovedApproval
+	}
+	return schedule.Approval
+}
+
+func (repo *PostgresRepository) AddSchedule(
+	ctx context.Context,
+	schedule entities.Schedule,
+) (
 	int,
 	error,
 ) {
+	ctx, done := repo.start(ctx, "AddSchedule")
+	defer done()
+
+	tx, err := repo.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM feature_flag
+		WHERE workspace_id=$1 AND feature_flag=$2 AND deleted_at IS NULL
+	);
+	`
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		query,
+		schedule.WorkspaceId,
+		schedule.FeatureFlagName,
+	).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrFlagNotFound
+	}
+
+	query = `
 	INSERT INTO schedule(
+	 	workspace_id,
 	 	feature_flag,
 	 	value,
 	 	users_list,
@@ -102,12 +397,16 @@ func (repo *PostgresRepository) AddSchedule(schedule entities.Schedule) (
 	 	day,
 	 	hour,
 	 	minute,
-	 	unix_time
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING schedule_id`
+	 	unix_time,
+	 	approval,
+	 	creator_id
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	RETURNING schedule_id`
 	var scheduleId int
 
-	err := repo.DB.QueryRow(
+	err = tx.QueryRowContext(ctx,
 		query,
+		schedule.WorkspaceId,
 		schedule.FeatureFlagName,
 		schedule.Value,
 		schedule.UsersList,
@@ -118,79 +417,74 @@ func (repo *PostgresRepository) AddSchedule(schedule entities.Schedule) (
 		schedule.Calendar.Hour,
 		schedule.Calendar.Minute,
 		time.Now().Unix(),
+		scheduleApproval(schedule),
+		schedule.CreatorId,
 	).Scan(&scheduleId)
-	return scheduleId, err
+	if err != nil {
+		return 0, conflict(err, nil, ErrFlagNotFound)
+	}
+
+	return scheduleId, tx.Commit()
 }
 
-func (repo *PostgresRepository) RemoveSchedule(scheduleId int) error {
+func (repo *PostgresRepository) RemoveSchedule(
+	ctx context.Context,
+	scheduleId int,
+) error {
+	ctx, done := repo.start(ctx, "RemoveSchedule")
+	defer done()
+
 	query := `
 	DELETE FROM schedule where schedule_id=$1
 	`
-	_, err := repo.DB.Exec(query, scheduleId)
+	_, err := repo.conn().ExecContext(ctx, query, scheduleId)
 	return err
 }
 
-func (repo *PostgresRepository) GetFeatureFlagByName(name string) (
-	*entities.FeatureFlag,
-	error,
-) {
+func (repo *PostgresRepository) GetFeatureFlagByName(
+	ctx context.Context,
+	workspaceId int,
+	name string,
+) (*entities.FeatureFlag, error) {
+	ctx, done := repo.start(ctx, "GetFeatureFlagByName")
+	defer done()
+
 	query := `
-	SELECT feature_flag, owner_id, unix_time FROM feature_flag WHERE feature_flag=$1;
+	SELECT workspace_id, feature_flag, owner_id, paused, unix_time, COALESCE(deleted_at, 0)
+	FROM feature_flag
+	WHERE workspace_id=$1 AND feature_flag=$2 AND deleted_at IS NULL;
 	`
 
-	var featureFlag entities.FeatureFlag
-	err := repo.DB.QueryRow(query, name).Scan(
-		&featureFlag.Name,
-		&featureFlag.OwnerId,
-		&featureFlag.UnixTime,
+	featureFlag, err := scanFeatureFlag(
+		repo.conn().QueryRowContext(ctx, query, workspaceId, name),
 	)
-
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, err
-		}
+		return nil, notFound(err, ErrFlagNotFound)
 	}
 
 	return &featureFlag, nil
 }
 
-func (repo *PostgresRepository) GetFeatureFlagsByOwnerId(ownerId int) (
+func (repo *PostgresRepository) GetFeatureFlagsByOwnerId(
+	ctx context.Context,
+	ownerId int,
+) (
 	[]entities.FeatureFlag,
 	error,
 ) {
+	ctx, done := repo.start(ctx, "GetFeatureFlagsByOwnerId")
+	defer done()
+
 	query := `
-	SELECT feature_flag, owner_id, unix_time FROM feature_flag WHERE owner_id=$1;
+	SELECT workspace_id, feature_flag, owner_id, paused, unix_time, COALESCE(deleted_at, 0)
+	FROM feature_flag WHERE owner_id=$1 AND deleted_at IS NULL
+	ORDER BY feature_flag;
 	`
-	var featureFlags []entities.FeatureFlag
-	rows, err := repo.DB.Query(query, ownerId)
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			log.Fatal(err)
-		}
-	}(rows)
-
-	if err != nil {
-		return featureFlags, err
-	}
-
-	var featureFlag entities.FeatureFlag
-	for rows.Next() {
-		err := rows.Scan(
-			&featureFlag.Name,
-			&featureFlag.OwnerId,
-			&featureFlag.UnixTime,
-		)
-		if err != nil {
-			// todo: this is not really correct
-			return featureFlags, err
-		}
-		featureFlags = append(featureFlags, featureFlag)
-	}
-	return featureFlags, nil
+	return queryAll(ctx, repo.conn(), scanFeatureFlag, query, ownerId)
 }
 
 func (repo *PostgresRepository) GetScheduleByTime(
+	ctx context.Context,
 	calendarType entities.CalendarType,
 	year int,
 	month int,
@@ -198,23 +492,36 @@ func (repo *PostgresRepository) GetScheduleByTime(
 	startTime entities.CalendarTime,
 	endTime entities.CalendarTime,
 ) ([]entities.Schedule, error) {
+	ctx, done := repo.start(ctx, "GetScheduleByTime")
+	defer done()
+
 	query := `
-	SELECT schedule_id, feature_flag, value, calendar_type, users_list, year, month, day, hour, minute, unix_time
-	FROM schedule
-	WHERE calendar_type = $1
-	AND day = $2
-	AND (year = 0 OR year = $3)
-	AND (month = 0 OR month = $4)
+	SELECT s.schedule_id, s.workspace_id, s.feature_flag, s.value, s.calendar_type, s.users_list, s.year, s.month, s.day, s.hour, s.minute, s.paused, s.unix_time,
+		s.approval, s.creator_id
+	FROM schedule s
+	JOIN feature_flag f ON f.workspace_id = s.workspace_id AND f.feature_flag = s.feature_flag
+	WHERE s.calendar_type = $1
+	AND s.day = $2
+	AND (s.year = 0 OR s.year = $3)
+	AND (s.month = 0 OR s.month = $4)
 	AND (
-		(hour = $5 AND hour = $7 AND minute >= $6 AND minute <= $8)
-		OR (hour = $5 AND minute >= $6)
-		OR (hour > $5 AND hour < $7)
-		OR (hour = $7 AND minute <= $8)
+		(s.hour = $5 AND s.hour = $7 AND s.minute >= $6 AND s.minute <= $8)
+		OR (s.hour = $5 AND s.minute >= $6)
+		OR (s.hour > $5 AND s.hour < $7)
+		OR (s.hour = $7 AND s.minute <= $8)
+	)
+	AND s.paused = FALSE
+	AND s.approval = $10
+	AND f.paused = FALSE
+	AND f.deleted_at IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM setting WHERE name = $9 AND value = 'true'
 	)
 	`
 
-	var schedules []entities.Schedule
-	rows, err := repo.DB.Query(
+	return queryAll(ctx,
+		repo.conn(),
+		scanSchedule,
 		query,
 		calendarType,
 		day,
@@ -224,63 +531,532 @@ func (repo *PostgresRepository) GetScheduleByTime(
 		startTime.Minute,
 		endTime.Hour,
 		endTime.Minute,
+		schedulingPausedSetting,
+		entities.ApprovedApproval,
 	)
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			log.Fatal(err)
-		}
-	}(rows)
+}
+
+// RemoveFeatureFlag moves the feature flag to the trash. it is hidden with
+// its schedules until it is restored or purged.
+func (repo *PostgresRepository) RemoveFeatureFlag(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+) error {
+	ctx, done := repo.start(ctx, "RemoveFeatureFlag")
+	defer done()
+
+	query := `
+	UPDATE feature_flag SET deleted_at=$3
+	WHERE workspace_id=$1 AND feature_flag=$2 AND deleted_at IS NULL;
+	`
+	_, err := repo.conn().ExecContext(ctx,
+		query,
+		workspaceId,
+		featureFlag,
+		time.Now().Unix(),
+	)
+	return err
+}
+
+// Init migrates the database to the latest version of the schema. the query
+// timeout does not apply to migrations.
+func (repo *PostgresRepository) Init(ctx context.Context) error {
+	_, err := NewMigrator(repo.DB, PostgresDriver).Up(ctx)
+	return err
+}
+
+func (repo *PostgresRepository) GetScheduleById(
+	ctx context.Context,
+	scheduleId int,
+) (
+	*entities.Schedule,
+	error,
+) {
+	ctx, done := repo.start(ctx, "GetScheduleById")
+	defer done()
+
+	query := `
+	SELECT s.schedule_id, s.workspace_id, s.feature_flag, s.value, s.calendar_type, s.users_list, s.year, s.month, s.day, s.hour, s.minute, s.paused, s.unix_time,
+		s.approval, s.creator_id
+	FROM schedule s
+	JOIN feature_flag f ON f.workspace_id = s.workspace_id AND f.feature_flag = s.feature_flag
+	WHERE s.schedule_id=$1 AND f.deleted_at IS NULL;
+	`
+
+	schedule, err := scanSchedule(repo.conn().QueryRowContext(ctx, query, scheduleId))
+	if err != nil {
+		return nil, notFound(err, ErrScheduleNotFound)
+	}
+
+	return &schedule, nil
+}
 
+func (repo *PostgresRepository) GetSchedulesByFeatureFlag(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+) ([]entities.Schedule, error) {
+	ctx, done := repo.start(ctx, "GetSchedulesByFeatureFlag")
+	defer done()
+
+	query := `
+	SELECT s.schedule_id, s.workspace_id, s.feature_flag, s.value, s.calendar_type, s.users_list, s.year, s.month, s.day, s.hour, s.minute, s.paused, s.unix_time,
+		s.approval, s.creator_id
+	FROM schedule s
+	JOIN feature_flag f ON f.workspace_id = s.workspace_id AND f.feature_flag = s.feature_flag
+	WHERE s.workspace_id=$1 AND s.feature_flag=$2 AND f.deleted_at IS NULL
+	ORDER BY s.schedule_id;
+	`
+
+	return queryAll(ctx, repo.conn(), scanSchedule, query, workspaceId, featureFlag)
+}
+
+func (repo *PostgresRepository) SetFeatureFlagPaused(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+	paused bool,
+) error {
+	ctx, done := repo.start(ctx, "SetFeatureFlagPaused")
+	defer done()
+
+	query := `
+	UPDATE feature_flag SET paused=$3 WHERE workspace_id=$1 AND feature_flag=$2;
+	`
+	_, err := repo.conn().ExecContext(ctx, query, workspaceId, featureFlag, paused)
+	return err
+}
+
+func (repo *PostgresRepository) SetSchedulePaused(
+	ctx context.Context,
+	scheduleId int,
+	paused bool,
+) error {
+	ctx, done := repo.start(ctx, "SetSchedulePaused")
+	defer done()
+
+	query := `UPDATE schedule SET paused=$2 WHERE schedule_id=$1;`
+	_, err := repo.conn().ExecContext(ctx, query, scheduleId, paused)
+	return err
+}
+
+// ReviewSchedule approves or rejects a pending schedule. it returns
+// ErrScheduleReviewed if the schedule is not pending anymore.
+func (repo *PostgresRepository) ReviewSchedule(
+	ctx context.Context,
+	scheduleId int,
+	approval entities.Approval,
+) error {
+	ctx, done := repo.start(ctx, "ReviewSchedule")
+	defer done()
+
+	query := `UPDATE schedule SET approval=$2 WHERE schedule_id=$1 AND approval=$3;`
+	result, err := repo.conn().ExecContext(ctx,
+		query,
+		scheduleId,
+		approval,
+		entities.PendingApproval,
+	)
 	if err != nil {
-		return schedules, err
-	}
-
-	var schedule entities.Schedule
-	for rows.Next() {
-		err := rows.Scan(
-			&schedule.ScheduleId,
-			&schedule.FeatureFlagName,
-			&schedule.Value,
-			&schedule.Calendar.Type,
-			&schedule.UsersList,
-			&schedule.Calendar.Year,
-			&schedule.Calendar.Month,
-			&schedule.Calendar.Day,
-			&schedule.Calendar.Hour,
-			&schedule.Calendar.Minute,
-			&schedule.UnixTime,
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	query = `SELECT EXISTS (SELECT 1 FROM schedule WHERE schedule_id=$1);`
+	var exists bool
+	err = repo.conn().QueryRowContext(ctx, query, scheduleId).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrScheduleNotFound
+	}
+	return ErrScheduleReviewed
+}
+
+func (repo *PostgresRepository) SetSchedulingPaused(
+	ctx context.Context,
+	paused bool,
+) error {
+	ctx, done := repo.start(ctx, "SetSchedulingPaused")
+	defer done()
+
+	query := `
+	INSERT INTO setting(name, value) VALUES ($1, $2)
+	ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value;
+	`
+	_, err := repo.conn().ExecContext(ctx,
+		query,
+		schedulingPausedSetting,
+		strconv.FormatBool(paused),
+	)
+	return err
+}
+
+func (repo *PostgresRepository) IsSchedulingPaused(ctx context.Context) (bool, error) {
+	ctx, done := repo.start(ctx, "IsSchedulingPaused")
+	defer done()
+
+	query := `SELECT value FROM setting WHERE name=$1;`
+
+	var value string
+	err := repo.conn().QueryRowContext(ctx, query, schedulingPausedSetting).Scan(&value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return strconv.ParseBool(value)
+}
+
+// IsScheduleActive reports whether the schedule still exists, is approved
+// and neither the schedule, its feature flag nor the whole scheduling is
+// paused.
+func (repo *PostgresRepository) IsScheduleActive(
+	ctx context.Context,
+	scheduleId int,
+) (bool, error) {
+	ctx, done := repo.start(ctx, "IsScheduleActive")
+	defer done()
+
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM schedule s
+		JOIN feature_flag f ON f.workspace_id = s.workspace_id AND f.feature_flag = s.feature_flag
+		WHERE s.schedule_id = $1
+		AND s.paused = FALSE
+		AND s.approval = $3
+		AND f.paused = FALSE
+		AND f.deleted_at IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM setting WHERE name = $2 AND value = 'true'
 		)
+	);
+	`
+
+	var active bool
+	err := repo.conn().QueryRowContext(
+		ctx,
+		query,
+		scheduleId,
+		schedulingPausedSetting,
+		entities.ApprovedApproval,
+	).Scan(&active)
+	return active, err
+}
+
+func (repo *PostgresRepository) GetFeatureFlagsByUserId(
+	ctx context.Context,
+	workspaceId int,
+	userId int,
+	minRole entities.Role,
+) ([]entities.FeatureFlag, error) {
+	ctx, done := repo.start(ctx, "GetFeatureFlagsByUserId")
+	defer done()
+
+	query := `
+	SELECT f.workspace_id, f.feature_flag, f.owner_id, f.paused, f.unix_time, COALESCE(f.deleted_at, 0)
+	FROM feature_flag f
+	JOIN feature_flag_member m
+		ON m.workspace_id = f.workspace_id AND m.feature_flag = f.feature_flag AND m.user_id = $2
+	WHERE f.workspace_id = $1 AND f.deleted_at IS NULL AND m.role >= $3
+	ORDER BY f.feature_flag;
+	`
+
+	return queryAll(ctx,
+		repo.conn(),
+		scanFeatureFlag,
+		query,
+		workspaceId,
+		userId,
+		minRole,
+	)
+}
+
+// GetFeatureFlagRole returns the role of the user on the feature flag. only
+// members of the feature flag have access to it, membership of its workspace
+// grants none. zero is returned if the user has no access to the feature
+// flag.
+func (repo *PostgresRepository) GetFeatureFlagRole(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+	userId int,
+) (entities.Role, error) {
+	ctx, done := repo.start(ctx, "GetFeatureFlagRole")
+	defer done()
 
-		if err != nil {
-			// todo: this is not really correct
-			return schedules, err
+	query := `
+	SELECT m.role
+	FROM feature_flag f
+	JOIN feature_flag_member m
+		ON m.workspace_id = f.workspace_id AND m.feature_flag = f.feature_flag AND m.user_id = $3
+	WHERE f.workspace_id = $1 AND f.feature_flag = $2 AND f.deleted_at IS NULL;
+	`
+
+	var role entities.Role
+	err := repo.conn().QueryRowContext(ctx,
+		query,
+		workspaceId,
+		featureFlag,
+		userId,
+	).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
 		}
+		return 0, err
+	}
+
+	return role, nil
+}
+
+func (repo *PostgresRepository) GetFeatureFlagMembers(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+) ([]entities.FeatureFlagMember, error) {
+	ctx, done := repo.start(ctx, "GetFeatureFlagMembers")
+	defer done()
+
+	query := `
+	SELECT workspace_id, feature_flag, user_id, role, unix_time FROM feature_flag_member
+	WHERE workspace_id=$1 AND feature_flag=$2 ORDER BY role DESC, unix_time;
+	`
 
-		schedules = append(schedules, schedule)
+	return queryAll(ctx,
+		repo.conn(),
+		scanFeatureFlagMember,
+		query,
+		workspaceId,
+		featureFlag,
+	)
+}
+
+// SetFeatureFlagMember gives the user the role on the feature flag. the user
+// also joins the workspace of the feature flag as a viewer if not a member,
+// so that the workspace can be switched to. that grants no access to the
+// other feature flags of the workspace.
+func (repo *PostgresRepository) SetFeatureFlagMember(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+	userId int,
+	role entities.Role,
+) error {
+	ctx, done := repo.start(ctx, "SetFeatureFlagMember")
+	defer done()
+
+	tx, err := repo.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	query := `
+	INSERT INTO feature_flag_member(workspace_id, feature_flag, user_id, role, unix_time)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (workspace_id, feature_flag, user_id) DO UPDATE SET role = EXCLUDED.role;
+	`
+	_, err = tx.ExecContext(ctx, query, workspaceId, featureFlag, userId, role, now)
+	if err != nil {
+		return conflict(err, nil, ErrFlagNotFound)
+	}
+
+	query = `
+	INSERT INTO workspace_member(workspace_id, user_id, role, unix_time)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT DO NOTHING;
+	`
+	_, err = tx.ExecContext(ctx, query, workspaceId, userId, entities.ViewerRole, now)
+	if err != nil {
+		return err
 	}
 
-	return schedules, nil
+	return tx.Commit()
 }
 
-func (repo *PostgresRepository) RemoveFeatureFlag(featureFlag string) error {
+func (repo *PostgresRepository) RemoveFeatureFlagMember(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+	userId int,
+) error {
+	ctx, done := repo.start(ctx, "RemoveFeatureFlagMember")
+	defer done()
+
 	query := `
-	DELETE FROM feature_flag WHERE feature_flag=$1;
+	DELETE FROM feature_flag_member
+	WHERE workspace_id=$1 AND feature_flag=$2 AND user_id=$3;
 	`
-	_, err := repo.DB.Exec(query, featureFlag)
+	_, err := repo.conn().ExecContext(ctx, query, workspaceId, featureFlag, userId)
 	return err
 }
 
-func (repo *PostgresRepository) Init() error {
-	err := repo.CreateTableFeatureFlag()
+// TransferFeatureFlagOwnership makes newOwnerId the owner of the feature flag.
+// the previous owner stays an editor of it.
+func (repo *PostgresRepository) TransferFeatureFlagOwnership(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+	newOwnerId int,
+) error {
+	ctx, done := repo.start(ctx, "TransferFeatureFlagOwnership")
+	defer done()
+
+	tx, err := repo.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE feature_flag_member SET role=$4
+	WHERE workspace_id=$1 AND feature_flag=$2 AND role=$3;
+	`
+	_, err = tx.ExecContext(ctx,
+		query,
+		workspaceId,
+		featureFlag,
+		entities.OwnerRole,
+		entities.EditorRole,
+	)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	query = `
+	INSERT INTO feature_flag_member(workspace_id, feature_flag, user_id, role, unix_time)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (workspace_id, feature_flag, user_id) DO UPDATE SET role = EXCLUDED.role;
+	`
+	_, err = tx.ExecContext(ctx,
+		query,
+		workspaceId,
+		featureFlag,
+		newOwnerId,
+		entities.OwnerRole,
+		now,
+	)
+	if err != nil {
+		return conflict(err, nil, ErrFlagNotFound)
+	}
+
+	query = `
+	INSERT INTO workspace_member(workspace_id, user_id, role, unix_time)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT DO NOTHING;
+	`
+	_, err = tx.ExecContext(ctx, query, workspaceId, newOwnerId, entities.ViewerRole, now)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	UPDATE feature_flag SET owner_id=$3 WHERE workspace_id=$1 AND feature_flag=$2;
+	`
+	_, err = tx.ExecContext(ctx, query, workspaceId, featureFlag, newOwnerId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (repo *PostgresRepository) UpsertBotUser(
+	ctx context.Context,
+	bot string,
+	user entities.User,
+) error {
+	ctx, done := repo.start(ctx, "UpsertBotUser")
+	defer done()
+
+	var userName string
+	if user.UserName != nil {
+		userName = *user.UserName
+	}
+
+	tx, err := repo.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the username moved to user, so whoever had it before lost it.
+	query := `
+	UPDATE bot_user SET username = ''
+	WHERE LOWER(username) = LOWER($1) AND username <> '' AND user_id <> $2;
+	`
+	_, err = tx.ExecContext(ctx, query, userName, user.Id)
+	if err != nil {
+		return err
+	}
 
+	now := time.Now().Unix()
+	query = `
+	INSERT INTO bot_user(user_id, username, first_name, unix_time)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (user_id) DO UPDATE
+	SET username = EXCLUDED.username, first_name = EXCLUDED.first_name;
+	`
+	_, err = tx.ExecContext(ctx,
+		query,
+		user.Id,
+		userName,
+		user.FirstName,
+		now,
+	)
 	if err != nil {
 		return err
 	}
 
-	err = repo.CreateTableSchedule()
+	query = `
+	INSERT INTO user_bot(bot, user_id, unix_time)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING;
+	`
+	_, err = tx.ExecContext(ctx, query, bot, user.Id, now)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return tx.Commit()
+}
+
+func (repo *PostgresRepository) GetBotUserByUserName(
+	ctx context.Context,
+	userName string,
+) (
+	*entities.BotUser,
+	error,
+) {
+	ctx, done := repo.start(ctx, "GetBotUserByUserName")
+	defer done()
+
+	query := `
+	SELECT user_id, username, first_name, unix_time FROM bot_user
+	WHERE LOWER(username) = LOWER($1);
+	`
+
+	var user entities.BotUser
+	err := repo.conn().QueryRowContext(ctx, query, userName).Scan(
+		&user.UserId,
+		&user.UserName,
+		&user.FirstName,
+		&user.UnixTime,
+	)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	return &user, nil
 }
@@ -0,0 +1,1067 @@
+// Package repositorytest is the conformance suite of repository.Repository.
+// every implementation is expected to pass it, so that the bot behaves the
+// same whichever database it runs on.
+package repositorytest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/repository"
+)
+
+// Run runs the suite. newRepository must return an empty repository that is
+// already initialized.
+func Run(t *testing.T, newRepository func(t *testing.T) repository.Repository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo repository.Repository)
+	}{
+		{"FeatureFlags", testFeatureFlags},
+		{"FeatureFlagMembers", testFeatureFlagMembers},
+		{"TransferFeatureFlagOwnership", testTransferFeatureFlagOwnership},
+		{"Workspaces", testWorkspaces},
+		{"BotUsers", testBotUsers},
+		{"Schedules", testSchedules},
+		{"ScheduleByTime", testScheduleByTime},
+		{"ProcessedUpdates", testProcessedUpdates},
+		{"WithTx", testWithTx},
+		{"Trash", testTrash},
+		{"AuditLogs", testAuditLogs},
+		{"ScheduleApproval", testScheduleApproval},
+		{"Admins", testAdmins},
+		{"AdminReports", testAdminReports},
+		{"InviteCodes", testInviteCodes},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, newRepository(t))
+		})
+	}
+}
+
+const (
+	owner  = 100
+	member = 200
+	other  = 300
+)
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantRole(
+	t *testing.T,
+	repo repository.Repository,
+	featureFlag string,
+	userId int,
+	want entities.Role,
+) {
+	t.Helper()
+	ctx := t.Context()
+	role, err := repo.GetFeatureFlagRole(ctx, repository.DefaultWorkspaceId, featureFlag, userId)
+	must(t, err)
+	if role != want {
+		t.Errorf("role of %d on %s = %d, want %d", userId, featureFlag, role, want)
+	}
+}
+
+func newSchedule(featureFlag string, calendar entities.CalendarTime) entities.Schedule {
+	return entities.Schedule{
+		WorkspaceId:     repository.DefaultWorkspaceId,
+		FeatureFlagName: featureFlag,
+		Value:           "on",
+		UsersList:       "all",
+		Calendar:        calendar,
+	}
+}
+
+func testFeatureFlags(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	workspaceId := repository.DefaultWorkspaceId
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "b"))
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "a"))
+
+	err := repo.AddFeatureFlag(ctx, workspaceId, other, "a")
+	if !errors.Is(err, repository.ErrFlagExists) {
+		t.Errorf("adding a duplicate feature flag returned %v, want ErrFlagExists", err)
+	}
+
+	featureFlag, err := repo.GetFeatureFlagByName(ctx, workspaceId, "a")
+	must(t, err)
+	if featureFlag.Name != "a" || featureFlag.OwnerId != owner ||
+		featureFlag.WorkspaceId != workspaceId || featureFlag.Paused {
+		t.Errorf("got feature flag %+v", featureFlag)
+	}
+
+	_, err = repo.GetFeatureFlagByName(ctx, workspaceId, "missing")
+	if !errors.Is(err, repository.ErrFlagNotFound) {
+		t.Errorf("getting a missing feature flag returned %v, want ErrFlagNotFound", err)
+	}
+
+	featureFlags, err := repo.GetFeatureFlagsByOwnerId(ctx, owner)
+	must(t, err)
+	if len(featureFlags) != 2 || featureFlags[0].Name == featureFlags[1].Name {
+		t.Errorf("got feature flags of the owner %+v", featureFlags)
+	}
+	featureFlags, err = repo.GetFeatureFlagsByOwnerId(ctx, other)
+	must(t, err)
+	if len(featureFlags) != 0 {
+		t.Errorf("got feature flags of a stranger %+v", featureFlags)
+	}
+
+	must(t, repo.SetFeatureFlagPaused(ctx, workspaceId, "a", true))
+	featureFlag, err = repo.GetFeatureFlagByName(ctx, workspaceId, "a")
+	must(t, err)
+	if !featureFlag.Paused {
+		t.Error("feature flag is not paused")
+	}
+
+	_, err = repo.AddSchedule(ctx, newSchedule("a", entities.CalendarTime{Day: 1}))
+	must(t, err)
+	must(t, repo.SetFeatureFlagMember(ctx, workspaceId, "a", member, entities.EditorRole))
+	must(t, repo.RemoveFeatureFlag(ctx, workspaceId, "a"))
+
+	_, err = repo.GetFeatureFlagByName(ctx, workspaceId, "a")
+	if !errors.Is(err, repository.ErrFlagNotFound) {
+		t.Errorf("getting a removed feature flag returned %v, want ErrFlagNotFound", err)
+	}
+	schedules, err := repo.GetSchedulesByFeatureFlag(ctx, workspaceId, "a")
+	must(t, err)
+	if len(schedules) != 0 {
+		t.Errorf("schedules of a removed feature flag are visible: %+v", schedules)
+	}
+
+	_, err = repo.PurgeFeatureFlagsDeletedBefore(ctx, time.Now().Add(time.Second))
+	must(t, err)
+	members, err := repo.GetFeatureFlagMembers(ctx, workspaceId, "a")
+	must(t, err)
+	if len(members) != 0 {
+		t.Errorf("members of a purged feature flag are kept: %+v", members)
+	}
+}
+
+func testFeatureFlagMembers(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	workspaceId := repository.DefaultWorkspaceId
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "a"))
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "b"))
+
+	wantRole(t, repo, "a", owner, entities.OwnerRole)
+	wantRole(t, repo, "a", member, 0)
+	wantRole(t, repo, "missing", owner, 0)
+
+	must(t, repo.SetFeatureFlagMember(ctx, workspaceId, "a", member, entities.ViewerRole))
+	must(t, repo.SetFeatureFlagMember(ctx, workspaceId, "a", member, entities.EditorRole))
+	wantRole(t, repo, "a", member, entities.EditorRole)
+
+	err := repo.SetFeatureFlagMember(ctx, workspaceId, "missing", member, entities.ViewerRole)
+	if !errors.Is(err, repository.ErrFlagNotFound) {
+		t.Errorf("sharing a missing feature flag returned %v, want ErrFlagNotFound", err)
+	}
+
+	// joining a feature flag makes the user a viewer of the workspace, but
+	// gives no access to its other feature flags.
+	role, err := repo.GetWorkspaceRole(ctx, workspaceId, member)
+	must(t, err)
+	if role != entities.ViewerRole {
+		t.Errorf("workspace role of the member = %d, want viewer", role)
+	}
+	wantRole(t, repo, "b", member, 0)
+
+	// neither does editing the workspace, which the users of feature flags
+	// created before workspaces existed do in the default workspace.
+	must(t, repo.SetWorkspaceMember(ctx, workspaceId, other, entities.EditorRole))
+	wantRole(t, repo, "a", other, 0)
+	featureFlags, err := repo.GetFeatureFlagsByUserId(ctx, workspaceId, other, entities.ViewerRole)
+	must(t, err)
+	if len(featureFlags) != 0 {
+		t.Errorf("an editor of the workspace got feature flags %+v", featureFlags)
+	}
+
+	featureFlags, err = repo.GetFeatureFlagsByUserId(ctx, workspaceId, member, entities.ViewerRole)
+	must(t, err)
+	if len(featureFlags) != 1 || featureFlags[0].Name != "a" {
+		t.Errorf("got viewable feature flags %+v, want a", featureFlags)
+	}
+	featureFlags, err = repo.GetFeatureFlagsByUserId(ctx, workspaceId, member, entities.EditorRole)
+	must(t, err)
+	if len(featureFlags) != 1 || featureFlags[0].Name != "a" {
+		t.Errorf("got editable feature flags %+v, want a", featureFlags)
+	}
+
+	members, err := repo.GetFeatureFlagMembers(ctx, workspaceId, "a")
+	must(t, err)
+	if len(members) != 2 || members[0].UserId != owner || members[1].UserId != member {
+		t.Errorf("got members %+v, want the owner then the member", members)
+	}
+
+	must(t, repo.RemoveFeatureFlagMember(ctx, workspaceId, "a", member))
+	wantRole(t, repo, "a", member, 0)
+
+	must(t, repo.SetFeatureFlagMember(ctx, workspaceId, "a", member, entities.EditorRole))
+	must(t, repo.RemoveWorkspaceMember(ctx, workspaceId, member))
+	wantRole(t, repo, "a", member, 0)
+	wantRole(t, repo, "b", member, 0)
+}
+
+func testTransferFeatureFlagOwnership(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	workspaceId := repository.DefaultWorkspaceId
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "a"))
+	must(t, repo.TransferFeatureFlagOwnership(ctx, workspaceId, "a", member))
+
+	wantRole(t, repo, "a", owner, entities.EditorRole)
+	wantRole(t, repo, "a", member, entities.OwnerRole)
+
+	featureFlag, err := repo.GetFeatureFlagByName(ctx, workspaceId, "a")
+	must(t, err)
+	if featureFlag.OwnerId != member {
+		t.Errorf("owner of the feature flag = %d, want %d", featureFlag.OwnerId, member)
+	}
+
+	role, err := repo.GetWorkspaceRole(ctx, workspaceId, member)
+	must(t, err)
+	if role == 0 {
+		t.Error("the new owner is not a member of the workspace")
+	}
+
+	err = repo.TransferFeatureFlagOwnership(ctx, workspaceId, "missing", member)
+	if !errors.Is(err, repository.ErrFlagNotFound) {
+		t.Errorf("transferring a missing feature flag returned %v, want ErrFlagNotFound", err)
+	}
+}
+
+func testWorkspaces(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	workspace, err := repo.GetWorkspaceById(ctx, repository.DefaultWorkspaceId)
+	must(t, err)
+	if workspace.WorkspaceId != repository.DefaultWorkspaceId {
+		t.Errorf("got default workspace %+v", workspace)
+	}
+
+	workspaceId, err := repo.CreateWorkspace(ctx, "team", owner)
+	must(t, err)
+	if workspaceId == repository.DefaultWorkspaceId {
+		t.Error("a new workspace got the id of the default workspace")
+	}
+
+	_, err = repo.CreateWorkspace(ctx, "team", other)
+	if !errors.Is(err, repository.ErrWorkspaceExists) {
+		t.Errorf("creating a workspace with a duplicate name returned %v, want ErrWorkspaceExists", err)
+	}
+
+	_, err = repo.GetWorkspaceById(ctx, workspaceId+100)
+	if !errors.Is(err, repository.ErrWorkspaceNotFound) {
+		t.Errorf("getting a missing workspace returned %v, want ErrWorkspaceNotFound", err)
+	}
+	err = repo.SetWorkspaceMember(ctx, workspaceId+100, member, entities.ViewerRole)
+	if !errors.Is(err, repository.ErrWorkspaceNotFound) {
+		t.Errorf("joining a missing workspace returned %v, want ErrWorkspaceNotFound", err)
+	}
+
+	role, err := repo.GetWorkspaceRole(ctx, workspaceId, owner)
+	must(t, err)
+	if role != entities.OwnerRole {
+		t.Errorf("role of the creator = %d, want owner", role)
+	}
+	role, err = repo.GetWorkspaceRole(ctx, workspaceId, other)
+	must(t, err)
+	if role != 0 {
+		t.Errorf("role of a stranger = %d, want 0", role)
+	}
+
+	must(t, repo.SetWorkspaceMember(ctx, workspaceId, member, entities.ViewerRole))
+	must(t, repo.SetWorkspaceMember(ctx, workspaceId, member, entities.EditorRole))
+	members, err := repo.GetWorkspaceMembers(ctx, workspaceId)
+	must(t, err)
+	if len(members) != 2 || members[0].UserId != owner ||
+		members[1].UserId != member || members[1].Role != entities.EditorRole {
+		t.Errorf("got workspace members %+v", members)
+	}
+
+	must(t, repo.SetWorkspaceMember(ctx, repository.DefaultWorkspaceId, member, entities.ViewerRole))
+	workspaces, err := repo.GetWorkspacesByUserId(ctx, member)
+	must(t, err)
+	if len(workspaces) != 2 ||
+		workspaces[0].WorkspaceId != repository.DefaultWorkspaceId ||
+		workspaces[1].WorkspaceId != workspaceId {
+		t.Errorf("got workspaces of the member %+v", workspaces)
+	}
+
+	currentWorkspaceId, err := repo.GetCurrentWorkspaceId(ctx, member)
+	must(t, err)
+	if currentWorkspaceId != 0 {
+		t.Errorf("current workspace of a new user = %d, want 0", currentWorkspaceId)
+	}
+	must(t, repo.SetCurrentWorkspaceId(ctx, member, workspaceId))
+	currentWorkspaceId, err = repo.GetCurrentWorkspaceId(ctx, member)
+	must(t, err)
+	if currentWorkspaceId != workspaceId {
+		t.Errorf("current workspace = %d, want %d", currentWorkspaceId, workspaceId)
+	}
+
+	must(t, repo.RemoveWorkspaceMember(ctx, workspaceId, member))
+	role, err = repo.GetWorkspaceRole(ctx, workspaceId, member)
+	must(t, err)
+	if role != 0 {
+		t.Errorf("role of a removed member = %d, want 0", role)
+	}
+}
+
+func testBotUsers(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	userName := "Alice"
+	must(t, repo.UpsertBotUser(ctx, "", entities.User{Id: member, FirstName: "alice", UserName: &userName}))
+
+	user, err := repo.GetBotUserByUserName(ctx, "alice")
+	must(t, err)
+	if user.UserId != member || user.FirstName != "alice" {
+		t.Errorf("got bot user %+v", user)
+	}
+
+	userName = "bob"
+	must(t, repo.UpsertBotUser(ctx, "", entities.User{Id: member, FirstName: "bob", UserName: &userName}))
+	_, err = repo.GetBotUserByUserName(ctx, "alice")
+	if !errors.Is(err, repository.ErrUserNotFound) {
+		t.Errorf("getting a renamed user returned %v, want ErrUserNotFound", err)
+	}
+	user, err = repo.GetBotUserByUserName(ctx, "BOB")
+	must(t, err)
+	if user.UserId != member {
+		t.Errorf("got bot user %+v", user)
+	}
+
+	// switching workspaces keeps the user.
+	must(t, repo.SetCurrentWorkspaceId(ctx, member, repository.DefaultWorkspaceId))
+	user, err = repo.GetBotUserByUserName(ctx, "bob")
+	must(t, err)
+	if user.UserId != member {
+		t.Errorf("got bot user %+v", user)
+	}
+
+	// a username taken by another user belongs to the new one only.
+	userName = "Bob"
+	must(t, repo.UpsertBotUser(ctx, "", entities.User{Id: other, FirstName: "bob", UserName: &userName}))
+	user, err = repo.GetBotUserByUserName(ctx, "bob")
+	must(t, err)
+	if user.UserId != other {
+		t.Errorf("got bot user %+v, want the new owner of the username", user)
+	}
+
+	// users without a username do not clash.
+	must(t, repo.UpsertBotUser(ctx, "", entities.User{Id: owner, FirstName: "owner"}))
+	must(t, repo.UpsertBotUser(ctx, "", entities.User{Id: member, FirstName: "member"}))
+}
+
+func testSchedules(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	workspaceId := repository.DefaultWorkspaceId
+	_, err := repo.AddSchedule(ctx, newSchedule("missing", entities.CalendarTime{Day: 1}))
+	if !errors.Is(err, repository.ErrFlagNotFound) {
+		t.Errorf("adding a schedule to a missing feature flag returned %v, want ErrFlagNotFound", err)
+	}
+
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "a"))
+	calendar := entities.CalendarTime{
+		Type:   entities.GeorgianCalendarType,
+		Year:   2030,
+		Month:  2,
+		Day:    3,
+		Hour:   4,
+		Minute: 5,
+	}
+	firstId, err := repo.AddSchedule(ctx, newSchedule("a", calendar))
+	must(t, err)
+	secondId, err := repo.AddSchedule(ctx, newSchedule("a", calendar))
+	must(t, err)
+	if firstId == secondId {
+		t.Errorf("both schedules got id %d", firstId)
+	}
+
+	schedule, err := repo.GetScheduleById(ctx, firstId)
+	must(t, err)
+	if schedule.ScheduleId != firstId || schedule.Calendar != calendar ||
+		schedule.Value != "on" || schedule.UsersList != "all" || schedule.Paused {
+		t.Errorf("got schedule %+v", schedule)
+	}
+
+	schedules, err := repo.GetSchedulesByFeatureFlag(ctx, workspaceId, "a")
+	must(t, err)
+	if len(schedules) != 2 || schedules[0].ScheduleId != firstId ||
+		schedules[1].ScheduleId != secondId {
+		t.Errorf("got schedules %+v", schedules)
+	}
+
+	must(t, repo.SetSchedulePaused(ctx, firstId, true))
+	schedule, err = repo.GetScheduleById(ctx, firstId)
+	must(t, err)
+	if !schedule.Paused {
+		t.Error("schedule is not paused")
+	}
+
+	must(t, repo.RemoveSchedule(ctx, firstId))
+	_, err = repo.GetScheduleById(ctx, firstId)
+	if !errors.Is(err, repository.ErrScheduleNotFound) {
+		t.Errorf("getting a removed schedule returned %v, want ErrScheduleNotFound", err)
+	}
+}
+
+func testScheduleByTime(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	workspaceId := repository.DefaultWorkspaceId
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "a"))
+
+	add := func(calendar entities.CalendarTime) int {
+		t.Helper()
+		scheduleId, err := repo.AddSchedule(ctx, newSchedule("a", calendar))
+		must(t, err)
+		return scheduleId
+	}
+	every := add(entities.CalendarTime{Type: entities.GeorgianCalendarType, Day: 10, Hour: 12, Minute: 30})
+	thisYear := add(entities.CalendarTime{Type: entities.GeorgianCalendarType, Year: 2030, Month: 5, Day: 10, Hour: 13})
+	add(entities.CalendarTime{Type: entities.GeorgianCalendarType, Year: 2031, Day: 10, Hour: 12, Minute: 30})
+	add(entities.CalendarTime{Type: entities.GeorgianCalendarType, Month: 6, Day: 10, Hour: 12, Minute: 30})
+	add(entities.CalendarTime{Type: entities.GeorgianCalendarType, Day: 11, Hour: 12, Minute: 30})
+	add(entities.CalendarTime{Type: entities.KhorshidiCalendarType, Day: 10, Hour: 12, Minute: 30})
+	add(entities.CalendarTime{Type: entities.GeorgianCalendarType, Day: 10, Hour: 12, Minute: 29})
+	add(entities.CalendarTime{Type: entities.GeorgianCalendarType, Day: 10, Hour: 13, Minute: 1})
+
+	scheduleIds := func() []int {
+		t.Helper()
+		schedules, err := repo.GetScheduleByTime(ctx,
+			entities.GeorgianCalendarType,
+			2030,
+			5,
+			10,
+			entities.CalendarTime{Hour: 12, Minute: 30},
+			entities.CalendarTime{Hour: 13, Minute: 0},
+		)
+		must(t, err)
+
+		var ids []int
+		for _, schedule := range schedules {
+			ids = append(ids, schedule.ScheduleId)
+		}
+		return ids
+	}
+	wantIds := func(want ...int) {
+		t.Helper()
+		got := scheduleIds()
+		if len(got) != len(want) {
+			t.Errorf("got schedules %v, want %v", got, want)
+			return
+		}
+		found := map[int]bool{}
+		for _, id := range got {
+			found[id] = true
+		}
+		for _, id := range want {
+			if !found[id] {
+				t.Errorf("got schedules %v, want %v", got, want)
+				return
+			}
+		}
+	}
+	wantActive := func(scheduleId int, want bool) {
+		t.Helper()
+		active, err := repo.IsScheduleActive(ctx, scheduleId)
+		must(t, err)
+		if active != want {
+			t.Errorf("schedule %d active = %v, want %v", scheduleId, active, want)
+		}
+	}
+
+	wantIds(every, thisYear)
+	wantActive(every, true)
+
+	must(t, repo.SetSchedulePaused(ctx, every, true))
+	wantIds(thisYear)
+	wantActive(every, false)
+	must(t, repo.SetSchedulePaused(ctx, every, false))
+
+	must(t, repo.SetFeatureFlagPaused(ctx, workspaceId, "a", true))
+	wantIds()
+	wantActive(thisYear, false)
+	must(t, repo.SetFeatureFlagPaused(ctx, workspaceId, "a", false))
+
+	paused, err := repo.IsSchedulingPaused(ctx)
+	must(t, err)
+	if paused {
+		t.Error("scheduling is paused in a new repository")
+	}
+	must(t, repo.SetSchedulingPaused(ctx, true))
+	paused, err = repo.IsSchedulingPaused(ctx)
+	must(t, err)
+	if !paused {
+		t.Error("scheduling is not paused")
+	}
+	wantIds()
+	wantActive(every, false)
+
+	must(t, repo.SetSchedulingPaused(ctx, false))
+	wantIds(every, thisYear)
+	wantActive(every, true)
+	wantActive(every+1000, false)
+}
+
+func testProcessedUpdates(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	for _, bot := range []string{"", "telegram"} {
+		lastId, err := repo.GetLastProcessedUpdateId(ctx, bot)
+		must(t, err)
+		if lastId != 0 {
+			t.Errorf("last processed update of %q = %d, want 0", bot, lastId)
+		}
+
+		for _, updateId := range []int{1, 2, 3} {
+			marked, err := repo.MarkUpdateProcessed(ctx, bot, updateId)
+			must(t, err)
+			if !marked {
+				t.Errorf("update %d of %q was processed before", updateId, bot)
+			}
+		}
+		marked, err := repo.MarkUpdateProcessed(ctx, bot, 2)
+		must(t, err)
+		if marked {
+			t.Errorf("update 2 of %q was marked twice", bot)
+		}
+	}
+
+	must(t, repo.DeleteProcessedUpdatesBefore(ctx, time.Now().Add(time.Hour)))
+	for _, bot := range []string{"", "telegram"} {
+		lastId, err := repo.GetLastProcessedUpdateId(ctx, bot)
+		must(t, err)
+		if lastId != 3 {
+			t.Errorf("last processed update of %q = %d, want 3", bot, lastId)
+		}
+
+		marked, err := repo.MarkUpdateProcessed(ctx, bot, 3)
+		must(t, err)
+		if marked {
+			t.Errorf("the last update of %q was deleted", bot)
+		}
+		marked, err = repo.MarkUpdateProcessed(ctx, bot, 1)
+		must(t, err)
+		if !marked {
+			t.Errorf("an old update of %q was not deleted", bot)
+		}
+	}
+}
+
+func testWithTx(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	workspaceId := repository.DefaultWorkspaceId
+	var scheduleId int
+	err := repo.WithTx(ctx, func(tx repository.Repository) error {
+		err := tx.AddFeatureFlag(ctx, workspaceId, owner, "a")
+		if err != nil {
+			return err
+		}
+
+		// a nested transaction joins the outer one.
+		return tx.WithTx(ctx, func(tx repository.Repository) error {
+			var err error
+			scheduleId, err = tx.AddSchedule(ctx, newSchedule("a", entities.CalendarTime{Day: 1}))
+			return err
+		})
+	})
+	must(t, err)
+	_, err = repo.GetFeatureFlagByName(ctx, workspaceId, "a")
+	must(t, err)
+	_, err = repo.GetScheduleById(ctx, scheduleId)
+	must(t, err)
+	wantRole(t, repo, "a", owner, entities.OwnerRole)
+
+	failed := errors.New("failed")
+	err = repo.WithTx(ctx, func(tx repository.Repository) error {
+		err := tx.AddFeatureFlag(ctx, workspaceId, owner, "b")
+		if err != nil {
+			return err
+		}
+		_, err = tx.AddSchedule(ctx, newSchedule("b", entities.CalendarTime{Day: 1}))
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("WithTx returned %v, want the error of the function", err)
+	}
+	_, err = repo.GetFeatureFlagByName(ctx, workspaceId, "b")
+	if !errors.Is(err, repository.ErrFlagNotFound) {
+		t.Errorf("getting a rolled back feature flag returned %v, want ErrFlagNotFound", err)
+	}
+	wantRole(t, repo, "b", owner, 0)
+
+	// what is changed outside a transaction while it runs is kept when it is
+	// rolled back.
+	written := make(chan error, 1)
+	err = repo.WithTx(ctx, func(tx repository.Repository) error {
+		must(t, tx.AddFeatureFlag(ctx, workspaceId, owner, "c"))
+		go func() {
+			written <- repo.AddFeatureFlag(ctx, workspaceId, other, "d")
+		}()
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("WithTx returned %v, want the error of the function", err)
+	}
+	must(t, <-written)
+	_, err = repo.GetFeatureFlagByName(ctx, workspaceId, "d")
+	if err != nil {
+		t.Errorf("getting a feature flag added during a rolled back transaction returned %v", err)
+	}
+	_, err = repo.GetFeatureFlagByName(ctx, workspaceId, "c")
+	if !errors.Is(err, repository.ErrFlagNotFound) {
+		t.Errorf("getting a rolled back feature flag returned %v, want ErrFlagNotFound", err)
+	}
+
+	err = repo.WithTx(ctx, func(tx repository.Repository) error {
+		return tx.AddFeatureFlag(ctx, workspaceId, other, "a")
+	})
+	if !errors.Is(err, repository.ErrFlagExists) {
+		t.Errorf("adding a duplicate feature flag in a transaction returned %v, want ErrFlagExists", err)
+	}
+	featureFlag, err := repo.GetFeatureFlagByName(ctx, workspaceId, "a")
+	must(t, err)
+	if featureFlag.OwnerId != owner {
+		t.Errorf("owner of the feature flag = %d, want %d", featureFlag.OwnerId, owner)
+	}
+}
+
+func testTrash(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	workspaceId := repository.DefaultWorkspaceId
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "a"))
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "b"))
+	must(t, repo.SetFeatureFlagMember(ctx, workspaceId, "a", member, entities.EditorRole))
+	scheduleId, err := repo.AddSchedule(ctx, newSchedule("a", entities.CalendarTime{Day: 1}))
+	must(t, err)
+	must(t, repo.RemoveFeatureFlag(ctx, workspaceId, "a"))
+
+	wantRole(t, repo, "a", owner, 0)
+	_, err = repo.GetScheduleById(ctx, scheduleId)
+	if !errors.Is(err, repository.ErrScheduleNotFound) {
+		t.Errorf("getting a schedule of a deleted feature flag returned %v, want ErrScheduleNotFound", err)
+	}
+	active, err := repo.IsScheduleActive(ctx, scheduleId)
+	must(t, err)
+	if active {
+		t.Error("a schedule of a deleted feature flag is active")
+	}
+	featureFlags, err := repo.GetFeatureFlagsByUserId(ctx, workspaceId, owner, entities.ViewerRole)
+	must(t, err)
+	if len(featureFlags) != 1 || featureFlags[0].Name != "b" {
+		t.Errorf("got feature flags %+v, want b", featureFlags)
+	}
+
+	err = repo.AddFeatureFlag(ctx, workspaceId, other, "a")
+	if !errors.Is(err, repository.ErrFlagDeleted) {
+		t.Errorf("adding a deleted feature flag returned %v, want ErrFlagDeleted", err)
+	}
+	_, err = repo.AddSchedule(ctx, newSchedule("a", entities.CalendarTime{Day: 1}))
+	if !errors.Is(err, repository.ErrFlagNotFound) {
+		t.Errorf("adding a schedule to a deleted feature flag returned %v, want ErrFlagNotFound", err)
+	}
+
+	deleted, err := repo.GetDeletedFeatureFlagsByUserId(ctx, workspaceId, owner, entities.OwnerRole)
+	must(t, err)
+	if len(deleted) != 1 || deleted[0].Name != "a" || deleted[0].DeletedAt == 0 {
+		t.Errorf("got deleted feature flags %+v, want a", deleted)
+	}
+	deleted, err = repo.GetDeletedFeatureFlagsByUserId(ctx, workspaceId, member, entities.OwnerRole)
+	must(t, err)
+	if len(deleted) != 0 {
+		t.Errorf("got deleted feature flags of an editor %+v", deleted)
+	}
+
+	must(t, repo.RestoreFeatureFlag(ctx, workspaceId, "a"))
+	err = repo.RestoreFeatureFlag(ctx, workspaceId, "a")
+	if !errors.Is(err, repository.ErrFlagNotFound) {
+		t.Errorf("restoring a feature flag twice returned %v, want ErrFlagNotFound", err)
+	}
+	featureFlag, err := repo.GetFeatureFlagByName(ctx, workspaceId, "a")
+	must(t, err)
+	if featureFlag.DeletedAt != 0 {
+		t.Errorf("got restored feature flag %+v", featureFlag)
+	}
+	wantRole(t, repo, "a", member, entities.EditorRole)
+	active, err = repo.IsScheduleActive(ctx, scheduleId)
+	must(t, err)
+	if !active {
+		t.Error("a schedule of a restored feature flag is not active")
+	}
+
+	must(t, repo.RemoveFeatureFlag(ctx, workspaceId, "a"))
+	purged, err := repo.PurgeFeatureFlagsDeletedBefore(ctx, time.Now().Add(-time.Hour))
+	must(t, err)
+	if len(purged) != 0 {
+		t.Errorf("purged recently deleted feature flags %+v", purged)
+	}
+	deleted, err = repo.GetDeletedFeatureFlagsByUserId(ctx, workspaceId, owner, entities.OwnerRole)
+	must(t, err)
+	if len(deleted) != 1 {
+		t.Errorf("a recently deleted feature flag is purged: %+v", deleted)
+	}
+
+	purged, err = repo.PurgeFeatureFlagsDeletedBefore(ctx, time.Now().Add(time.Second))
+	must(t, err)
+	if len(purged) != 1 || purged[0].Name != "a" || purged[0].WorkspaceId != workspaceId ||
+		purged[0].OwnerId != owner || purged[0].DeletedAt == 0 {
+		t.Errorf("got purged feature flags %+v, want a", purged)
+	}
+	deleted, err = repo.GetDeletedFeatureFlagsByUserId(ctx, workspaceId, owner, entities.OwnerRole)
+	must(t, err)
+	if len(deleted) != 0 {
+		t.Errorf("got purged feature flags %+v", deleted)
+	}
+	err = repo.RestoreFeatureFlag(ctx, workspaceId, "a")
+	if !errors.Is(err, repository.ErrFlagNotFound) {
+		t.Errorf("restoring a purged feature flag returned %v, want ErrFlagNotFound", err)
+	}
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, other, "a"))
+	schedules, err := repo.GetSchedulesByFeatureFlag(ctx, workspaceId, "a")
+	must(t, err)
+	if len(schedules) != 0 {
+		t.Errorf("schedules of a purged feature flag are kept: %+v", schedules)
+	}
+	featureFlag, err = repo.GetFeatureFlagByName(ctx, workspaceId, "b")
+	must(t, err)
+	if featureFlag.Name != "b" {
+		t.Errorf("got feature flag %+v", featureFlag)
+	}
+}
+
+func testAuditLogs(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	workspaceId := repository.DefaultWorkspaceId
+	created := entities.AuditLog{
+		ActorId:         owner,
+		WorkspaceId:     workspaceId,
+		FeatureFlagName: "a",
+		Entity:          entities.FeatureFlagAuditEntity,
+		Action:          entities.CreateAuditAction,
+		After:           `{"Name":"a"}`,
+	}
+	must(t, repo.AddAuditLog(ctx, created))
+	must(t, repo.AddAuditLog(ctx, entities.AuditLog{
+		ActorId:         member,
+		WorkspaceId:     workspaceId,
+		FeatureFlagName: "a",
+		Entity:          entities.FeatureFlagAuditEntity,
+		Action:          entities.UpdateAuditAction,
+		Before:          `{"Paused":false}`,
+		After:           `{"Paused":true}`,
+	}))
+	must(t, repo.AddAuditLog(ctx, entities.AuditLog{
+		ActorId:     owner,
+		WorkspaceId: workspaceId,
+		Entity:      entities.WorkspaceMemberAuditEntity,
+		Action:      entities.DeleteAuditAction,
+		Before:      `{"UserId":300}`,
+	}))
+
+	// rolled back changes leave no audit log behind.
+	failed := errors.New("failed")
+	err := repo.WithTx(ctx, func(tx repository.Repository) error {
+		must(t, tx.AddAuditLog(ctx, created))
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("WithTx returned %v, want the error of the function", err)
+	}
+
+	// a feature flag of another workspace with the same name.
+	must(t, repo.AddAuditLog(ctx, entities.AuditLog{
+		ActorId:         other,
+		WorkspaceId:     workspaceId + 1,
+		FeatureFlagName: "a",
+		Entity:          entities.FeatureFlagAuditEntity,
+		Action:          entities.CreateAuditAction,
+	}))
+
+	auditLogs, err := repo.GetAuditLogsByFeatureFlag(ctx, workspaceId, "a", 10)
+	must(t, err)
+	if len(auditLogs) != 2 ||
+		auditLogs[0].Action != entities.UpdateAuditAction ||
+		auditLogs[1].Action != entities.CreateAuditAction {
+		t.Fatalf("got audit logs of a %+v, want update and create", auditLogs)
+	}
+	got := auditLogs[1]
+	if got.AuditId == 0 || got.UnixTime == 0 {
+		t.Errorf("got audit log %+v without an id or time", got)
+	}
+	got.AuditId, got.UnixTime = 0, 0
+	if got != created {
+		t.Errorf("got audit log %+v, want %+v", got, created)
+	}
+
+	auditLogs, err = repo.GetAuditLogsByActorId(ctx, owner, 1)
+	must(t, err)
+	if len(auditLogs) != 1 || auditLogs[0].Entity != entities.WorkspaceMemberAuditEntity {
+		t.Errorf("got last audit log of the owner %+v, want the workspace member", auditLogs)
+	}
+	auditLogs, err = repo.GetAuditLogsByActorId(ctx, member, 10)
+	must(t, err)
+	if len(auditLogs) != 1 || auditLogs[0].Action != entities.UpdateAuditAction {
+		t.Errorf("got audit logs of the member %+v, want the update", auditLogs)
+	}
+	auditLogs, err = repo.GetAuditLogsByActorId(ctx, owner+1, 10)
+	must(t, err)
+	if len(auditLogs) != 0 {
+		t.Errorf("got audit logs of a user who changed nothing %+v", auditLogs)
+	}
+}
+
+func testScheduleApproval(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	workspaceId := repository.DefaultWorkspaceId
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "a"))
+	calendar := entities.CalendarTime{
+		Type: entities.GeorgianCalendarType,
+		Day:  3,
+		Hour: 4,
+	}
+	getScheduleIds := func() []int {
+		t.Helper()
+		schedules, err := repo.GetScheduleByTime(
+			ctx,
+			entities.GeorgianCalendarType,
+			2030,
+			2,
+			3,
+			entities.CalendarTime{Hour: 0, Minute: 0},
+			entities.CalendarTime{Hour: 23, Minute: 59},
+		)
+		must(t, err)
+		var ids []int
+		for _, schedule := range schedules {
+			ids = append(ids, schedule.ScheduleId)
+		}
+		return ids
+	}
+
+	approvedId, err := repo.AddSchedule(ctx, newSchedule("a", calendar))
+	must(t, err)
+	pending := newSchedule("a", calendar)
+	pending.Approval = entities.PendingApproval
+	pending.CreatorId = member
+	pendingId, err := repo.AddSchedule(ctx, pending)
+	must(t, err)
+	rejectedId, err := repo.AddSchedule(ctx, pending)
+	must(t, err)
+
+	schedule, err := repo.GetScheduleById(ctx, approvedId)
+	must(t, err)
+	if schedule.Approval != entities.ApprovedApproval {
+		t.Errorf("a schedule added without an approval has approval %d", schedule.Approval)
+	}
+	schedule, err = repo.GetScheduleById(ctx, pendingId)
+	must(t, err)
+	if schedule.Approval != entities.PendingApproval || schedule.CreatorId != member {
+		t.Errorf("got pending schedule %+v", schedule)
+	}
+	if ids := getScheduleIds(); len(ids) != 1 || ids[0] != approvedId {
+		t.Errorf("got schedules %v by time, want only the approved %d", ids, approvedId)
+	}
+	active, err := repo.IsScheduleActive(ctx, pendingId)
+	must(t, err)
+	if active {
+		t.Error("a pending schedule is active")
+	}
+
+	must(t, repo.ReviewSchedule(ctx, pendingId, entities.ApprovedApproval))
+	must(t, repo.ReviewSchedule(ctx, rejectedId, entities.RejectedApproval))
+	err = repo.ReviewSchedule(ctx, rejectedId, entities.ApprovedApproval)
+	if !errors.Is(err, repository.ErrScheduleReviewed) {
+		t.Errorf("approving a rejected schedule returned %v, want ErrScheduleReviewed", err)
+	}
+	err = repo.ReviewSchedule(ctx, rejectedId+100, entities.ApprovedApproval)
+	if !errors.Is(err, repository.ErrScheduleNotFound) {
+		t.Errorf("approving a missing schedule returned %v, want ErrScheduleNotFound", err)
+	}
+
+	if ids := getScheduleIds(); len(ids) != 2 || ids[0] == rejectedId || ids[1] == rejectedId {
+		t.Errorf("got schedules %v by time, want %d and %d", ids, approvedId, pendingId)
+	}
+	active, err = repo.IsScheduleActive(ctx, pendingId)
+	must(t, err)
+	if !active {
+		t.Error("an approved schedule is not active")
+	}
+	active, err = repo.IsScheduleActive(ctx, rejectedId)
+	must(t, err)
+	if active {
+		t.Error("a rejected schedule is active")
+	}
+}
+
+func testAdmins(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	must(t, repo.AddAdmin(ctx, member))
+	must(t, repo.AddAdmin(ctx, owner))
+	must(t, repo.AddAdmin(ctx, owner))
+
+	admin, err := repo.IsAdmin(ctx, owner)
+	must(t, err)
+	if !admin {
+		t.Error("an added admin is not an admin")
+	}
+	admins, err := repo.GetAdmins(ctx)
+	must(t, err)
+	if len(admins) != 2 || admins[0].UserId != owner || admins[1].UserId != member ||
+		admins[0].UnixTime == 0 {
+		t.Errorf("got admins %+v, want %d and %d", admins, owner, member)
+	}
+
+	must(t, repo.RemoveAdmin(ctx, owner))
+	admin, err = repo.IsAdmin(ctx, owner)
+	must(t, err)
+	if admin {
+		t.Error("a removed admin is still an admin")
+	}
+	admin, err = repo.IsAdmin(ctx, other)
+	must(t, err)
+	if admin {
+		t.Error("a user who was never added is an admin")
+	}
+}
+
+func testAdminReports(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	workspaceId := repository.DefaultWorkspaceId
+	otherWorkspaceId, err := repo.CreateWorkspace(ctx, "other", other)
+	must(t, err)
+	must(t, repo.AddFeatureFlag(ctx, otherWorkspaceId, other, "a"))
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "b"))
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "a"))
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, owner, "deleted"))
+	must(t, repo.SetFeatureFlagMember(ctx, workspaceId, "a", member, entities.EditorRole))
+	_, err = repo.AddSchedule(ctx, newSchedule("a", entities.CalendarTime{Day: 1}))
+	must(t, err)
+	pending := newSchedule("b", entities.CalendarTime{Day: 1})
+	pending.Approval = entities.PendingApproval
+	_, err = repo.AddSchedule(ctx, pending)
+	must(t, err)
+	_, err = repo.AddSchedule(ctx, newSchedule("deleted", entities.CalendarTime{Day: 1}))
+	must(t, err)
+	must(t, repo.RemoveFeatureFlag(ctx, workspaceId, "deleted"))
+	must(t, repo.UpsertBotUser(ctx, "", entities.User{Id: member, FirstName: "member"}))
+	must(t, repo.UpsertBotUser(ctx, "", entities.User{Id: owner, FirstName: "owner"}))
+	must(t, repo.UpsertBotUser(ctx, "telegram", entities.User{Id: other, FirstName: "other"}))
+
+	featureFlags, err := repo.GetAllFeatureFlags(ctx)
+	must(t, err)
+	var names []string
+	for _, featureFlag := range featureFlags {
+		names = append(names, fmt.Sprintf("%d/%s", featureFlag.WorkspaceId, featureFlag.Name))
+	}
+	want := []string{
+		fmt.Sprintf("%d/a", workspaceId),
+		fmt.Sprintf("%d/b", workspaceId),
+		fmt.Sprintf("%d/a", otherWorkspaceId),
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("got all feature flags %v, want %v", names, want)
+	}
+
+	stats, err := repo.GetStats(ctx)
+	must(t, err)
+	wantStats := entities.Stats{
+		FeatureFlags:        3,
+		DeletedFeatureFlags: 1,
+		Schedules:           2,
+		PendingSchedules:    1,
+		Workspaces:          2,
+		BotUsers:            3,
+	}
+	if stats != wantStats {
+		t.Errorf("got stats %+v, want %+v", stats, wantStats)
+	}
+
+	userIds, err := repo.GetBotUserIds(ctx, "")
+	must(t, err)
+	if !slices.Equal(userIds, []int{owner, member}) {
+		t.Errorf("got bot user ids %v, want %d and %d", userIds, owner, member)
+	}
+	userIds, err = repo.GetBotUserIds(ctx, "telegram")
+	must(t, err)
+	if !slices.Equal(userIds, []int{other}) {
+		t.Errorf("got bot user ids %v of the other bot, want %d", userIds, other)
+	}
+
+	must(t, repo.PurgeFeatureFlag(ctx, workspaceId, "a"))
+	must(t, repo.PurgeFeatureFlag(ctx, workspaceId, "deleted"))
+	err = repo.PurgeFeatureFlag(ctx, workspaceId, "a")
+	if !errors.Is(err, repository.ErrFlagNotFound) {
+		t.Errorf("purging a missing feature flag returned %v, want ErrFlagNotFound", err)
+	}
+	wantRole(t, repo, "a", member, 0)
+	must(t, repo.AddFeatureFlag(ctx, workspaceId, other, "deleted"))
+	stats, err = repo.GetStats(ctx)
+	must(t, err)
+	if stats.FeatureFlags != 3 || stats.DeletedFeatureFlags != 0 || stats.Schedules != 1 {
+		t.Errorf("got stats %+v after purging", stats)
+	}
+}
+
+func testInviteCodes(t *testing.T, repo repository.Repository) {
+	ctx := t.Context()
+	must(t, repo.AddInviteCode(ctx, "first", owner))
+	must(t, repo.AddInviteCode(ctx, "second", owner))
+	err := repo.AddInviteCode(ctx, "first", other)
+	if !errors.Is(err, repository.ErrInviteCodeExists) {
+		t.Errorf("adding a taken invite code returned %v, want ErrInviteCodeExists", err)
+	}
+
+	allowed, err := repo.IsAllowedUser(ctx, member)
+	must(t, err)
+	if allowed {
+		t.Error("a user is allowed before redeeming an invite code")
+	}
+
+	must(t, repo.RedeemInviteCode(ctx, "first", member))
+	err = repo.RedeemInviteCode(ctx, "first", other)
+	if !errors.Is(err, repository.ErrInviteCodeInvalid) {
+		t.Errorf("redeeming a used invite code returned %v, want ErrInviteCodeInvalid", err)
+	}
+	err = repo.RedeemInviteCode(ctx, "missing", other)
+	if !errors.Is(err, repository.ErrInviteCodeInvalid) {
+		t.Errorf("redeeming a missing invite code returned %v, want ErrInviteCodeInvalid", err)
+	}
+	must(t, repo.RedeemInviteCode(ctx, "second", member))
+
+	allowed, err = repo.IsAllowedUser(ctx, member)
+	must(t, err)
+	if !allowed {
+		t.Error("a user who redeemed an invite code is not allowed")
+	}
+	allowed, err = repo.IsAllowedUser(ctx, other)
+	must(t, err)
+	if allowed {
+		t.Error("a user whose invite codes failed is allowed")
+	}
+
+	allowedUsers, err := repo.GetAllowedUsers(ctx)
+	must(t, err)
+	if len(allowedUsers) != 1 || allowedUsers[0].UserId != member ||
+		allowedUsers[0].InviteCode != "first" || allowedUsers[0].UnixTime == 0 {
+		t.Errorf("got allowed users %+v, want %d let in by first", allowedUsers, member)
+	}
+
+	must(t, repo.RemoveAllowedUser(ctx, member))
+	allowed, err = repo.IsAllowedUser(ctx, member)
+	must(t, err)
+	if allowed {
+		t.Error("a removed user is still allowed")
+	}
+}
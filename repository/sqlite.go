@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SqliteRepository keeps everything in an embedded sqlite database for
+// single node installs. sqlite understands the queries of PostgresRepository,
+// so only the migrations are its own.
+type SqliteRepository struct {
+	PostgresRepository
+}
+
+func NewSqliteRepository(db *sql.DB, timeout time.Duration) *SqliteRepository {
+	if timeout <= 0 {
+		timeout = DefaultQueryTimeout
+	}
+
+	return &SqliteRepository{PostgresRepository{DB: db, Timeout: timeout}}
+}
+
+// OpenSqlite opens the sqlite database at path with foreign keys enforced.
+// sqlite has a single writer, so the pool is limited to one connection and
+// transactions take the write lock when they begin.
+func OpenSqlite(path string) (*sql.DB, error) {
+	db, err := sql.Open(
+		"sqlite",
+		fmt.Sprintf(
+			"file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate",
+			path,
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// Init migrates the database to the latest version of the schema.
+func (repo *SqliteRepository) Init(ctx context.Context) error {
+	_, err := NewMigrator(repo.DB, SqliteDriver).Up(ctx)
+	return err
+}
+
+// WithTx runs fn in a transaction, like PostgresRepository.WithTx.
+func (repo *SqliteRepository) WithTx(
+	ctx context.Context,
+	fn func(repo Repository) error,
+) error {
+	return repo.withTx(ctx, func(txRepo *PostgresRepository) error {
+		return fn(&SqliteRepository{*txRepo})
+	})
+}
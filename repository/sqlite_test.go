@@ -0,0 +1,26 @@
+package repository_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/fatemehkarimi/chronos_bot/repository"
+	"github.com/fatemehkarimi/chronos_bot/repository/repositorytest"
+)
+
+func TestSqliteRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		db, err := repository.OpenSqlite(filepath.Join(t.TempDir(), "chronos.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		repo := repository.NewSqliteRepository(db, 0)
+		err = repo.Init(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+// DefaultTrashRetention is how long a deleted feature flag can be restored
+// before it is purged.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// GetDeletedFeatureFlagsByUserId returns the deleted feature flags of the
+// workspace on which the user has at least minRole, most recently deleted
+// first. roles are those the user had when the feature flag was deleted.
+func (repo *PostgresRepository) GetDeletedFeatureFlagsByUserId(
+	ctx context.Context,
+	workspaceId int,
+	userId int,
+	minRole entities.Role,
+) ([]entities.FeatureFlag, error) {
+	ctx, done := repo.start(ctx, "GetDeletedFeatureFlagsByUserId")
+	defer done()
+
+	query := `
+	SELECT f.workspace_id, f.feature_flag, f.owner_id, f.paused, f.unix_time, f.deleted_at
+	FROM feature_flag f
+	JOIN feature_flag_member m
+		ON m.workspace_id = f.workspace_id AND m.feature_flag = f.feature_flag AND m.user_id = $2
+	WHERE f.workspace_id = $1 AND f.deleted_at IS NOT NULL AND m.role >= $3
+	ORDER BY f.deleted_at DESC, f.feature_flag;
+	`
+
+	return queryAll(ctx,
+		repo.conn(),
+		scanFeatureFlag,
+		query,
+		workspaceId,
+		userId,
+		minRole,
+	)
+}
+
+// RestoreFeatureFlag moves the feature flag out of the trash along with its
+// schedules and members. ErrFlagNotFound is returned if it is not in the
+// trash.
+func (repo *PostgresRepository) RestoreFeatureFlag(
+	ctx context.Context,
+	workspaceId int,
+	featureFlag string,
+) error {
+	ctx, done := repo.start(ctx, "RestoreFeatureFlag")
+	defer done()
+
+	query := `
+	UPDATE feature_flag SET deleted_at=NULL
+	WHERE workspace_id=$1 AND feature_flag=$2 AND deleted_at IS NOT NULL;
+	`
+	result, err := repo.conn().ExecContext(ctx, query, workspaceId, featureFlag)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrFlagNotFound
+	}
+	return nil
+}
+
+// PurgeFeatureFlagsDeletedBefore deletes the feature flags that were moved to
+// the trash before t, together with their schedules and members, and returns
+// the deleted feature flags.
+func (repo *PostgresRepository) PurgeFeatureFlagsDeletedBefore(
+	ctx context.Context,
+	t time.Time,
+) ([]entities.FeatureFlag, error) {
+	ctx, done := repo.start(ctx, "PurgeFeatureFlagsDeletedBefore")
+	defer done()
+
+	query := `
+	DELETE FROM feature_flag WHERE deleted_at < $1
+	RETURNING workspace_id, feature_flag, owner_id, paused, unix_time, COALESCE(deleted_at, 0);
+	`
+	return queryAll(ctx, repo.conn(), scanFeatureFlag, query, t.Unix())
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// WithTx runs fn in a transaction. a repository that is already in a
+// transaction runs fn in it, so the outermost WithTx commits.
+func (repo *PostgresRepository) WithTx(
+	ctx context.Context,
+	fn func(repo Repository) error,
+) error {
+	return repo.withTx(ctx, func(txRepo *PostgresRepository) error {
+		return fn(txRepo)
+	})
+}
+
+func (repo *PostgresRepository) withTx(
+	ctx context.Context,
+	fn func(txRepo *PostgresRepository) error,
+) error {
+	if repo.tx != nil {
+		return fn(repo)
+	}
+
+	ctx, done := repo.start(ctx, "WithTx")
+	defer done()
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(&PostgresRepository{DB: repo.DB, Timeout: repo.Timeout, tx: tx})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// conn returns the transaction of the repository, or the database if it is
+// not in one.
+func (repo *PostgresRepository) conn() querier {
+	if repo.tx != nil {
+		return repo.tx
+	}
+	return repo.DB
+}
+
+// txn is a transaction of a single repository method. it is a no-op wrapper
+// of the transaction of WithTx when the repository is in one, so the method
+// neither commits nor rolls back the enclosing transaction.
+type txn struct {
+	*sql.Tx
+	joined bool
+}
+
+func (tx txn) Commit() error {
+	if tx.joined {
+		return nil
+	}
+	return tx.Tx.Commit()
+}
+
+func (tx txn) Rollback() error {
+	if tx.joined {
+		return nil
+	}
+	return tx.Tx.Rollback()
+}
+
+// begin starts the transaction of a repository method.
+func (repo *PostgresRepository) begin(ctx context.Context) (txn, error) {
+	if repo.tx != nil {
+		return txn{Tx: repo.tx, joined: true}, nil
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	return txn{Tx: tx}, err
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// MarkUpdateProcessed records the update of bot as processed and reports
+// whether it was not recorded before. concurrent calls for the same update,
+// from this process or another replica, report true for exactly one of
+// them. update ids are only unique per bot.
+func (repo *PostgresRepository) MarkUpdateProcessed(
+	ctx context.Context,
+	bot string,
+	updateId int,
+) (bool, error) {
+	ctx, done := repo.start(ctx, "MarkUpdateProcessed")
+	defer done()
+
+	query := `
+	INSERT INTO processed_update(bot, update_id, unix_time)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (bot, update_id) DO NOTHING`
+	result, err := repo.conn().ExecContext(ctx, query, bot, updateId, time.Now().Unix())
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// GetLastProcessedUpdateId returns the largest processed update id of bot,
+// or 0 if no update is processed yet.
+func (repo *PostgresRepository) GetLastProcessedUpdateId(
+	ctx context.Context,
+	bot string,
+) (int, error) {
+	ctx, done := repo.start(ctx, "GetLastProcessedUpdateId")
+	defer done()
+
+	query := `SELECT COALESCE(MAX(update_id), 0) FROM processed_update WHERE bot = $1`
+	var updateId int
+	err := repo.conn().QueryRowContext(ctx, query, bot).Scan(&updateId)
+	return updateId, err
+}
+
+// DeleteProcessedUpdatesBefore removes the records of updates processed
+// before t. bale does not redeliver updates that old, so they are not needed
+// for deduplication anymore. the last processed update of every bot is
+// always kept.
+func (repo *PostgresRepository) DeleteProcessedUpdatesBefore(
+	ctx context.Context,
+	t time.Time,
+) error {
+	ctx, done := repo.start(ctx, "DeleteProcessedUpdatesBefore")
+	defer done()
+
+	query := `
+	DELETE FROM processed_update AS p
+	WHERE unix_time < $1
+	AND update_id < (
+		SELECT MAX(update_id) FROM processed_update WHERE bot = p.bot
+	)`
+	_, err := repo.conn().ExecContext(ctx, query, t.Unix())
+	return err
+}
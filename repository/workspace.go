@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+// DefaultWorkspaceId is the workspace that feature flags created before
+// workspaces existed are migrated into.
+const DefaultWorkspaceId = 1
+
+// CreateWorkspace creates a workspace whose owner is its first member.
+func (repo *PostgresRepository) CreateWorkspace(
+	ctx context.Context,
+	name string,
+	ownerId int,
+) (int, error) {
+	ctx, done := repo.start(ctx, "CreateWorkspace")
+	defer done()
+
+	tx, err := repo.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	query := `
+	INSERT INTO workspace(name, owner_id, unix_time) VALUES ($1, $2, $3)
+	RETURNING workspace_id;
+	`
+	var workspaceId int
+	err = tx.QueryRowContext(ctx, query, name, ownerId, now).Scan(&workspaceId)
+	if err != nil {
+		return 0, conflict(err, ErrWorkspaceExists, nil)
+	}
+
+	query = `
+	INSERT INTO workspace_member(workspace_id, user_id, role, unix_time)
+	VALUES ($1, $2, $3, $4);
+	`
+	_, err = tx.ExecContext(ctx, query, workspaceId, ownerId, entities.OwnerRole, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return workspaceId, tx.Commit()
+}
+
+func (repo *PostgresRepository) GetWorkspaceById(
+	ctx context.Context,
+	workspaceId int,
+) (
+	*entities.Workspace,
+	error,
+) {
+	ctx, done := repo.start(ctx, "GetWorkspaceById")
+	defer done()
+
+	query := `
+	SELECT workspace_id, name, owner_id, unix_time FROM workspace
+	WHERE workspace_id=$1;
+	`
+
+	workspace, err := scanWorkspace(repo.conn().QueryRowContext(ctx, query, workspaceId))
+	if err != nil {
+		return nil, notFound(err, ErrWorkspaceNotFound)
+	}
+
+	return &workspace, nil
+}
+
+func (repo *PostgresRepository) GetWorkspacesByUserId(
+	ctx context.Context,
+	userId int,
+) (
+	[]entities.Workspace,
+	error,
+) {
+	ctx, done := repo.start(ctx, "GetWorkspacesByUserId")
+	defer done()
+
+	query := `
+	SELECT w.workspace_id, w.name, w.owner_id, w.unix_time
+	FROM workspace w
+	JOIN workspace_member m ON m.workspace_id = w.workspace_id
+	WHERE m.user_id = $1
+	ORDER BY w.workspace_id;
+	`
+
+	return queryAll(ctx, repo.conn(), scanWorkspace, query, userId)
+}
+
+// GetWorkspaceRole returns the role of the user in the workspace, or zero if
+// the user is not a member of it.
+func (repo *PostgresRepository) GetWorkspaceRole(
+	ctx context.Context,
+	workspaceId int,
+	userId int,
+) (entities.Role, error) {
+	ctx, done := repo.start(ctx, "GetWorkspaceRole")
+	defer done()
+
+	query := `
+	SELECT role FROM workspace_member WHERE workspace_id=$1 AND user_id=$2;
+	`
+
+	var role entities.Role
+	err := repo.conn().QueryRowContext(ctx, query, workspaceId, userId).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return role, nil
+}
+
+func (repo *PostgresRepository) GetWorkspaceMembers(
+	ctx context.Context,
+	workspaceId int,
+) (
+	[]entities.WorkspaceMember,
+	error,
+) {
+	ctx, done := repo.start(ctx, "GetWorkspaceMembers")
+	defer done()
+
+	query := `
+	SELECT workspace_id, user_id, role, unix_time FROM workspace_member
+	WHERE workspace_id=$1 ORDER BY role DESC, unix_time;
+	`
+
+	return queryAll(ctx, repo.conn(), scanWorkspaceMember, query, workspaceId)
+}
+
+func (repo *PostgresRepository) SetWorkspaceMember(
+	ctx context.Context,
+	workspaceId int,
+	userId int,
+	role entities.Role,
+) error {
+	ctx, done := repo.start(ctx, "SetWorkspaceMember")
+	defer done()
+
+	query := `
+	INSERT INTO workspace_member(workspace_id, user_id, role, unix_time)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role;
+	`
+	_, err := repo.conn().ExecContext(ctx, query, workspaceId, userId, role, time.Now().Unix())
+	return conflict(err, nil, ErrWorkspaceNotFound)
+}
+
+// RemoveWorkspaceMember removes the user from the workspace and from the
+// access lists of its feature flags.
+func (repo *PostgresRepository) RemoveWorkspaceMember(
+	ctx context.Context,
+	workspaceId int,
+	userId int,
+) error {
+	ctx, done := repo.start(ctx, "RemoveWorkspaceMember")
+	defer done()
+
+	tx, err := repo.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	DELETE FROM feature_flag_member WHERE workspace_id=$1 AND user_id=$2;
+	`
+	_, err = tx.ExecContext(ctx, query, workspaceId, userId)
+	if err != nil {
+		return err
+	}
+
+	query = `DELETE FROM workspace_member WHERE workspace_id=$1 AND user_id=$2;`
+	_, err = tx.ExecContext(ctx, query, workspaceId, userId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetCurrentWorkspaceId returns the workspace the user has switched to, or
+// zero if the user has never chosen one.
+func (repo *PostgresRepository) GetCurrentWorkspaceId(
+	ctx context.Context,
+	userId int,
+) (int, error) {
+	ctx, done := repo.start(ctx, "GetCurrentWorkspaceId")
+	defer done()
+
+	query := `SELECT COALESCE(workspace_id, 0) FROM bot_user WHERE user_id=$1;`
+
+	var workspaceId int
+	err := repo.conn().QueryRowContext(ctx, query, userId).Scan(&workspaceId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return workspaceId, nil
+}
+
+func (repo *PostgresRepository) SetCurrentWorkspaceId(
+	ctx context.Context,
+	userId int,
+	workspaceId int,
+) error {
+	ctx, done := repo.start(ctx, "SetCurrentWorkspaceId")
+	defer done()
+
+	query := `
+	INSERT INTO bot_user(user_id, workspace_id, unix_time) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id) DO UPDATE SET workspace_id = EXCLUDED.workspace_id;
+	`
+	_, err := repo.conn().ExecContext(ctx, query, userId, workspaceId, time.Now().Unix())
+	return err
+}
@@ -1,7 +1,11 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/fatemehkarimi/chronos_bot/api"
 	"github.com/fatemehkarimi/chronos_bot/entities"
@@ -16,23 +20,42 @@ type Scheduler interface {
 		endDayTime entities.CalendarTime,
 	)
 	OnNewSchedule(schedule entities.Schedule)
+	RelaunchToday()
+}
+
+// LogChannel is a chat that fired schedules are announced in.
+type LogChannel struct {
+	Api    api.Api
+	ChatId string
 }
 
 type DBScheduler struct {
-	repo       repository.Repository
-	api        api.Api
-	logChannel string
+	// ctx is cancelled on shutdown. schedules waiting to be fired are
+	// dropped then.
+	ctx         context.Context
+	repo        repository.Repository
+	logChannels []LogChannel
+
+	// pending holds the ids of schedules that are waiting to be fired today,
+	// so that a schedule is never launched twice.
+	mu      sync.Mutex
+	pending map[int]bool
 }
 
 func NewScheduler(
+	ctx context.Context,
 	DB repository.Repository,
-	api api.Api,
-	logChannel string,
+	logChannels []LogChannel,
 ) Scheduler {
-	return DBScheduler{DB, api, logChannel}
+	return &DBScheduler{
+		ctx:         ctx,
+		repo:        DB,
+		logChannels: logChannels,
+		pending:     map[int]bool{},
+	}
 }
 
-func (s DBScheduler) LaunchSchedulesInRange(
+func (s *DBScheduler) LaunchSchedulesInRange(
 	calendar entities.Calendar,
 	startDayTime entities.CalendarTime,
 	endDayTime entities.CalendarTime,
@@ -43,6 +66,7 @@ func (s DBScheduler) LaunchSchedulesInRange(
 	day := now.Day
 
 	schedules, err := s.repo.GetScheduleByTime(
+		s.ctx,
 		calendar.Type(),
 		year,
 		month,
@@ -63,42 +87,143 @@ func (s DBScheduler) LaunchSchedulesInRange(
 	}
 }
 
-func (s DBScheduler) OnNewSchedule(schedule entities.Schedule) {
+func (s *DBScheduler) OnNewSchedule(schedule entities.Schedule) {
 	calendar := utils.GetCalendarByType(schedule.Calendar.Type)
 	if utils.ShouldRunToday(calendar, schedule) {
 		go s.ScheduleAndNotify(schedule)
 	}
 }
 
-func (s DBScheduler) ScheduleAndNotify(schedule entities.Schedule) {
+// RelaunchToday launches the remaining schedules of today. it is used after
+// a feature flag or the whole scheduling is resumed; schedules that are
+// already pending are not launched again.
+func (s *DBScheduler) RelaunchToday() {
+	startTime, ok := NextMinuteOfToday(time.Now())
+	if !ok {
+		return
+	}
+	endTime := entities.CalendarTime{Hour: 23, Minute: 59}
+
+	calendars := []entities.Calendar{
+		entities.KhorshidiCalendar{},
+		entities.GeorgianCalendar{},
+		entities.QamariCalendar{},
+	}
+	for _, calendar := range calendars {
+		go s.LaunchSchedulesInRange(calendar, startTime, endTime)
+	}
+}
+
+// NextMinuteOfToday returns the minute after now, where relaunching the
+// schedules of today starts. the schedules of the current minute may have
+// fired already, so they are not launched again. false is returned if now
+// is the last minute of the day.
+func NextMinuteOfToday(now time.Time) (entities.CalendarTime, bool) {
+	next := now.Truncate(time.Minute).Add(time.Minute)
+	if next.Day() != now.Day() {
+		return entities.CalendarTime{}, false
+	}
+	return entities.CalendarTime{Hour: next.Hour(), Minute: next.Minute()}, true
+}
+
+// notifyTimeout bounds sending a schedule to the log channel, including the
+// retries of rate limited requests.
+const notifyTimeout = 5 * time.Minute
+
+func (s *DBScheduler) ScheduleAndNotify(schedule entities.Schedule) {
+	if !s.markPending(schedule.ScheduleId) {
+		slog.Debug(
+			"schedule is already pending",
+			slog.Int("scheduleId", schedule.ScheduleId),
+		)
+		return
+	}
+	defer s.unmarkPending(schedule.ScheduleId)
+
 	taskDayTime := entities.CalendarTime{
 		Hour:   schedule.Calendar.Hour,
 		Minute: schedule.Calendar.Minute,
 	}
 	task := func() error {
-		SetConfig(schedule)
-		result := s.api.SendMessage(
-			s.logChannel,
-			utils.ScheduleToText(schedule),
-			nil,
-		)
-
-		if result.Err != nil {
-			slog.Error(
-				"error sending schedule to log channel",
-				slog.Any("error", result.Err),
+		// the schedule, its feature flag or the whole scheduling may have
+		// been paused since the schedule was launched.
+		active, err := s.repo.IsScheduleActive(s.ctx, schedule.ScheduleId)
+		if err != nil {
+			return err
+		}
+		if !active {
+			slog.Info(
+				"skipping paused schedule",
 				slog.Any("schedule", schedule),
 			)
+			return nil
 		}
 
+		SetConfig(schedule)
+		for _, logChannel := range s.logChannels {
+			s.notify(logChannel, schedule)
+		}
 		return nil
 	}
-	err := utils.ScheduleTaskOnSameDay(taskDayTime, task)
-	if err != nil {
+	err := utils.ScheduleTaskOnSameDay(s.ctx, taskDayTime, task)
+	if errors.Is(err, context.Canceled) {
+		slog.Info(
+			"dropping pending schedule on shutdown",
+			slog.Int("scheduleId", schedule.ScheduleId),
+		)
+	} else if err != nil {
 		slog.Error("error scheduling task on same day", slog.Any("error", err))
 	}
 }
 
+func (s *DBScheduler) notify(
+	logChannel LogChannel,
+	schedule entities.Schedule,
+) {
+	ctx, cancel := context.WithTimeout(s.ctx, notifyTimeout)
+	defer cancel()
+	_, err := logChannel.Api.SendMessage(
+		ctx,
+		logChannel.ChatId,
+		utils.ScheduleToText(schedule),
+		nil,
+	)
+
+	if api.IsChatUnreachable(err) {
+		slog.Error(
+			"log channel is unreachable, check the log channel id and that the bot is a member of it",
+			slog.String("logChannel", logChannel.ChatId),
+			slog.Any("error", err),
+			slog.Any("schedule", schedule),
+		)
+	} else if err != nil {
+		slog.Error(
+			"error sending schedule to log channel",
+			slog.String("logChannel", logChannel.ChatId),
+			slog.Any("error", err),
+			slog.Any("schedule", schedule),
+		)
+	}
+}
+
+func (s *DBScheduler) markPending(scheduleId int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending[scheduleId] {
+		return false
+	}
+	s.pending[scheduleId] = true
+	return true
+}
+
+func (s *DBScheduler) unmarkPending(scheduleId int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pending, scheduleId)
+}
+
 // complete: this function calls the awx set config function
 func SetConfig(schedule entities.Schedule) {
 	slog.Debug("setting schedule", slog.Any("schedule", schedule))
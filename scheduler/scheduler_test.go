@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+func TestNextMinuteOfToday(t *testing.T) {
+	tests := []struct {
+		name   string
+		now    time.Time
+		want   entities.CalendarTime
+		wantOk bool
+	}{
+		{
+			"start of a minute",
+			time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC),
+			entities.CalendarTime{Hour: 10, Minute: 1},
+			true,
+		},
+		{
+			"end of a minute",
+			time.Date(2026, 5, 1, 10, 0, 59, 999, time.UTC),
+			entities.CalendarTime{Hour: 10, Minute: 1},
+			true,
+		},
+		{
+			"end of an hour",
+			time.Date(2026, 5, 1, 10, 59, 30, 0, time.UTC),
+			entities.CalendarTime{Hour: 11, Minute: 0},
+			true,
+		},
+		{
+			"last minute of the day",
+			time.Date(2026, 5, 1, 23, 59, 0, 0, time.UTC),
+			entities.CalendarTime{},
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := NextMinuteOfToday(test.now)
+			if got != test.want || ok != test.wantOk {
+				t.Fatalf("NextMinuteOfToday(%v) = %+v, %v, want %+v, %v", test.now, got, ok, test.want, test.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package state
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+type memoryEntry struct {
+	state     entities.UserState
+	updatedAt time.Time
+}
+
+// MemoryStateStore keeps states in memory. states are lost on restart.
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	states map[int]memoryEntry
+}
+
+func NewMemoryStateStore(ttl time.Duration) *MemoryStateStore {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &MemoryStateStore{
+		ttl:    ttl,
+		states: map[int]memoryEntry{},
+	}
+}
+
+func (s *MemoryStateStore) Get(
+	ctx context.Context,
+	chatId int,
+) (entities.UserState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.states[chatId]
+	if !ok {
+		return startState(), nil
+	}
+	if time.Since(entry.updatedAt) > s.ttl {
+		delete(s.states, chatId)
+		return startState(), nil
+	}
+	return entry.state, nil
+}
+
+func (s *MemoryStateStore) Set(
+	ctx context.Context,
+	chatId int,
+	state entities.UserState,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state.StateName == entities.StartState {
+		delete(s.states, chatId)
+		return nil
+	}
+
+	s.states[chatId] = memoryEntry{state: state, updatedAt: time.Now()}
+	return nil
+}
+
+// DeleteExpired removes states that have not been updated within the ttl.
+func (s *MemoryStateStore) DeleteExpired(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for chatId, entry := range s.states {
+		if time.Since(entry.updatedAt) > s.ttl {
+			delete(s.states, chatId)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package state_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/state"
+	"github.com/fatemehkarimi/chronos_bot/state/statetest"
+)
+
+func TestMemoryStateStore(t *testing.T) {
+	statetest.Run(t, func(t *testing.T) statetest.Backend {
+		// every bot has a store of its own in memory.
+		return statetest.Backend{
+			NewStore: func(bot string, ttl time.Duration) state.StateStore {
+				return state.NewMemoryStateStore(ttl)
+			},
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/repository"
+)
+
+// PostgresStateStore keeps states in the user_state table so a restart in
+// the middle of a conversation does not lose it. the states of different
+// bots are kept apart by Bot.
+type PostgresStateStore struct {
+	DB  *sql.DB
+	Bot string
+	TTL time.Duration
+	// Timeout bounds every query.
+	Timeout time.Duration
+}
+
+func NewPostgresStateStore(
+	db *sql.DB,
+	bot string,
+	ttl time.Duration,
+	timeout time.Duration,
+) *PostgresStateStore {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if timeout <= 0 {
+		timeout = repository.DefaultQueryTimeout
+	}
+
+	return &PostgresStateStore{DB: db, Bot: bot, TTL: ttl, Timeout: timeout}
+}
+
+func (s *PostgresStateStore) Get(
+	ctx context.Context,
+	chatId int,
+) (entities.UserState, error) {
+	ctx, cancel := withTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	var data []byte
+	var updatedAt time.Time
+	err := s.DB.QueryRowContext(
+		ctx,
+		"SELECT state, updated_at FROM user_state WHERE bot = $1 AND chat_id = $2",
+		s.Bot,
+		chatId,
+	).Scan(&data, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return startState(), nil
+	}
+	if err != nil {
+		return startState(), err
+	}
+
+	if time.Since(updatedAt) > s.TTL {
+		_, err = s.DB.ExecContext(
+			ctx,
+			"DELETE FROM user_state WHERE bot = $1 AND chat_id = $2",
+			s.Bot,
+			chatId,
+		)
+		return startState(), err
+	}
+
+	var userState entities.UserState
+	err = json.Unmarshal(data, &userState)
+	if err != nil {
+		return startState(), err
+	}
+	return userState, nil
+}
+
+func (s *PostgresStateStore) Set(
+	ctx context.Context,
+	chatId int,
+	state entities.UserState,
+) error {
+	ctx, cancel := withTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	if state.StateName == entities.StartState {
+		_, err := s.DB.ExecContext(
+			ctx,
+			"DELETE FROM user_state WHERE bot = $1 AND chat_id = $2",
+			s.Bot,
+			chatId,
+		)
+		return err
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.ExecContext(
+		ctx,
+		`INSERT INTO user_state (bot, chat_id, state, updated_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (bot, chat_id) DO UPDATE
+		SET state = EXCLUDED.state, updated_at = EXCLUDED.updated_at`,
+		s.Bot,
+		chatId,
+		data,
+	)
+	return err
+}
+
+// DeleteExpired removes states that have not been updated within the ttl.
+func (s *PostgresStateStore) DeleteExpired(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(
+		ctx,
+		"DELETE FROM user_state WHERE bot = $1 AND updated_at < $2",
+		s.Bot,
+		time.Now().Add(-s.TTL),
+	)
+	return err
+}
@@ -0,0 +1,61 @@
+package state_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/repository"
+	"github.com/fatemehkarimi/chronos_bot/state"
+	"github.com/fatemehkarimi/chronos_bot/state/statetest"
+	_ "github.com/lib/pq"
+)
+
+// postgresDSNEnv names the variable holding the key=value connection string
+// of the database the postgres tests run against. they are skipped if it is
+// not set.
+const postgresDSNEnv = "CHRONOS_TEST_POSTGRES_DSN"
+
+func TestPostgresStateStore(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+
+	statetest.Run(t, func(t *testing.T) statetest.Backend {
+		// every test gets an empty schema of its own.
+		schema := fmt.Sprintf("chronos_test_%d", time.Now().UnixNano())
+		admin, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { admin.Close() })
+		_, err = admin.ExecContext(t.Context(), "CREATE SCHEMA "+schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			admin.ExecContext(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		})
+
+		db, err := sql.Open("postgres", dsn+" search_path="+schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		err = repository.CreateNewRepository(db, 0).Init(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return statetest.Backend{
+			NewStore: func(bot string, ttl time.Duration) state.StateStore {
+				return state.NewPostgresStateStore(db, bot, ttl, 0)
+			},
+			Count: countStates(t, db),
+		}
+	})
+}
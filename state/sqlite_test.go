@@ -0,0 +1,45 @@
+package state_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/repository"
+	"github.com/fatemehkarimi/chronos_bot/state"
+	"github.com/fatemehkarimi/chronos_bot/state/statetest"
+)
+
+// countStates returns the number of rows of user_state in db.
+func countStates(t *testing.T, db *sql.DB) func() int {
+	return func() int {
+		var count int
+		err := db.QueryRowContext(t.Context(), "SELECT COUNT(*) FROM user_state").Scan(&count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+}
+
+func TestSqliteStateStore(t *testing.T) {
+	statetest.Run(t, func(t *testing.T) statetest.Backend {
+		db, err := repository.OpenSqlite(filepath.Join(t.TempDir(), "chronos.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		err = repository.NewSqliteRepository(db, 0).Init(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return statetest.Backend{
+			NewStore: func(bot string, ttl time.Duration) state.StateStore {
+				return state.NewSqliteStateStore(db, bot, ttl, 0)
+			},
+			Count: countStates(t, db),
+		}
+	})
+}
@@ -0,0 +1,158 @@
+// Package statetest is the conformance suite of state.StateStore. every
+// implementation is expected to pass it, so that conversations behave the
+// same whichever store keeps them.
+package statetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/state"
+)
+
+// Backend is the storage of the stores of a test.
+type Backend struct {
+	// NewStore returns a store of the states of bot.
+	NewStore func(bot string, ttl time.Duration) state.StateStore
+	// Count returns the number of states kept for all bots, expired or not.
+	// it may be nil if the backend can not tell.
+	Count func() int
+}
+
+// Run runs the suite. newBackend must return an empty backend.
+func Run(t *testing.T, newBackend func(t *testing.T) Backend) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, backend Backend)
+	}{
+		{"SetAndGet", testSetAndGet},
+		{"Keys", testKeys},
+		{"Expiry", testExpiry},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, newBackend(t))
+		})
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantState(
+	t *testing.T,
+	store state.StateStore,
+	chatId int,
+	want entities.UserState,
+) {
+	t.Helper()
+	got, err := store.Get(t.Context(), chatId)
+	must(t, err)
+	if got.StateName != want.StateName ||
+		got.WorkspaceId != want.WorkspaceId ||
+		got.FeatureFlagName != want.FeatureFlagName ||
+		got.Role != want.Role ||
+		got.NewFeatureFlag != want.NewFeatureFlag ||
+		(got.Schedule == nil) != (want.Schedule == nil) ||
+		got.Schedule != nil && *got.Schedule != *want.Schedule {
+		t.Errorf("state of chat %d = %+v, want %+v", chatId, got, want)
+	}
+}
+
+func wantCount(t *testing.T, backend Backend, want int) {
+	t.Helper()
+	if backend.Count == nil {
+		return
+	}
+	if got := backend.Count(); got != want {
+		t.Errorf("backend keeps %d states, want %d", got, want)
+	}
+}
+
+var (
+	start   = entities.UserState{StateName: entities.StartState}
+	sharing = entities.UserState{
+		StateName:       entities.GetWorkspaceNameState,
+		WorkspaceId:     3,
+		FeatureFlagName: "dark-mode",
+		Role:            entities.EditorRole,
+	}
+	scheduling = entities.UserState{
+		StateName: entities.GetWorkspaceNameState,
+		Schedule: &entities.Schedule{
+			WorkspaceId:     3,
+			FeatureFlagName: "dark-mode",
+			Value:           "on",
+			UsersList:       "all",
+			Calendar:        entities.CalendarTime{Day: 1, Hour: 10},
+		},
+		NewFeatureFlag: true,
+	}
+)
+
+func testSetAndGet(t *testing.T, backend Backend) {
+	ctx := t.Context()
+	store := backend.NewStore("bale", time.Hour)
+	wantState(t, store, 1, start)
+
+	must(t, store.Set(ctx, 1, sharing))
+	wantState(t, store, 1, sharing)
+	must(t, store.Set(ctx, 1, scheduling))
+	wantState(t, store, 1, scheduling)
+
+	// the start state is not kept.
+	must(t, store.Set(ctx, 1, start))
+	wantState(t, store, 1, start)
+	wantCount(t, backend, 0)
+}
+
+func testKeys(t *testing.T, backend Backend) {
+	ctx := t.Context()
+	bale := backend.NewStore("bale", time.Hour)
+	telegram := backend.NewStore("telegram", time.Hour)
+
+	must(t, bale.Set(ctx, 1, sharing))
+	wantState(t, telegram, 1, start)
+	wantState(t, bale, 2, start)
+
+	must(t, telegram.Set(ctx, 1, scheduling))
+	must(t, bale.Set(ctx, 2, scheduling))
+	wantState(t, bale, 1, sharing)
+	wantState(t, telegram, 1, scheduling)
+	wantState(t, bale, 2, scheduling)
+	wantCount(t, backend, 3)
+
+	must(t, bale.Set(ctx, 1, start))
+	wantState(t, bale, 1, start)
+	wantState(t, telegram, 1, scheduling)
+}
+
+func testExpiry(t *testing.T, backend Backend) {
+	ctx := t.Context()
+	store := backend.NewStore("bale", time.Second)
+	other := backend.NewStore("telegram", time.Hour)
+
+	must(t, store.Set(ctx, 1, sharing))
+	must(t, store.Set(ctx, 2, sharing))
+	must(t, other.Set(ctx, 1, sharing))
+	wantState(t, store, 1, sharing)
+
+	// stores may keep the time of a state in whole seconds.
+	time.Sleep(2100 * time.Millisecond)
+	must(t, store.Set(ctx, 3, scheduling))
+	wantState(t, store, 1, start)
+	wantCount(t, backend, 3)
+
+	must(t, store.DeleteExpired(ctx))
+	wantCount(t, backend, 2)
+	wantState(t, store, 2, start)
+	wantState(t, store, 3, scheduling)
+	// the states of a store with a longer ttl are kept.
+	wantState(t, other, 1, sharing)
+}
@@ -0,0 +1,36 @@
+package state
+
+import (
+	"context"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+// DefaultTTL is how long a conversation state is kept after its last update.
+const DefaultTTL = 24 * time.Hour
+
+// StateStore keeps the conversation state of users between updates. states
+// that are not updated for longer than the ttl of the store are expired and
+// Get returns the start state for them. DeleteExpired removes them from the
+// store.
+type StateStore interface {
+	Get(ctx context.Context, chatId int) (entities.UserState, error)
+	Set(ctx context.Context, chatId int, state entities.UserState) error
+	DeleteExpired(ctx context.Context) error
+}
+
+func startState() entities.UserState {
+	return entities.UserState{StateName: entities.StartState}
+}
+
+// withTimeout bounds ctx with the query timeout of a store.
+func withTimeout(
+	ctx context.Context,
+	timeout time.Duration,
+) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
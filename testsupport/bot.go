@@ -0,0 +1,88 @@
+package testsupport
+
+import (
+	"context"
+
+	"github.com/fatemehkarimi/chronos_bot/dispatcher"
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/handler"
+	"github.com/fatemehkarimi/chronos_bot/repository"
+	"github.com/fatemehkarimi/chronos_bot/scheduler"
+	"github.com/fatemehkarimi/chronos_bot/state"
+)
+
+// LogChannel is the chat the schedules of a Bot are announced in.
+const LogChannel = "-1000"
+
+// Bot is the bot wired to a fake bale server, an in-memory repository and
+// in-memory user states. a schedule added for the current minute of today
+// is fired right away, and shows up as a message in LogChannel.
+type Bot struct {
+	Bale      *FakeBale
+	Repo      *repository.MemoryRepository
+	States    *state.MemoryStateStore
+	Scheduler scheduler.Scheduler
+	Handler   handler.Handler
+}
+
+func NewBot(admins ...int) (*Bot, error) {
+	fake := NewFakeBale()
+	repo := repository.NewMemoryRepository()
+	err := repo.Init(context.Background())
+	if err != nil {
+		fake.Close()
+		return nil, err
+	}
+
+	botApi := fake.Api()
+	states := state.NewMemoryStateStore(state.DefaultTTL)
+	botScheduler := scheduler.NewScheduler(
+		context.Background(),
+		repo,
+		[]scheduler.LogChannel{{Api: botApi, ChatId: LogChannel}},
+	)
+	botHandler := handler.NewHttpHandler(
+		context.Background(),
+		"",
+		repo,
+		botApi,
+		botScheduler,
+		states,
+		dispatcher.Config{},
+		admins,
+		repository.DefaultTrashRetention,
+		handler.ApprovalConfig{},
+		handler.AccessConfig{},
+		LogChannel,
+	)
+
+	return &Bot{
+		Bale:      fake,
+		Repo:      repo,
+		States:    states,
+		Scheduler: botScheduler,
+		Handler:   botHandler,
+	}, nil
+}
+
+// Send processes the update and returns once the bot has answered it. use
+// Handler.ReceiveUpdate to go through deduplication and the dispatcher
+// instead.
+func (b *Bot) Send(update entities.Update) {
+	b.Handler.ProcessUpdate(context.Background(), update)
+}
+
+// SendText processes a text message of userId.
+func (b *Bot) SendText(userId int, text string) {
+	b.Send(b.Bale.TextMessage(userId, text))
+}
+
+// Press processes userId pressing an inline button with data.
+func (b *Bot) Press(userId int, data string) {
+	b.Send(b.Bale.CallbackQuery(userId, data))
+}
+
+func (b *Bot) Close() {
+	b.Handler.Stop()
+	b.Bale.Close()
+}
@@ -0,0 +1,259 @@
+package testsupport
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/handler"
+	"github.com/fatemehkarimi/chronos_bot/pkg/utils"
+)
+
+func hasButton(message SentMessage, data string) bool {
+	if message.ReplyMarkup == nil {
+		return false
+	}
+	for _, row := range message.ReplyMarkup.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData != nil && *button.CallbackData == data {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func lastMessage(t *testing.T, bot *Bot, chatId string) SentMessage {
+	t.Helper()
+	message, ok := bot.Bale.LastMessage(chatId)
+	if !ok {
+		t.Fatalf("no message was sent in chat %s", chatId)
+	}
+	return message
+}
+
+func TestScheduleIsAnnouncedInLogChannel(t *testing.T) {
+	bot, err := NewBot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Close()
+
+	const userId = 101
+	chatId := fmt.Sprint(userId)
+
+	bot.SendText(userId, "/start")
+	message := lastMessage(t, bot, chatId)
+	if !hasButton(message, utils.AddFeatureFlagCallbackData) {
+		t.Fatalf("start message has no add feature flag button: %+v", message)
+	}
+
+	bot.Press(userId, utils.AddFeatureFlagCallbackData)
+	if message := lastMessage(t, bot, chatId); message.Text != "نام پرچم(feature flag) را بنویسید." {
+		t.Fatalf("unexpected answer to add feature flag: %q", message.Text)
+	}
+
+	bot.SendText(userId, "dark-mode")
+	message = lastMessage(t, bot, chatId)
+	if !strings.HasPrefix(message.Text, "پرچم شما ثبت شد") {
+		t.Fatalf("feature flag was not added: %q", message.Text)
+	}
+	if !hasButton(message, utils.AddScheduleCallbackData) {
+		t.Fatalf("answer to the new feature flag has no add schedule button: %+v", message)
+	}
+
+	bot.Press(userId, utils.AddScheduleCallbackData)
+	featureFlagData, ok := lastMessage(t, bot, chatId).CallbackData("dark-mode")
+	if !ok {
+		t.Fatal("the new feature flag can not be chosen for a schedule")
+	}
+
+	bot.Press(userId, featureFlagData)
+	if !hasButton(lastMessage(t, bot, chatId), utils.GeorgianCalendarCallbackData) {
+		t.Fatal("calendar types were not offered")
+	}
+	bot.Press(userId, utils.GeorgianCalendarCallbackData)
+
+	// the schedule fires at the current minute, which must not pass before
+	// it is saved.
+	if second := time.Now().Second(); second >= 50 {
+		time.Sleep(time.Duration(61-second) * time.Second)
+	}
+	now := time.Now()
+	bot.SendText(
+		userId,
+		fmt.Sprintf("y:\nm:\nd: %d\nhh: %d\nmm: %d", now.Day(), now.Hour(), now.Minute()),
+	)
+	if !hasButton(lastMessage(t, bot, chatId), utils.ConfirmSchedulePatternCallbackData) {
+		t.Fatal("schedule pattern was not previewed")
+	}
+
+	bot.Press(userId, utils.ConfirmSchedulePatternCallbackData)
+	bot.SendText(userId, "enabled")
+	bot.Press(userId, utils.UsersListForAllCallbackData)
+	if message := lastMessage(t, bot, chatId); !strings.Contains(message.Text, "با موفقیت ذخیره شد") {
+		t.Fatalf("schedule was not saved: %q", message.Text)
+	}
+
+	messages, err := bot.Bale.WaitForMessages(LogChannel, 1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages in log channel, want 1", len(messages))
+	}
+	for _, want := range []string{"dark-mode", "enabled", "*"} {
+		if !strings.Contains(messages[0].Text, want) {
+			t.Errorf("log channel message %q does not contain %q", messages[0].Text, want)
+		}
+	}
+}
+
+func TestBroadcastRunsInTheBackground(t *testing.T) {
+	const admin = 1
+	bot, err := NewBot(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Close()
+
+	users := []int{101, 102, 103}
+	for _, userId := range users {
+		bot.SendText(userId, "/start")
+	}
+	// users of another bot are not messaged.
+	err = bot.Repo.UpsertBotUser(t.Context(), "telegram", entities.User{Id: 104})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bot.SendText(admin, "/broadcast hello")
+	for _, userId := range users {
+		_, err := bot.Bale.WaitForMessages(fmt.Sprint(userId), 2, 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	bot.Handler.Stop()
+
+	if messages := bot.Bale.Messages("104"); len(messages) != 0 {
+		t.Fatalf("a user of another bot got %d messages", len(messages))
+	}
+	// the admin talked to the bot too.
+	want := fmt.Sprintf("پیام به %d کاربر از %d کاربر ارسال شد.", len(users)+1, len(users)+1)
+	if message := lastMessage(t, bot, fmt.Sprint(admin)); message.Text != want {
+		t.Fatalf("got broadcast result %q, want %q", message.Text, want)
+	}
+}
+
+func TestRevokeListsRemainingFeatureFlags(t *testing.T) {
+	const admin, userId = 1, 101
+	bot, err := NewBot(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Close()
+
+	ctx := t.Context()
+	err = bot.Repo.AddInviteCode(ctx, "code", admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bot.Repo.RedeemInviteCode(ctx, "code", userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot.SendText(userId, "/start")
+	bot.Press(userId, utils.AddFeatureFlagCallbackData)
+	bot.SendText(userId, "dark-mode")
+
+	bot.SendText(admin, fmt.Sprintf("/revoke %d", userId))
+	message := lastMessage(t, bot, fmt.Sprint(admin))
+	if !strings.Contains(message.Text, "dark-mode") ||
+		!strings.Contains(message.Text, "/forcedelete") {
+		t.Fatalf("revoke answer does not mention the remaining feature flag: %q", message.Text)
+	}
+}
+
+func TestPersonalWorkspaceNamesAreReserved(t *testing.T) {
+	bot, err := NewBot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Close()
+
+	const userId, victimId = 101, 102
+
+	bot.SendText(userId, "/start")
+	bot.Press(userId, utils.CreateWorkspaceCallbackData)
+	bot.SendText(userId, fmt.Sprintf("personal-%d", victimId))
+	message := lastMessage(t, bot, fmt.Sprint(userId))
+	if !strings.Contains(message.Text, "نمی‌تواند") {
+		t.Fatalf("a personal workspace name was accepted: %q", message.Text)
+	}
+
+	// the personal workspace of the victim is created on its first feature
+	// flag.
+	bot.SendText(victimId, "/start")
+	bot.Press(victimId, utils.AddFeatureFlagCallbackData)
+	bot.SendText(victimId, "dark-mode")
+	message = lastMessage(t, bot, fmt.Sprint(victimId))
+	if !strings.HasPrefix(message.Text, "پرچم شما ثبت شد") {
+		t.Fatalf("feature flag was not added: %q", message.Text)
+	}
+}
+
+func TestAuditOfAFeatureFlagIsScopedToItsWorkspace(t *testing.T) {
+	const admin = 1
+	bot, err := NewBot(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Close()
+
+	ctx := t.Context()
+	users := []int{101, 102}
+	for _, userId := range users {
+		bot.SendText(userId, "/start")
+		bot.Press(userId, utils.AddFeatureFlagCallbackData)
+		bot.SendText(userId, "dark-mode")
+	}
+	workspaceId, err := bot.Repo.GetCurrentWorkspaceId(ctx, users[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bot.SendText(admin, fmt.Sprintf("/audit flag %d dark-mode", workspaceId))
+	message := lastMessage(t, bot, fmt.Sprint(admin))
+	if !strings.Contains(message.Text, fmt.Sprintf("کاربر %d", users[0])) ||
+		strings.Contains(message.Text, fmt.Sprintf("کاربر %d", users[1])) {
+		t.Fatalf("audit of the feature flag of %d is %q", users[0], message.Text)
+	}
+
+	// the current workspace of the admin has no such feature flag.
+	bot.SendText(admin, "/audit flag dark-mode")
+	if message := lastMessage(t, bot, fmt.Sprint(admin)); message.Text != "تغییری ثبت نشده است." {
+		t.Fatalf("audit of a feature flag of another workspace is %q", message.Text)
+	}
+
+	err = bot.Repo.RemoveFeatureFlag(ctx, workspaceId, "dark-mode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = handler.PurgeTrash(ctx, bot.Repo, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditLogs, err := bot.Repo.GetAuditLogsByFeatureFlag(ctx, workspaceId, "dark-mode", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(auditLogs) != 1 ||
+		auditLogs[0].ActorId != entities.SystemActorId ||
+		auditLogs[0].Action != entities.DeleteAuditAction ||
+		auditLogs[0].Before == "" {
+		t.Fatalf("purging the trash was audited as %+v", auditLogs)
+	}
+}
@@ -0,0 +1,554 @@
+// Package testsupport runs the bot against an in-process fake of the bale
+// bot api, so that whole conversations can be driven end to end without
+// bale or postgres.
+package testsupport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/api"
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+const (
+	// FakeToken is the token the fake server accepts.
+	FakeToken = "fake-token"
+	// maxPollTimeout caps how long getUpdates is held, so that closing the
+	// server is never blocked by a long poll.
+	maxPollTimeout = 5 * time.Second
+)
+
+// Call is a request received by the fake server. Body holds the json body,
+// or the form fields encoded as json for multipart requests.
+type Call struct {
+	Method string
+	Body   json.RawMessage
+}
+
+// Markup is a reply markup as the fake server received it.
+type Markup struct {
+	Keyboard       [][]entities.KeyboardButton       `json:"keyboard"`
+	InlineKeyboard [][]entities.InlineKeyboardButton `json:"inline_keyboard"`
+	RemoveKeyboard bool                              `json:"remove_keyboard"`
+}
+
+// SentMessage is a message the bot sent or edited.
+type SentMessage struct {
+	Method      string  `json:"-"`
+	ChatId      string  `json:"chat_id"`
+	MessageId   int     `json:"message_id"`
+	Text        string  `json:"text"`
+	ReplyMarkup *Markup `json:"reply_markup"`
+}
+
+// CallbackData returns the callback data of the inline button with text.
+func (m SentMessage) CallbackData(text string) (string, bool) {
+	if m.ReplyMarkup == nil {
+		return "", false
+	}
+	for _, row := range m.ReplyMarkup.InlineKeyboard {
+		for _, button := range row {
+			if button.Text == text && button.CallbackData != nil {
+				return *button.CallbackData, true
+			}
+		}
+	}
+	return "", false
+}
+
+type failure struct {
+	errorCode   int
+	description string
+	retryAfter  int
+}
+
+// FakeBale is an in-process bale bot api server. it records every request,
+// answers with plausible results and serves scripted updates through
+// getUpdates.
+type FakeBale struct {
+	server *httptest.Server
+
+	mu            sync.Mutex
+	changed       chan struct{}
+	calls         []Call
+	updates       []entities.Update
+	lastUpdateId  int
+	lastMessageId int
+	webhookUrl    string
+	failures      map[string][]failure
+	blocked       map[string]bool
+	// chatMembers are the statuses of users in groups, by chat id and
+	// user id. users who are not in it have left the group.
+	chatMembers map[string]map[int]string
+}
+
+func NewFakeBale() *FakeBale {
+	fake := &FakeBale{
+		changed:  make(chan struct{}),
+		failures: map[string][]failure{},
+		blocked:  map[string]bool{},
+
+		chatMembers: map[string]map[int]string{},
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	return fake
+}
+
+func (f *FakeBale) URL() string {
+	return f.server.URL
+}
+
+func (f *FakeBale) Close() {
+	f.server.Close()
+}
+
+// Config returns an api config pointing at the fake server. the rate limits
+// are raised so that tests are not slowed down.
+func (f *FakeBale) Config() api.Config {
+	return api.Config{
+		BaseURL:     f.server.URL,
+		Timeout:     10 * time.Second,
+		GlobalRate:  1000,
+		GlobalBurst: 1000,
+		ChatRate:    1000,
+		ChatBurst:   1000,
+	}
+}
+
+func (f *FakeBale) Api() api.BaleApi {
+	return api.NewBaleApi(FakeToken, f.Config())
+}
+
+// PushUpdate queues an update for getUpdates. an update id is assigned if
+// it has none.
+func (f *FakeBale) PushUpdate(update entities.Update) entities.Update {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if update.UpdateId == 0 {
+		f.lastUpdateId++
+		update.UpdateId = f.lastUpdateId
+	}
+	f.lastUpdateId = max(f.lastUpdateId, update.UpdateId)
+	f.updates = append(f.updates, update)
+	f.notify()
+	return update
+}
+
+// TextMessage builds an update of a private text message from userId.
+func (f *FakeBale) TextMessage(userId int, text string) entities.Update {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastUpdateId++
+	f.lastMessageId++
+	return entities.Update{
+		UpdateId: f.lastUpdateId,
+		Message: &entities.Message{
+			MessageId: f.lastMessageId,
+			From:      user(userId),
+			Date:      int(time.Now().Unix()),
+			Chat:      entities.Chat{Id: int64(userId), Type: "private"},
+			Text:      &text,
+		},
+	}
+}
+
+// CallbackQuery builds an update of userId pressing an inline button with
+// data.
+func (f *FakeBale) CallbackQuery(userId int, data string) entities.Update {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastUpdateId++
+	f.lastMessageId++
+	return entities.Update{
+		UpdateId: f.lastUpdateId,
+		CallbackQuery: &entities.CallbackQuery{
+			Id:   strconv.Itoa(f.lastUpdateId),
+			From: user(userId),
+			Message: &entities.Message{
+				MessageId: f.lastMessageId,
+				From:      entities.User{Id: 1, IsBot: true, FirstName: "chronos"},
+				Date:      int(time.Now().Unix()),
+				Chat:      entities.Chat{Id: int64(userId), Type: "private"},
+			},
+			Data: &data,
+		},
+	}
+}
+
+func user(userId int) entities.User {
+	userName := fmt.Sprintf("user%d", userId)
+	return entities.User{
+		Id:        userId,
+		FirstName: userName,
+		UserName:  &userName,
+	}
+}
+
+// Fail makes the next request of method fail with errorCode. retryAfter is
+// sent as the retry_after parameter when it is positive.
+func (f *FakeBale) Fail(
+	method string,
+	errorCode int,
+	description string,
+	retryAfter int,
+) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures[method] = append(
+		f.failures[method],
+		failure{errorCode, description, retryAfter},
+	)
+}
+
+// Block makes messages to chatId fail as if the user blocked the bot.
+func (f *FakeBale) Block(chatId string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.blocked[chatId] = true
+}
+
+// SetChatMember sets the status getChatMember returns for the user in
+// chatId.
+func (f *FakeBale) SetChatMember(chatId string, userId int, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.chatMembers[chatId] == nil {
+		f.chatMembers[chatId] = map[int]string{}
+	}
+	f.chatMembers[chatId][userId] = status
+}
+
+// Calls returns the requests of method, or every request if method is
+// empty.
+func (f *FakeBale) Calls(method string) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var calls []Call
+	for _, call := range f.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Messages returns the messages sent or edited in chatId, or in every chat
+// if chatId is empty.
+func (f *FakeBale) Messages(chatId string) []SentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.messages(chatId)
+}
+
+func (f *FakeBale) messages(chatId string) []SentMessage {
+	var messages []SentMessage
+	for _, call := range f.calls {
+		switch call.Method {
+		case "sendMessage", "editMessageText", "editMessageReplyMarkup":
+		default:
+			continue
+		}
+
+		var message SentMessage
+		err := json.Unmarshal(call.Body, &message)
+		if err != nil {
+			continue
+		}
+		message.Method = call.Method
+		if chatId == "" || message.ChatId == chatId {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// WaitForMessages waits until count messages have been sent in chatId and
+// returns them.
+func (f *FakeBale) WaitForMessages(
+	chatId string,
+	count int,
+	timeout time.Duration,
+) ([]SentMessage, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		f.mu.Lock()
+		messages := f.messages(chatId)
+		changed := f.changed
+		f.mu.Unlock()
+
+		if len(messages) >= count {
+			return messages, nil
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return messages, fmt.Errorf(
+				"got %d messages in chat %s, want %d",
+				len(messages),
+				chatId,
+				count,
+			)
+		}
+	}
+}
+
+// LastMessage returns the last message sent or edited in chatId.
+func (f *FakeBale) LastMessage(chatId string) (SentMessage, bool) {
+	messages := f.Messages(chatId)
+	if len(messages) == 0 {
+		return SentMessage{}, false
+	}
+	return messages[len(messages)-1], true
+}
+
+// WebhookUrl returns the url of the last setWebhook request.
+func (f *FakeBale) WebhookUrl() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.webhookUrl
+}
+
+// notify wakes up the waiters of a change. f.mu must be held.
+func (f *FakeBale) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *FakeBale) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	token, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+	if !ok || token != FakeToken {
+		writeError(w, http.StatusUnauthorized, "Unauthorized", 0)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), 0)
+		return
+	}
+
+	f.mu.Lock()
+	if method != "getUpdates" {
+		f.calls = append(f.calls, Call{Method: method, Body: body})
+		f.notify()
+	}
+	failures := f.failures[method]
+	if len(failures) > 0 {
+		f.failures[method] = failures[1:]
+		f.mu.Unlock()
+		writeError(
+			w,
+			failures[0].errorCode,
+			failures[0].description,
+			failures[0].retryAfter,
+		)
+		return
+	}
+	f.mu.Unlock()
+
+	var result any
+	switch method {
+	case "sendMessage", "editMessageText", "editMessageReplyMarkup", "sendDocument":
+		result, err = f.message(method, body)
+	case "answerCallbackQuery", "deleteMessage", "deleteWebhook":
+		result = true
+	case "setWebhook":
+		var request entities.RequestSetWebhook
+		err = json.Unmarshal(body, &request)
+		f.mu.Lock()
+		f.webhookUrl = request.Url
+		f.mu.Unlock()
+		result = true
+	case "getWebhookInfo":
+		result = entities.WebhookInfo{Url: f.WebhookUrl()}
+	case "getMe":
+		result = entities.User{Id: 1, IsBot: true, FirstName: "chronos"}
+	case "getChat":
+		var request entities.RequestGetChat
+		err = json.Unmarshal(body, &request)
+		chatId, _ := strconv.ParseInt(request.ChatId, 10, 64)
+		result = entities.Chat{Id: chatId, Type: "private"}
+	case "getChatMember":
+		var request entities.RequestGetChatMember
+		err = json.Unmarshal(body, &request)
+		result = f.chatMember(request)
+	case "getUpdates":
+		var request entities.RequestGetUpdates
+		err = json.Unmarshal(body, &request)
+		if err == nil {
+			result = f.getUpdates(r, request)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "Not Found", 0)
+		return
+	}
+
+	if errors.Is(err, errBlocked) {
+		writeError(w, http.StatusForbidden, "Forbidden: bot was blocked by the user", 0)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), 0)
+		return
+	}
+	writeResult(w, result)
+}
+
+var errBlocked = errors.New("blocked")
+
+func (f *FakeBale) chatMember(request entities.RequestGetChatMember) entities.ChatMember {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	status, ok := f.chatMembers[request.ChatId][request.UserId]
+	if !ok {
+		status = "left"
+	}
+	return entities.ChatMember{Status: status, User: user(request.UserId)}
+}
+
+func (f *FakeBale) message(method string, body []byte) (entities.Message, error) {
+	var request SentMessage
+	err := json.Unmarshal(body, &request)
+	if err != nil {
+		return entities.Message{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.blocked[request.ChatId] {
+		return entities.Message{}, errBlocked
+	}
+
+	messageId := request.MessageId
+	if method == "sendMessage" || method == "sendDocument" {
+		f.lastMessageId++
+		messageId = f.lastMessageId
+	}
+	chatId, _ := strconv.ParseInt(request.ChatId, 10, 64)
+	return entities.Message{
+		MessageId: messageId,
+		From:      entities.User{Id: 1, IsBot: true, FirstName: "chronos"},
+		Date:      int(time.Now().Unix()),
+		Chat:      entities.Chat{Id: chatId},
+		Text:      &request.Text,
+	}, nil
+}
+
+// getUpdates drops the updates before the offset, like bale does, and waits
+// for new updates for up to the timeout of the request.
+func (f *FakeBale) getUpdates(
+	r *http.Request,
+	request entities.RequestGetUpdates,
+) []entities.Update {
+	timeout := min(time.Duration(request.Timeout)*time.Second, maxPollTimeout)
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		f.mu.Lock()
+		pending := f.updates[:0]
+		for _, update := range f.updates {
+			if update.UpdateId >= request.Offset {
+				pending = append(pending, update)
+			}
+		}
+		f.updates = pending
+		if request.Limit > 0 && len(pending) > request.Limit {
+			pending = pending[:request.Limit]
+		}
+		result := append([]entities.Update(nil), pending...)
+		changed := f.changed
+		f.mu.Unlock()
+
+		if len(result) > 0 {
+			return result
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return []entities.Update{}
+		case <-r.Context().Done():
+			return []entities.Update{}
+		}
+	}
+}
+
+// readBody returns the json body of the request. multipart form fields are
+// converted to a json object, with the uploaded file replaced by its name.
+func readBody(r *http.Request) ([]byte, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return io.ReadAll(r.Body)
+	}
+
+	fields := map[string]string{}
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FileName() != "" {
+			fields[part.FormName()] = part.FileName()
+			continue
+		}
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		fields[part.FormName()] = string(value)
+	}
+	return json.Marshal(fields)
+}
+
+func writeResult(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entities.Response[any]{Ok: true, Result: result})
+}
+
+func writeError(
+	w http.ResponseWriter,
+	errorCode int,
+	description string,
+	retryAfter int,
+) {
+	response := entities.Response[any]{
+		ErrorCode:   errorCode,
+		Description: description,
+	}
+	if retryAfter > 0 {
+		response.Parameters = &entities.ResponseParameters{RetryAfter: retryAfter}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(errorCode)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,108 @@
+package updates
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+	"github.com/fatemehkarimi/chronos_bot/handler"
+)
+
+const (
+	defaultPollTimeout = 30
+	defaultPollLimit   = 100
+	minBackoff         = time.Second
+	maxBackoff         = time.Minute
+)
+
+type UpdatesApi interface {
+	GetUpdates(
+		ctx context.Context,
+		offset int,
+		limit int,
+		timeout int,
+	) ([]entities.Update, error)
+	SetWebhook(ctx context.Context, url string, secretToken string) error
+	DeleteWebhook(ctx context.Context) error
+	GetWebhookInfo(ctx context.Context) (entities.WebhookInfo, error)
+}
+
+// PollingSource long polls bale for updates and hands them to the handler
+// in process. it is meant for running the bot without a public address.
+type PollingSource struct {
+	Api     UpdatesApi
+	Handler handler.Handler
+	// Timeout is the long polling timeout in seconds.
+	Timeout int
+	Limit   int
+	// sleep waits out the backoff after a failed poll. it is replaced in
+	// tests.
+	sleep func(ctx context.Context, d time.Duration) bool
+}
+
+func (s *PollingSource) Run(ctx context.Context) error {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultPollTimeout
+	}
+	limit := s.Limit
+	if limit <= 0 {
+		limit = defaultPollLimit
+	}
+	wait := s.sleep
+	if wait == nil {
+		wait = sleep
+	}
+
+	// bale does not answer getUpdates while a webhook is set.
+	err := s.Api.DeleteWebhook(ctx)
+	if err != nil {
+		slog.Error("error deleting webhook", slog.Any("error", err))
+	}
+
+	offset := s.Handler.GetLastProcessedUpdateId(ctx) + 1
+	backoff := minBackoff
+	for ctx.Err() == nil {
+		updates, err := s.Api.GetUpdates(ctx, offset, limit, timeout)
+		if err == nil {
+			offset, err = s.receive(ctx, offset, updates)
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			slog.Error(
+				"error polling updates",
+				slog.Int("offset", offset),
+				slog.Duration("backoff", backoff),
+				slog.Any("error", err),
+			)
+			if !wait(ctx, backoff) {
+				break
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = minBackoff
+	}
+	return nil
+}
+
+// receive hands the updates to the handler and returns the offset after the
+// last received update. updates after a failed one are polled again.
+func (s *PollingSource) receive(
+	ctx context.Context,
+	offset int,
+	updates []entities.Update,
+) (int, error) {
+	for _, update := range updates {
+		err := s.Handler.ReceiveUpdate(ctx, update)
+		if err != nil {
+			return offset, err
+		}
+		offset = max(offset, update.UpdateId+1)
+	}
+	return offset, nil
+}
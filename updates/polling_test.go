@@ -0,0 +1,236 @@
+package updates
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/entities"
+)
+
+// fakeUpdatesApi answers each getUpdates with the next of polls and records
+// the offsets it is asked for.
+type fakeUpdatesApi struct {
+	mu      sync.Mutex
+	polls   []func(offset int) ([]entities.Update, error)
+	offsets []int
+	// done is called when polls run out.
+	done func()
+}
+
+func (a *fakeUpdatesApi) GetUpdates(
+	ctx context.Context,
+	offset int,
+	limit int,
+	timeout int,
+) ([]entities.Update, error) {
+	a.mu.Lock()
+	a.offsets = append(a.offsets, offset)
+	if len(a.polls) == 0 {
+		a.mu.Unlock()
+		a.done()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	poll := a.polls[0]
+	a.polls = a.polls[1:]
+	a.mu.Unlock()
+	return poll(offset)
+}
+
+func (a *fakeUpdatesApi) SetWebhook(ctx context.Context, url string, secretToken string) error {
+	return nil
+}
+
+func (a *fakeUpdatesApi) DeleteWebhook(ctx context.Context) error {
+	return nil
+}
+
+func (a *fakeUpdatesApi) GetWebhookInfo(ctx context.Context) (entities.WebhookInfo, error) {
+	return entities.WebhookInfo{}, nil
+}
+
+// updatesFrom returns the updates from offset to last.
+func updatesFrom(last int) func(offset int) ([]entities.Update, error) {
+	return func(offset int) ([]entities.Update, error) {
+		var updates []entities.Update
+		for updateId := offset; updateId <= last; updateId++ {
+			updates = append(updates, entities.Update{UpdateId: updateId})
+		}
+		return updates, nil
+	}
+}
+
+func failedPoll(offset int) ([]entities.Update, error) {
+	return nil, errors.New("bad gateway")
+}
+
+// fakeHandler records the updates it receives and fails each update in
+// failures once. it counts the webhook requests it serves.
+type fakeHandler struct {
+	mu                    sync.Mutex
+	lastProcessedUpdateId int
+	failures              map[int]bool
+	received              []int
+	webhookRequests       int
+}
+
+func (h *fakeHandler) GetUpdates(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.webhookRequests++
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *fakeHandler) GetLastProcessedUpdateId(ctx context.Context) int {
+	return h.lastProcessedUpdateId
+}
+
+func (h *fakeHandler) ReceiveUpdate(ctx context.Context, update entities.Update) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.received = append(h.received, update.UpdateId)
+	if h.failures[update.UpdateId] {
+		delete(h.failures, update.UpdateId)
+		return errors.New("database is down")
+	}
+	return nil
+}
+
+func (h *fakeHandler) ProcessUpdate(ctx context.Context, update entities.Update) {}
+
+func (h *fakeHandler) Stop() {}
+
+// runPolling runs source until the polls of api run out and returns the
+// backoffs it waited.
+func runPolling(t *testing.T, source *PollingSource, api *fakeUpdatesApi) []time.Duration {
+	t.Helper()
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	api.done = cancel
+
+	var backoffs []time.Duration
+	source.Api = api
+	source.sleep = func(ctx context.Context, d time.Duration) bool {
+		backoffs = append(backoffs, d)
+		return ctx.Err() == nil
+	}
+
+	err := source.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backoffs
+}
+
+func TestPollingResumesAfterTheLastProcessedUpdate(t *testing.T) {
+	h := &fakeHandler{lastProcessedUpdateId: 41}
+	api := &fakeUpdatesApi{
+		polls: []func(offset int) ([]entities.Update, error){updatesFrom(43)},
+	}
+	runPolling(t, &PollingSource{Handler: h}, api)
+
+	if want := []int{42, 44}; !slices.Equal(api.offsets, want) {
+		t.Fatalf("polled offsets %v, want %v", api.offsets, want)
+	}
+	if want := []int{42, 43}; !slices.Equal(h.received, want) {
+		t.Fatalf("received updates %v, want %v", h.received, want)
+	}
+}
+
+func TestPollingPollsAFailedUpdateAgain(t *testing.T) {
+	h := &fakeHandler{lastProcessedUpdateId: 9, failures: map[int]bool{11: true}}
+	api := &fakeUpdatesApi{
+		polls: []func(offset int) ([]entities.Update, error){
+			updatesFrom(12),
+			updatesFrom(12),
+		},
+	}
+	backoffs := runPolling(t, &PollingSource{Handler: h}, api)
+
+	if want := []int{10, 11, 13}; !slices.Equal(api.offsets, want) {
+		t.Fatalf("polled offsets %v, want %v", api.offsets, want)
+	}
+	// the update after the failed one waits for it.
+	if want := []int{10, 11, 11, 12}; !slices.Equal(h.received, want) {
+		t.Fatalf("received updates %v, want %v", h.received, want)
+	}
+	if want := []time.Duration{minBackoff}; !slices.Equal(backoffs, want) {
+		t.Fatalf("backed off %v, want %v", backoffs, want)
+	}
+}
+
+func TestPollingBacksOff(t *testing.T) {
+	var polls []func(offset int) ([]entities.Update, error)
+	for range 8 {
+		polls = append(polls, failedPoll)
+	}
+	polls = append(polls, updatesFrom(1), failedPoll)
+
+	h := &fakeHandler{}
+	api := &fakeUpdatesApi{polls: polls}
+	backoffs := runPolling(t, &PollingSource{Handler: h}, api)
+
+	want := []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		16 * time.Second,
+		32 * time.Second,
+		maxBackoff,
+		maxBackoff,
+		// a successful poll resets the backoff.
+		minBackoff,
+	}
+	if !slices.Equal(backoffs, want) {
+		t.Fatalf("backed off %v, want %v", backoffs, want)
+	}
+	// failed polls keep the offset.
+	wantOffsets := slices.Repeat([]int{1}, 9)
+	wantOffsets = append(wantOffsets, 2, 2)
+	if !slices.Equal(api.offsets, wantOffsets) {
+		t.Fatalf("polled offsets %v, want %v", api.offsets, wantOffsets)
+	}
+}
+
+func TestPollingStopsWhenCancelled(t *testing.T) {
+	tests := []struct {
+		name string
+		poll func(offset int) ([]entities.Update, error)
+	}{
+		{"while polling", nil},
+		{"while backing off", failedPoll},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+			api := &fakeUpdatesApi{done: func() {}}
+			if test.poll != nil {
+				api.polls = append(api.polls, test.poll)
+			}
+			source := &PollingSource{Api: api, Handler: &fakeHandler{}}
+
+			stopped := make(chan error)
+			go func() {
+				stopped <- source.Run(ctx)
+			}()
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+
+			select {
+			case err := <-stopped:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("polling did not stop")
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package updates
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/handler"
+)
+
+const (
+	WebhookMode = "webhook"
+	PollingMode = "polling"
+)
+
+type Config struct {
+	Mode string
+	Addr string
+	// WebhookUrl is the public url of the getUpdates endpoint. the webhook is
+	// registered with bale on startup if it is set.
+	WebhookUrl string
+	// SecretToken authenticates webhook requests. bale sends it in the
+	// secret token header, or it can be the last segment of the webhook url.
+	SecretToken string
+	PollTimeout int
+	PollLimit   int
+}
+
+// UpdateSource delivers the updates of the bot to the handler until ctx is
+// done.
+type UpdateSource interface {
+	Run(ctx context.Context) error
+}
+
+func NewUpdateSource(
+	config Config,
+	updatesApi UpdatesApi,
+	h handler.Handler,
+) (UpdateSource, error) {
+	switch config.Mode {
+	case "", WebhookMode:
+		addr := config.Addr
+		if addr == "" {
+			addr = defaultAddr
+		}
+		return &WebhookSource{
+			Addr:        addr,
+			Api:         updatesApi,
+			Handler:     h,
+			WebhookUrl:  config.WebhookUrl,
+			SecretToken: config.SecretToken,
+		}, nil
+	case PollingMode:
+		return &PollingSource{
+			Api:     updatesApi,
+			Handler: h,
+			Timeout: config.PollTimeout,
+			Limit:   config.PollLimit,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown update source mode %q", config.Mode)
+	}
+}
+
+// sleep waits for d and reports false if ctx is done before that.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
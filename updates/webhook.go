@@ -0,0 +1,143 @@
+package updates
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fatemehkarimi/chronos_bot/handler"
+)
+
+const (
+	defaultAddr     = ":8080"
+	shutdownTimeout = 10 * time.Second
+	// SecretTokenHeader carries the secret token given to setWebhook.
+	SecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+)
+
+// WebhookSource serves the webhook endpoint that bale posts updates to.
+type WebhookSource struct {
+	Addr        string
+	Api         UpdatesApi
+	Handler     handler.Handler
+	WebhookUrl  string
+	SecretToken string
+}
+
+func (s *WebhookSource) Run(ctx context.Context) error {
+	if s.SecretToken == "" {
+		slog.Warn("webhook secret token is not set, updates are not authenticated")
+	}
+
+	server := &http.Server{
+		Addr:         s.Addr,
+		Handler:      s.routes(),
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	if s.WebhookUrl != "" {
+		s.register(ctx)
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(
+		context.Background(),
+		shutdownTimeout,
+	)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		return err
+	}
+
+	err = <-errs
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *WebhookSource) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /getUpdates", s.authenticate(s.Handler.GetUpdates))
+	mux.HandleFunc(
+		"POST /getUpdates/{secret}",
+		s.authenticate(s.Handler.GetUpdates),
+	)
+	mux.HandleFunc("/healthcheck", Healthcheck)
+	return mux
+}
+
+// register sets the webhook of the bot to WebhookUrl. the secret token is
+// also appended to the url for when bale does not send the header.
+func (s *WebhookSource) register(ctx context.Context) {
+	url := s.WebhookUrl
+	if s.SecretToken != "" {
+		url = strings.TrimSuffix(url, "/") + "/" + s.SecretToken
+	}
+
+	err := s.Api.SetWebhook(ctx, url, s.SecretToken)
+	if err != nil {
+		slog.Error("error setting webhook", slog.Any("error", err))
+		return
+	}
+
+	info, err := s.Api.GetWebhookInfo(ctx)
+	if err != nil {
+		slog.Error("error getting webhook info", slog.Any("error", err))
+		return
+	}
+	slog.Info(
+		"webhook is set",
+		slog.Int("pendingUpdates", info.PendingUpdateCount),
+		slog.String("lastError", info.LastErrorMessage),
+	)
+}
+
+// authenticate rejects the requests that carry neither the secret token
+// header nor the secret token path segment.
+func (s *WebhookSource) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.SecretToken == "" {
+			next(w, r)
+			return
+		}
+
+		token := r.Header.Get(SecretTokenHeader)
+		if token == "" {
+			token = r.PathValue("secret")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.SecretToken)) != 1 {
+			slog.Warn(
+				"rejected unauthenticated webhook request",
+				slog.String("remoteAddr", r.RemoteAddr),
+			)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func Healthcheck(w http.ResponseWriter, r *http.Request) {
+	_, err := w.Write([]byte("hello from chronos bot"))
+	if err != nil {
+		slog.Error("error writing healthcheck response", slog.Any("error", err))
+	}
+}
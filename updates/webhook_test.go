@@ -0,0 +1,54 @@
+package updates
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookAuthentication(t *testing.T) {
+	const secret = "s3cret"
+	tests := []struct {
+		name        string
+		secretToken string
+		path        string
+		header      string
+		want        int
+	}{
+		{"header", secret, "/getUpdates", secret, http.StatusOK},
+		{"path segment", secret, "/getUpdates/" + secret, "", http.StatusOK},
+		{"header wins over path segment", secret, "/getUpdates/wrong", secret, http.StatusOK},
+		{"wrong header", secret, "/getUpdates", "wrong", http.StatusUnauthorized},
+		{"wrong path segment", secret, "/getUpdates/wrong", "", http.StatusUnauthorized},
+		{"wrong header with right path segment", secret, "/getUpdates/" + secret, "wrong", http.StatusUnauthorized},
+		{"missing token", secret, "/getUpdates", "", http.StatusUnauthorized},
+		{"prefix of the token", secret, "/getUpdates", secret[:3], http.StatusUnauthorized},
+		{"no secret token", "", "/getUpdates", "", http.StatusOK},
+		{"no secret token with a path segment", "", "/getUpdates/anything", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := &fakeHandler{}
+			source := &WebhookSource{Handler: h, SecretToken: test.secretToken}
+
+			req := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader("{}"))
+			if test.header != "" {
+				req.Header.Set(SecretTokenHeader, test.header)
+			}
+			res := httptest.NewRecorder()
+			source.routes().ServeHTTP(res, req)
+
+			if res.Code != test.want {
+				t.Fatalf("got status %d, want %d", res.Code, test.want)
+			}
+			wantRequests := 0
+			if test.want == http.StatusOK {
+				wantRequests = 1
+			}
+			if h.webhookRequests != wantRequests {
+				t.Fatalf("the handler served %d requests, want %d", h.webhookRequests, wantRequests)
+			}
+		})
+	}
+}